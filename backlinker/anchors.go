@@ -0,0 +1,233 @@
+package backlinker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// HeadingAnchorStyle chooses whether, and how, headings are given explicit ids in the
+// output, so that links to them don't depend on how the renderer makes ids.
+type HeadingAnchorStyle string
+
+const (
+	// HeadingAnchorsOff leaves headings as they are.
+	HeadingAnchorsOff HeadingAnchorStyle = ""
+	// HeadingAnchorsAttribute adds a {#id} attribute to each heading, as Hugo (with
+	// goldmark's heading attributes, which it enables by default) understands.
+	HeadingAnchorsAttribute HeadingAnchorStyle = "attribute"
+	// HeadingAnchorsHTML starts each heading with an empty <a id="..."></a>. Hugo only
+	// keeps it when goldmark's unsafe rendering is turned on.
+	HeadingAnchorsHTML HeadingAnchorStyle = "html"
+)
+
+// splitAnchor separates the note name in a link such as [[page#heading]] from the heading
+// it points to. The anchor is empty when the link is to the whole note.
+func splitAnchor(linkText string) (string, string) {
+	linkText, _ = splitLabel(linkText)
+	index := strings.Index(linkText, "#")
+	if index < 0 {
+		return linkText, ""
+	}
+	return linkText[:index], strings.TrimSpace(linkText[index+1:])
+}
+
+// splitLabel separates what a link such as [[page|label]] points to from the text shown
+// for it, which is empty when the link doesn't have its own. The pipe can be written \|
+// so that it doesn't end the cell of a table.
+func splitLabel(linkText string) (string, string) {
+	index := strings.Index(linkText, "|")
+	if index < 0 {
+		return linkText, ""
+	}
+	return strings.TrimSuffix(linkText[:index], "\\"), strings.TrimSpace(linkText[index+1:])
+}
+
+// linkLabel is the text shown for a link: its label, or otherwise the link text itself.
+func linkLabel(linkText string) string {
+	target, label := splitLabel(linkText)
+	if label == "" {
+		return target
+	}
+	return label
+}
+
+// isEmptyLink is true for links like [[]] and [[ ]] that don't name anything.
+func isEmptyLink(linkText string) bool {
+	name, anchor := splitAnchor(linkText)
+	return strings.TrimSpace(name) == "" && anchor == ""
+}
+
+// isInPageLink is true for links like [[#heading]] that go to a heading (or block) of
+// the note they're written in.
+func isInPageLink(linkText string) bool {
+	name, anchor := splitAnchor(linkText)
+	return strings.TrimSpace(name) == "" && anchor != ""
+}
+
+// inPageLink turns a link like [[#heading]] into a link to the heading's id within the
+// same page. A block can only be linked to when block anchors are being written.
+func inPageLink(linkText string, opts *Options) string {
+	_, anchor := splitAnchor(linkText)
+	fragment := headingSlug(anchor)
+	if block, isBlock := blockRef(anchor); isBlock {
+		if !opts.BlockAnchors {
+			return linkLabel(linkText)
+		}
+		fragment = block
+	}
+	return fmt.Sprintf("[%s](#%s)", linkLabel(linkText), fragment)
+}
+
+// headingSlug turns heading text into the id used to link to it, as Hugo makes ids
+// (its default "github" style): letters and digits are kept in lower case, along with
+// underscores, each space or hyphen becomes a hyphen, and everything else is dropped.
+func headingSlug(heading string) string {
+	var slug strings.Builder
+	for _, r := range strings.TrimSpace(headingText(heading)) {
+		switch {
+		case r == '-' || r == ' ':
+			slug.WriteRune('-')
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			slug.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return slug.String()
+}
+
+var emphasisUnderscores = regexp.MustCompile(`(^|[^\p{L}\p{N}])_+|_+([^\p{L}\p{N}]|$)`)
+
+// headingText is the text of a heading once it's rendered, which is what Hugo makes the
+// id from: links are reduced to the text shown for them, and the markers of emphasis
+// and code spans are dropped.
+func headingText(heading string) string {
+	text := unwrapWikilinks(heading)
+	text = markdownLink.ReplaceAllString(text, "$1")
+	text = strings.NewReplacer("`", "", "*", "", "~~", "").Replace(text)
+	return emphasisUnderscores.ReplaceAllString(text, "$1$2")
+}
+
+// anchorID is the id of the heading of the file that a link such as [[page#heading]]
+// points to. A heading given its own id ({#id} after its text) goes by that id.
+func (file *markdownFile) anchorID(anchor string) string {
+	slug := headingSlug(anchor)
+	if id, exists := file.headings[slug]; exists {
+		return id
+	}
+	return slug
+}
+
+// collectHeadings finds the ATX style headings and the block ids in the file's text,
+// skipping those inside fenced code blocks, and remembers their slugs and ids.
+func collectHeadings(file *markdownFile, filetext []byte) {
+	file.headings = make(map[string]string)
+	file.blocks = make(map[string]bool)
+	heading := regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*\s*$`)
+	inFence := false
+	scanner := bufio.NewScanner(bytes.NewReader(filetext))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if block := blockMarker.FindStringSubmatch(line); block != nil {
+			file.blocks[block[1]] = true
+		}
+		match := heading.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		text, id := match[1], ""
+		if attributes := headingAttributes.FindStringSubmatch(text); attributes != nil {
+			text, id = text[:len(text)-len(attributes[0])], attributes[1]
+		}
+		slug := headingSlug(text)
+		if id == "" {
+			id = slug
+		}
+		// The first heading with the text is the one links to it go to, and a heading can
+		// be linked to by its id as well
+		for _, key := range []string{slug, id} {
+			if _, exists := file.headings[key]; !exists {
+				file.headings[key] = id
+			}
+		}
+	}
+}
+
+// checkAnchors reports links to headings that don't exist in the file linked to.
+func checkAnchors(fileMap map[string]*markdownFile, opts *Options) {
+	for _, file := range sortedFiles(fileMap) {
+		if file.IsNew {
+			continue
+		}
+		for _, bl := range file.BackLinks {
+			if bl.Anchor == "" {
+				continue
+			}
+			if block, isBlock := blockRef(bl.Anchor); isBlock {
+				if !file.blocks[block] {
+					opts.warnf(WarnDanglingAnchor, bl.OtherFile.OriginalName, "%s has no block %q", file.OriginalName, bl.Anchor)
+				}
+				continue
+			}
+			if _, exists := file.headings[headingSlug(bl.Anchor)]; !exists {
+				opts.warnf(WarnDanglingAnchor, bl.OtherFile.OriginalName, "%s has no heading %q", file.OriginalName, bl.Anchor)
+			}
+		}
+	}
+}
+
+// headingAnchorer gives each heading of a note its id as the note's lines are converted.
+// Repeated headings are numbered the way Hugo numbers them: heading, heading-1 and so on.
+type headingAnchorer struct {
+	style   HeadingAnchorStyle
+	inFence bool
+	seen    map[string]int
+}
+
+var (
+	atxHeading        = regexp.MustCompile(`^(\s{0,3}#{1,6})\s+(.*?)\s*$`)
+	headingAttributes = regexp.MustCompile(`\s*\{#([^}\s]+)[^}]*\}$`)
+)
+
+func newHeadingAnchorer(style HeadingAnchorStyle) *headingAnchorer {
+	return &headingAnchorer{style: style, seen: make(map[string]int)}
+}
+
+// anchor returns the line with an id added when it's a heading.
+func (a *headingAnchorer) anchor(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+		a.inFence = !a.inFence
+		return line
+	}
+	match := atxHeading.FindStringSubmatch(line)
+	if a.inFence || match == nil {
+		return line
+	}
+	marker, text := match[1], strings.TrimRight(strings.TrimRight(match[2], "#"), " \t")
+	// A heading that already has an id keeps it
+	if existing := headingAttributes.FindStringSubmatch(text); existing != nil {
+		a.seen[existing[1]]++
+		return line
+	}
+	id := headingSlug(text)
+	if count := a.seen[id]; count > 0 {
+		a.seen[id]++
+		id = fmt.Sprintf("%s-%d", id, count)
+	}
+	a.seen[id]++
+	if a.style == HeadingAnchorsHTML {
+		return fmt.Sprintf(`%s <a id="%s"></a>%s`, marker, id, text)
+	}
+	return fmt.Sprintf("%s %s {#%s}", marker, text, id)
+}
@@ -0,0 +1,178 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadingSlug(t *testing.T) {
+	require := require.New(t)
+	require.Equal("background", headingSlug("Background"))
+	require.Equal("why-gardens-matter", headingSlug("Why *Gardens* Matter?"))
+	require.Equal("café--notes", headingSlug(" Café  notes "), "Hugo turns each space into a hyphen")
+	require.Equal("snake_case-and-code", headingSlug("snake_case and `code`"))
+	require.Equal("see-the-garden-and-shed", headingSlug("See [[Garden|the garden]] and [Shed](./shed/)"))
+	require.Equal("emphasis-1-2", headingSlug("_Emphasis_ 1-2!"))
+}
+
+func TestCustomHeadingIDs(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Source.md": "See [[Target#Why It Matters]], [[Target#Intro]] and [[Target#short]].\n",
+		"Target.md": "# Intro\n\n## Why It Matters {#short}\n\n# Intro\n",
+	})
+	report := Report{}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{CheckAnchors: true, Report: &report}))
+	source, err := ioutil.ReadFile(filepath.Join(destDir, "Source.md"))
+	require.NoError(err)
+	require.Contains(string(source), "See [Target#Why It Matters](./target/#short), [Target#Intro](./target/#intro) and [Target#short](./target/#short).\n")
+	require.Empty(report.Warnings)
+}
+
+func TestAnchorLinksConverted(t *testing.T) {
+	require := require.New(t)
+	fileMap := map[string]*markdownFile{
+		"some note.md": createMarkdownFile("Some Note.md", false),
+	}
+	result := convertLinksOnLine("See [[Some Note#Why It Matters]].", fileMap, &Options{})
+	require.Equal("See [Some Note#Why It Matters](./some-note/#why-it-matters).", result)
+	require.Equal(1, len(fileMap), "The anchor shouldn't create a new file")
+}
+
+func TestDanglingAnchorsReported(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Target.md": "# Target\n\n## Background\n\n```\n## Not A Heading\n```\n",
+		"Source.md": "See [[Target#Background]] but not [[target#Not a heading]].\n",
+	})
+	report := Report{}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{CheckAnchors: true, Report: &report})
+	require.NoError(err)
+	require.Equal(1, len(report.Warnings))
+	require.Equal(WarnDanglingAnchor, report.Warnings[0].Kind)
+	require.Equal("Source.md", report.Warnings[0].File)
+	require.Contains(report.Warnings[0].Message, `"Not a heading"`)
+
+	source, err := ioutil.ReadFile(filepath.Join(destDir, "Source.md"))
+	require.NoError(err)
+	require.Contains(string(source), "[Target#Background](./target/#background)")
+	require.NoFileExists(filepath.Join(destDir, "Target#Background.md"))
+}
+
+func TestHeadingAnchorsInjected(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Target.md": "# Target\n\n## Notes\n\nFirst.\n\n## Notes ##\n\n```\n## Notes\n```\n\n### Notes\n\n## Custom {#mine}\n\n## About [[Source]]\n",
+		"Source.md": "Anchored.\n",
+	})
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{HeadingAnchors: HeadingAnchorsAttribute})
+	require.NoError(err)
+	target, err := ioutil.ReadFile(filepath.Join(destDir, "Target.md"))
+	require.NoError(err)
+	require.Equal(`---
+title: Target
+---
+# Target {#target}
+
+## Notes {#notes}
+
+First.
+
+## Notes {#notes-1}
+
+`+"```\n## Notes\n```"+`
+
+### Notes {#notes-2}
+
+## Custom {#mine}
+
+## About [Source](./source/) {#about-source}
+`, string(target))
+
+	err = ProcessBackLinksWithOptions(sourceDir, destDir, Options{HeadingAnchors: HeadingAnchorsHTML})
+	require.NoError(err)
+	target, err = ioutil.ReadFile(filepath.Join(destDir, "Target.md"))
+	require.NoError(err)
+	require.Contains(string(target), "\n# <a id=\"target\"></a>Target\n")
+	require.Contains(string(target), "\n## <a id=\"notes-1\"></a>Notes\n")
+}
+
+func TestEmptyLinks(t *testing.T) {
+	require := require.New(t)
+	sourceDir, _ := writeVault(t, map[string]string{
+		"Typos.md": "Oops [[]] and [[   ]] and [[#]] but [[Real]].\n",
+		"Real.md":  "A note.\n",
+	})
+	for policy, expected := range map[EmptyLinkPolicy]string{
+		EmptyLinksLiteral: "Oops [[]] and [[   ]] and [[#]] but [Real](./real/).\n",
+		EmptyLinksDrop:    "Oops  and  and  but [Real](./real/).\n",
+		EmptyLinksError:   "Oops [[]] and [[   ]] and [[#]] but [Real](./real/).\n",
+	} {
+		destDir := t.TempDir()
+		report := Report{}
+		err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{EmptyLinks: policy, Report: &report})
+		require.NoError(err)
+		typos, err := ioutil.ReadFile(filepath.Join(destDir, "Typos.md"))
+		require.NoError(err)
+		require.Equal("---\ntitle: Typos\n---\n"+expected, string(typos), policy)
+
+		written, err := ioutil.ReadDir(destDir)
+		require.NoError(err)
+		require.Len(written, 2, "No stub is made for an empty link")
+		if policy == EmptyLinksError {
+			require.Len(report.Warnings, 3)
+			require.Equal(WarnEmptyLink, report.Warnings[0].Kind)
+		} else {
+			require.Empty(report.Warnings, policy)
+		}
+	}
+}
+
+func TestInPageLinks(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Page.md": "See [[#Further Reading]] and [[#Notes|the notes]].\n\n## Further Reading\n\n## Notes\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{}))
+	page, err := ioutil.ReadFile(filepath.Join(destDir, "Page.md"))
+	require.NoError(err)
+	require.Contains(string(page), "See [#Further Reading](#further-reading) and [the notes](#notes).\n")
+	require.NotContains(string(page), "Backlinks", "A link within the note isn't a backlink")
+
+	written, err := ioutil.ReadDir(destDir)
+	require.NoError(err)
+	require.Len(written, 1, "No stub is made for a link within the note")
+}
+
+func TestBlockReferences(t *testing.T) {
+	require := require.New(t)
+	notes := map[string]string{
+		"Source.md": "As [[Target#^quote1|they said]], and [[Target#^gone]].\n",
+		"Target.md": "A famous line. ^quote1\n\n```\ncode ^notablock\n```\n",
+	}
+	sourceDir, destDir := writeVault(t, notes)
+	report := Report{}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{CheckAnchors: true, Report: &report}))
+	source, err := ioutil.ReadFile(filepath.Join(destDir, "Source.md"))
+	require.NoError(err)
+	require.Contains(string(source), "As [they said](./target/), and [Target#^gone](./target/).\n")
+	require.NoFileExists(filepath.Join(destDir, "Target#^quote1.md"))
+	require.Len(report.Warnings, 1)
+	require.Equal(WarnDanglingAnchor, report.Warnings[0].Kind)
+	require.Contains(report.Warnings[0].Message, `no block "^gone"`)
+	target, err := ioutil.ReadFile(filepath.Join(destDir, "Target.md"))
+	require.NoError(err)
+	require.Contains(string(target), "A famous line. ^quote1\n")
+
+	destDir = t.TempDir()
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{BlockAnchors: true}))
+	source, err = ioutil.ReadFile(filepath.Join(destDir, "Source.md"))
+	require.NoError(err)
+	require.Contains(string(source), "As [they said](./target/#quote1), and [Target#^gone](./target/#gone).\n")
+	target, err = ioutil.ReadFile(filepath.Join(destDir, "Target.md"))
+	require.NoError(err)
+	require.Contains(string(target), "A famous line. <a id=\"quote1\"></a>\n\n```\ncode ^notablock\n```\n")
+}
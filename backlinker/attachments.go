@@ -0,0 +1,175 @@
+package backlinker
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// attachmentExtensions are the extensions of the files (other than notes) that links are
+// expected to point to, so that a link to one that's missing isn't taken for a link to
+// a note.
+var attachmentExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true,
+	".bmp": true, ".avif": true, ".pdf": true, ".mp3": true, ".mp4": true, ".webm": true,
+	".ogg": true, ".wav": true, ".mov": true,
+}
+
+// imageSize matches the label Obsidian uses to size an embedded image, as in
+// ![[diagram.png|300]] or ![[diagram.png|300x200]].
+var imageSize = regexp.MustCompile(`^\d+(x\d+)?$`)
+
+// findAttachments remembers the files other than notes in the source directory (in its
+// subdirectories too, under Options.Recursive) and in Options.AttachmentDir, so that
+// links to them can be told from links to notes.
+func findAttachments(sourceDir string, opts *Options) error {
+	opts.attachments = make(map[string]string)
+	opts.usedAttachments = make(map[string]bool)
+	err := listAttachments(sourceDir, "", opts.Recursive, opts)
+	if err != nil {
+		return err
+	}
+	if opts.AttachmentDir == "" {
+		return nil
+	}
+	err = listAttachments(sourceDir, path.Clean(opts.AttachmentDir), true, opts)
+	if os.IsNotExist(err) {
+		opts.warnf(WarnBadConfig, opts.AttachmentDir, "attachment directory doesn't exist")
+		return nil
+	}
+	return err
+}
+
+func listAttachments(sourceDir string, dir string, recursive bool, opts *Options) error {
+	fileInfos, err := ioutil.ReadDir(path.Join(sourceDir, dir))
+	if err != nil {
+		return err
+	}
+	for _, fileInfo := range fileInfos {
+		name := path.Join(dir, fileInfo.Name())
+		if strings.HasPrefix(fileInfo.Name(), ".") {
+			continue
+		}
+		if fileInfo.IsDir() {
+			if recursive {
+				err = listAttachments(sourceDir, name, recursive, opts)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if isMarkdownFile(name) {
+			continue
+		}
+		// The first one found keeps a name, as with notes
+		for _, key := range []string{strings.ToLower(name), strings.ToLower(fileInfo.Name())} {
+			if _, exists := opts.attachments[key]; !exists {
+				opts.attachments[key] = name
+			}
+		}
+	}
+	return nil
+}
+
+// attachment returns the path (relative to the source directory) of the attachment a
+// link points to, and whether it's a link to an attachment rather than a note. The path
+// is empty for an attachment that doesn't exist.
+func (o *Options) attachment(name string) (string, bool) {
+	key := strings.ToLower(strings.TrimPrefix(path.Clean(strings.TrimSpace(name)), "/"))
+	if found, exists := o.attachments[key]; exists {
+		return found, true
+	}
+	if found, exists := o.attachments[path.Base(key)]; exists {
+		return found, true
+	}
+	return "", attachmentExtensions[path.Ext(key)]
+}
+
+// attachmentLink writes a link to an attachment: an image when it's embedded, as in
+// ![[diagram.png]], and otherwise a plain link. In the body of a note under
+// Options.PageBundles, the link is to the copy in the note's bundle.
+func attachmentLink(linkText string, found string, embedded bool, opts *Options) string {
+	target, label := splitLabel(linkText)
+	bundled := found != "" && opts.bundle != nil
+	if found == "" {
+		found = strings.TrimSpace(target)
+	}
+	link := opts.withBasePath("./" + (&url.URL{Path: found}).EscapedPath())
+	if bundled {
+		// The attachment is copied into the note's bundle, at the same place under it
+		opts.bundle.bundled = append(opts.bundle.bundled, found)
+		link = (&url.URL{Path: found}).EscapedPath()
+	}
+	if !embedded {
+		return "[" + linkLabel(linkText) + "](" + link + ")"
+	}
+	if label == "" || imageSize.MatchString(label) {
+		label = removeExtension(path.Base(found))
+	}
+	return "![" + label + "](" + link + ")"
+}
+
+// copyAttachments copies the attachments that notes link to into the destination, at
+// the same place they have in the source.
+func copyAttachments(sourceDir string, destDir string, opts *Options) error {
+	if opts.inPlace {
+		return nil
+	}
+	var used []string
+	for name := range opts.usedAttachments {
+		used = append(used, name)
+	}
+	sort.Strings(used)
+	for _, name := range used {
+		err := copyAttachment(path.Join(sourceDir, name), path.Join(destDir, name), opts)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyAttachment copies the attachment at source to dest, unless it's there already.
+func copyAttachment(source string, dest string, opts *Options) error {
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	if existing, err := ioutil.ReadFile(dest); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	if opts.DryRun != nil {
+		opts.logf("Would copy %s to %s\n", source, dest)
+		return nil
+	}
+	opts.logf("Copying %s to %s\n", source, dest)
+	err = os.MkdirAll(path.Dir(dest), 0755)
+	if err != nil {
+		return err
+	}
+	return writeFile(dest, data)
+}
+
+// copyBundledAttachments copies the attachments each note links to into its bundle,
+// under Options.PageBundles.
+func copyBundledAttachments(sourceDir string, destDir string, fileMap map[string]*markdownFile, opts *Options) error {
+	if !opts.PageBundles || opts.inPlace {
+		return nil
+	}
+	for _, file := range includedFiles(fileMap) {
+		bundleDir := path.Dir(path.Join(destDir, opts.outputPath(file)))
+		for _, name := range file.bundled {
+			err := copyAttachment(path.Join(sourceDir, name), path.Join(bundleDir, name), opts)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachments(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Page.md":     "![[Diagram.PNG]] and ![[My Photo.jpg|A photo]] and ![[diagram.png|300]], see [[report.pdf]] and ![[gone.png]].\n",
+		"diagram.png": "png data",
+		"unused.gif":  "gif data",
+	})
+	require.NoError(os.MkdirAll(filepath.Join(sourceDir, "assets"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "assets", "My Photo.jpg"), []byte("jpg data"), 0644))
+	require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "assets", "report.pdf"), []byte("pdf data"), 0644))
+	report := Report{}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{AttachmentDir: "assets", Report: &report}))
+
+	page, err := ioutil.ReadFile(filepath.Join(destDir, "Page.md"))
+	require.NoError(err)
+	require.Contains(string(page), "![diagram](./diagram.png) and ![A photo](./assets/My%20Photo.jpg) and ![diagram](./diagram.png), "+
+		"see [report.pdf](./assets/report.pdf) and ![gone](./gone.png).\n")
+	for name, data := range map[string]string{"diagram.png": "png data", "assets/My Photo.jpg": "jpg data", "assets/report.pdf": "pdf data"} {
+		copied, err := ioutil.ReadFile(filepath.Join(destDir, filepath.FromSlash(name)))
+		require.NoError(err)
+		require.Equal(data, string(copied))
+	}
+	require.NoFileExists(filepath.Join(destDir, "unused.gif"))
+	for _, name := range []string{"Diagram.PNG.md", "diagram.png.md", "gone.png.md", "report.pdf.md"} {
+		require.NoFileExists(filepath.Join(destDir, name), "No stubs are made for attachments")
+	}
+	require.Len(report.Warnings, 1)
+	require.Equal(WarnDanglingLink, report.Warnings[0].Kind)
+	require.Contains(report.Warnings[0].Message, "[[gone.png]] doesn't match any attachment")
+}
+
+func TestPageBundles(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden Plan.md": "![[diagram.png]] by the [[Shed]], with ![[Pond]].\n",
+		"Pond.md":        "![[assets/frogs.jpg|Frogs]] in the [[Garden Plan]].\n",
+		"diagram.png":    "png data",
+	})
+	require.NoError(os.MkdirAll(filepath.Join(sourceDir, "assets"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "assets", "frogs.jpg"), []byte("jpg data"), 0644))
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{PageBundles: true, Embeds: EmbedsInline, AttachmentDir: "assets"}))
+
+	plan, err := ioutil.ReadFile(filepath.Join(destDir, "Garden Plan", "index.md"))
+	require.NoError(err)
+	require.Contains(string(plan), "![diagram](diagram.png) by the [Shed](./shed/), with ![Frogs](assets/frogs.jpg) in the [Garden Plan](./garden-plan/)..\n")
+	require.Contains(string(plan), "- [Pond](./pond/)\n    - ![Frogs](./assets/frogs.jpg) in the [Garden Plan](./garden-plan/).\n",
+		"Contexts link to the copy outside of any bundle")
+	for name, data := range map[string]string{
+		"Garden Plan/diagram.png":      "png data",
+		"Garden Plan/assets/frogs.jpg": "jpg data",
+		"Pond/assets/frogs.jpg":        "jpg data",
+		"assets/frogs.jpg":             "jpg data",
+	} {
+		copied, err := ioutil.ReadFile(filepath.Join(destDir, filepath.FromSlash(name)))
+		require.NoError(err)
+		require.Equal(data, string(copied))
+	}
+	require.FileExists(filepath.Join(destDir, "Shed", "index.md"), "Stubs are bundles too")
+	require.NoFileExists(filepath.Join(destDir, "Pond.md"))
+}
@@ -3,24 +3,22 @@ package backlinker
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
+	"math"
+	"net/url"
 	"os"
 	"path"
 	"regexp"
 	"sort"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
-	wikilinks "github.com/dangoor/goldmark-wikilinks"
-	// "github.com/naoina/toml"
-	"github.com/yuin/goldmark"
-	"github.com/yuin/goldmark/parser"
-	"github.com/yuin/goldmark/text"
-	"github.com/yuin/goldmark/util"
 	"gopkg.in/yaml.v2"
 
 )
@@ -29,6 +27,15 @@ import (
 type backlink struct {
 	OtherFile *markdownFile
 	Context   string
+	// Offset is where the link itself starts within Context.
+	Offset int
+	// Anchor is the heading linked to, for links like [[page#heading]].
+	Anchor string
+	// Label is the text the link was shown with, for links like [[page|label]].
+	Label string
+	// more are the other links from the same note, with contexts of their own, when
+	// they're collapsed into this one under ContextsNested.
+	more []backlink
 }
 
 // markdownFile is the fundamental unit that this code works with.
@@ -46,32 +53,183 @@ type markdownFile struct {
 	IsDateFile bool
 	newData    *bytes.Buffer
 	metadata   map[string]interface{}
-	firstLine  string
+	body       []string
 	scanner    *bufio.Scanner
+	// slug and url are the ones the file's frontmatter gives it, which Hugo publishes
+	// it under.
+	slug string
+	url  string
+	// frontmatter is the format the file's frontmatter was written in, which it's
+	// written back in.
+	frontmatter frontmatterFormat
+	// rawFrontmatter is the YAML frontmatter as it was written, for the metadata to be
+	// merged back into.
+	rawFrontmatter []byte
+	// mentions are the unlinked mentions of this file in others, for
+	// Options.UnlinkedMentions, in the form of backlinks.
+	mentions []backlink
+	// forwardLinks are the files this one links to, in the order they're first linked.
+	forwardLinks []*markdownFile
+	// oldURLs are the URLs the file had before it was renamed, under
+	// Options.RenameAliases.
+	oldURLs []string
+	// bundled are the attachments the file's body links to, which are copied into its
+	// bundle under Options.PageBundles.
+	bundled []string
+	// convertedBody is the body after its links have been converted.
+	convertedBody string
+	// headings holds the ids of the headings in the file, by their slugs.
+	headings map[string]string
+	// blocks holds the ids of the blocks in the file that links can point to.
+	blocks map[string]bool
+	// unreadable is set when the file couldn't be read and is being skipped.
+	unreadable bool
+	// unpublished is set for drafts that are being left out of the output.
+	unpublished bool
+	// unwritten is set for stubs that aren't being written, under Options.Stubs.
+	unwritten bool
+	// generated is set for the pages made up entirely by this tool.
+	generated bool
+	// noBacklinks is set for notes whose frontmatter says `backlinks: false`, which are
+	// linked to like any other but get no backlinks section.
+	noBacklinks bool
+	// dir is the subdirectory of the source directory the file is in, if it isn't at the top.
+	dir string
+	// related are the notes listed in this one's relation sections, and relatedLinks
+	// the notes this one names in its relation keys.
+	related      []relatedNote
+	relatedLinks []*markdownFile
 }
 
-// getFileList retrieves the list of markdown filenames for the source directory.
-func getFileList(sourceDir string) ([]string, error) {
+// getFileList retrieves the list of markdown filenames for the source directory. With
+// Options.Recursive, the filenames in its subdirectories are included too, as paths
+// relative to the source directory.
+func getFileList(sourceDir string, opts *Options) ([]string, error) {
 	result := make([]string, 0)
-	fileInfos, err := ioutil.ReadDir(sourceDir)
+	opts.excluded = make(map[string]bool)
+	err := listDir(sourceDir, "", opts, &result)
 	if err != nil {
 		return nil, err
 	}
+	return result, nil
+}
+
+// listDir adds the markdown files in the directory dir (relative to sourceDir) to result.
+func listDir(sourceDir string, dir string, opts *Options, result *[]string) error {
+	fileInfos, err := ioutil.ReadDir(path.Join(sourceDir, dir))
+	if err != nil {
+		return err
+	}
 	for _, fileInfo := range fileInfos {
-		if path.Ext(fileInfo.Name()) != ".md" {
+		name := path.Join(dir, fileInfo.Name())
+		// Hidden directories hold the settings of editors and tools, not notes
+		if fileInfo.IsDir() && opts.Recursive && !strings.HasPrefix(fileInfo.Name(), ".") {
+			err = listDir(sourceDir, name, opts, result)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if fileInfo.IsDir() || !isMarkdownFile(fileInfo.Name()) {
+			continue
+		}
+		excluded, err := opts.isExcluded(name)
+		if err != nil {
+			return err
+		}
+		if excluded {
+			opts.logf("Excluding %s\n", name)
+			for _, key := range linkKeys(name) {
+				opts.excluded[key] = true
+			}
 			continue
 		}
-		result = append(result, fileInfo.Name())
+		*result = append(*result, name)
 	}
-	return result, nil
+	return nil
+}
+
+// isMarkdownFile reports whether the file has a markdown extension, in any case
+// (notes.md, Notes.MD and notes.Markdown all count).
+func isMarkdownFile(filename string) bool {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// isExcluded reports whether the file is left out by the Include and Exclude patterns.
+func (o *Options) isExcluded(filename string) (bool, error) {
+	if len(o.Include) > 0 {
+		included, err := matchesAny("include", o.Include, filename)
+		if err != nil || !included {
+			return true, err
+		}
+	}
+	return matchesAny("exclude", o.Exclude, filename)
+}
+
+// matchesAny reports whether one of the patterns matches the file, by its path relative
+// to the source directory, by its name alone, or by any of the directories it's in.
+func matchesAny(kind string, patterns []string, filename string) (bool, error) {
+	names := []string{filename, path.Base(filename)}
+	for dir := path.Dir(filename); dir != "."; dir = path.Dir(dir) {
+		names = append(names, dir, path.Base(dir))
+	}
+	for _, pattern := range patterns {
+		// A trailing slash just says the pattern is meant for a directory
+		trimmed := strings.TrimSuffix(pattern, "/")
+		for _, name := range names {
+			matched, err := path.Match(trimmed, name)
+			if err != nil {
+				return false, fmt.Errorf("bad %s pattern %q: %v", kind, pattern, err)
+			}
+			if matched {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// excludeMarkedFiles takes the notes marked `exclude: true` in their frontmatter out of
+// the map, as if they had matched an Exclude pattern.
+func excludeMarkedFiles(fileMap map[string]*markdownFile, filetexts map[*markdownFile][]byte, opts *Options) {
+	for _, file := range sortedFiles(fileMap) {
+		filetext, exists := filetexts[file]
+		if !exists {
+			continue
+		}
+		if exclude, _ := probeFrontmatter(file, filetext)["exclude"].(bool); !exclude {
+			continue
+		}
+		opts.logf("Excluding %s\n", file.relativePath())
+		delete(filetexts, file)
+		for key, other := range fileMap {
+			if other == file {
+				delete(fileMap, key)
+			}
+		}
+		if opts.excluded == nil {
+			opts.excluded = make(map[string]bool)
+		}
+		for _, key := range linkKeys(file.relativePath()) {
+			opts.excluded[key] = true
+		}
+	}
+}
+
+// fileKey is the key a file is stored under in the file map: its lower case name with
+// a .md extension, whatever extension it has on disk, as Normalize produces for links.
+// Only the name counts, not the directory it's in.
+func fileKey(filename string) string {
+	return strings.ToLower(removeExtension(path.Base(filename))) + ".md"
 }
 
 // createMarkdownFile safely creates a markdownFile struct
 func createMarkdownFile(originalFileName string, isNew bool) *markdownFile {
-	isDateFile, err := regexp.MatchString(`\d\d\d\d-\d\d-\d\d.md`, originalFileName)
-	if err != nil {
-		panic(fmt.Sprintf("Error when parsing date regex: %v", err))
-	}
+	_, isDateFile := (&Options{}).filenameDate(originalFileName)
 
 	return &markdownFile{
 		OriginalName: originalFileName,
@@ -84,14 +242,70 @@ func createMarkdownFile(originalFileName string, isNew bool) *markdownFile {
 	}
 }
 
+// relativePath is where the file is, relative to the source directory.
+func (file *markdownFile) relativePath() string {
+	return path.Join(file.dir, file.OriginalName)
+}
+
+// newMarkdownFile is createMarkdownFile for the configured date layouts.
+func (o *Options) newMarkdownFile(originalFileName string, isNew bool) *markdownFile {
+	file := createMarkdownFile(originalFileName, isNew)
+	_, file.IsDateFile = o.filenameDate(originalFileName)
+	return file
+}
+
 // createFileMapping takes a list of filenames (found via getFileList)
-// and returns a map from lower case filename to *markdownFile
-func createFileMapping(files []string) map[string]*markdownFile {
+// and returns a map from lower case filename to *markdownFile. Files in subdirectories
+// are in the map by their name alone, so links find them wherever they are, and by their
+// path (see linkKeys). When several files have the same name, as Obsidian does, the one
+// with the shortest path gets it, and the others need links with a path.
+func createFileMapping(files []string, opts *Options) map[string]*markdownFile {
 	result := make(map[string]*markdownFile)
 	for _, filename := range files {
-		file := createMarkdownFile(filename, false)
-		result[strings.ToLower(filename)] = file
+		file := opts.newMarkdownFile(path.Base(filename), false)
+		if dir := path.Dir(filename); dir != "." {
+			file.dir = dir
+		}
+		for _, key := range linkKeys(filename) {
+			other, exists := result[key]
+			if !exists || pathDepth(filename) < pathDepth(other.relativePath()) {
+				result[key] = file
+			}
+			if exists && key == fileKey(filename) {
+				winner, loser := result[key], file
+				if winner == file {
+					loser = other
+				}
+				opts.warnf(WarnAmbiguousLink, loser.relativePath(), "links to %s mean %s, so links to this note need its path",
+					removeExtension(path.Base(filename)), winner.relativePath())
+			}
+		}
+	}
+	return result
+}
+
+// sortedFiles returns each of the files in the map once (a file can be in the map under
+// several names), ordered by filename, so that every run processes files in the same order.
+func sortedFiles(fileMap map[string]*markdownFile) []*markdownFile {
+	seen := make(map[*markdownFile]bool)
+	result := make([]*markdownFile, 0, len(fileMap))
+	for _, file := range fileMap {
+		if !seen[file] {
+			seen[file] = true
+			result = append(result, file)
+		}
 	}
+	sort.Slice(result, func(i, j int) bool {
+		nameI := strings.ToLower(result[i].OriginalName)
+		nameJ := strings.ToLower(result[j].OriginalName)
+		if nameI != nameJ {
+			return nameI < nameJ
+		}
+		if result[i].OriginalName != result[j].OriginalName {
+			return result[i].OriginalName < result[j].OriginalName
+		}
+		return result[i].relativePath() < result[j].relativePath()
+	})
 	return result
 }
 
@@ -101,86 +315,381 @@ func createFileMapping(files []string) map[string]*markdownFile {
 type backlinkCollector struct {
 	currentFile *markdownFile
 	fileMap     map[string]*markdownFile
+	opts        *Options
+	// seen counts the links found so far for each context and link text, so that
+	// repeated links within the same context can be told apart.
+	seen map[string]int
 }
 
 // LinkWithContext fulfills the goldmark-wikilinks tracker interface to keep track
 // of each wiki-style link that's discovered.
 func (blc backlinkCollector) LinkWithContext(destText string, destFilename string, context string) {
-	destFile, exists := blc.fileMap[destFilename]
+	destName, anchor := splitAnchor(destText)
+	_, label := splitLabel(destText)
+	if isEmptyLink(destText) {
+		if blc.opts.EmptyLinks == EmptyLinksError {
+			blc.opts.warnf(WarnEmptyLink, blc.currentFile.OriginalName, "[[%s]] doesn't name a note", destText)
+		}
+		return
+	}
+	// A link within the note isn't a link to another note
+	if isInPageLink(destText) {
+		return
+	}
+	if found, isAttachment := blc.opts.attachment(destName); isAttachment {
+		if found == "" {
+			blc.opts.warnf(WarnDanglingLink, blc.currentFile.OriginalName, "[[%s]] doesn't match any attachment", destText)
+		} else if blc.opts.usedAttachments != nil {
+			blc.opts.usedAttachments[found] = true
+		}
+		return
+	}
+	destFile, exists := blc.opts.resolveLink(blc.fileMap, destFilename)
+	if !exists && blc.opts.excluded[destFilename] {
+		return
+	}
 	if !exists {
-		destFile = createMarkdownFile(destText+".md", true)
+		destFile = blc.opts.newStub(destName)
 		blc.fileMap[destFilename] = destFile
 	}
+	if destFile == blc.currentFile && blc.opts.SkipSelfBacklinks {
+		return
+	}
+	if destFile.IsNew {
+		blc.opts.warnf(WarnDanglingLink, blc.currentFile.OriginalName, "[[%s]] doesn't match any file", destText)
+	}
+	blc.currentFile.addForwardLink(destFile)
 	destFile.BackLinks = append(destFile.BackLinks, backlink{
 		OtherFile: blc.currentFile,
 		Context:   context,
+		Offset:    blc.linkOffset(destText, context),
+		Anchor:    anchor,
+		Label:     label,
 	})
 }
 
-// Normalize fulfills the goldmark-wikilinks file normalizer interface to make sure links
+// addForwardLink records that the file links to other, once per file linked to.
+func (file *markdownFile) addForwardLink(other *markdownFile) {
+	for _, existing := range file.forwardLinks {
+		if existing == other {
+			return
+		}
+	}
+	file.forwardLinks = append(file.forwardLinks, other)
+}
+
+// linkOffset finds where the link is within its context. The links within a context are
+// reported in order, so the nth report of the same link text is its nth occurrence.
+func (blc backlinkCollector) linkOffset(destText string, context string) int {
+	key := context + "\x00" + destText
+	occurrence := blc.seen[key]
+	blc.seen[key] = occurrence + 1
+	link := "[[" + destText + "]]"
+	offset := 0
+	for i := 0; i <= occurrence; i++ {
+		index := strings.Index(context[offset:], link)
+		if index < 0 {
+			return -1
+		}
+		if i < occurrence {
+			offset += index + len(link)
+		} else {
+			offset += index
+		}
+	}
+	return offset
+}
+
+// Normalize is the wikilink extension's Resolver, used to make sure links
 // can point to the correct file, regardless of how the link is written. File lookups in
 // this code are all done with a lower case name.
 func (blc backlinkCollector) Normalize(linkText string) string {
-	return strings.ToLower(linkText) + ".md"
+	name, _ := splitAnchor(linkText)
+	return strings.ToLower(strings.TrimPrefix(name, "/")) + ".md"
 }
 
 // collectBacklinksForFile parses the file with Goldmark and tracks all of the links found
 // in order to accumulate the backlinks.
 // Goldmark isn't used for generating HTML (Hugo does that), but I need to use a proper
 // parser in order to be able to get the context of each link that's discovered.
-func collectBacklinksForFile(fileMap map[string]*markdownFile, currentFile *markdownFile, filetext []byte) {
+func collectBacklinksForFile(fileMap map[string]*markdownFile, currentFile *markdownFile, filetext []byte,
+	opts *Options) {
+	addLinks(fileMap, currentFile, findLinks(noteBody(filetext), opts), opts)
+}
+
+// addLinks tracks the links found in a file.
+func addLinks(fileMap map[string]*markdownFile, currentFile *markdownFile, links []foundLink, opts *Options) {
 	blc := backlinkCollector{
 		currentFile: currentFile,
 		fileMap:     fileMap,
+		opts:        opts,
+		seen:        make(map[string]int),
+	}
+	for _, link := range links {
+		linkText := link.Text
+		if link.Markdown {
+			var isNote bool
+			linkText, isNote = markdownLinkText(fileMap, currentFile, link)
+			if !isNote {
+				continue
+			}
+		}
+		blc.LinkWithContext(linkText, blc.Normalize(linkText), link.Context)
 	}
+}
 
-	wl := wikilinks.NewWikilinksParser().WithTracker(blc).WithNormalizer(blc)
-	md := goldmark.New(
-		goldmark.WithParserOptions(
-			parser.WithInlineParsers(util.Prioritized(wl, 102)),
-		),
-	)
-	reader := text.NewReader(filetext)
-	md.Parser().Parse(reader)
+// markdownLinkText returns the wikilink a markdown link found in the file (which is
+// relative to the directory the file is in) amounts to, and whether it's a link to one
+// of the notes at all.
+func markdownLinkText(fileMap map[string]*markdownFile, currentFile *markdownFile, link foundLink) (string, bool) {
+	name, anchor := splitAnchor(link.Text)
+	target := path.Join(currentFile.dir, name)
+	if strings.HasPrefix(target, "../") {
+		return "", false
+	}
+	target = removeExtension(target)
+	if file, exists := fileMap[strings.ToLower(target)+".md"]; !exists || file.IsNew {
+		return "", false
+	}
+	if anchor != "" {
+		target += "#" + anchor
+	}
+	if link.Label != "" {
+		target += "|" + link.Label
+	}
+	return target, true
 }
 
 // collectBacklinks loops through all of the files in the directory, parses each one,
 // and gathers the backlinks from that parsing.
-func collectBacklinks(sourceDir string, fileMap map[string]*markdownFile) error {
-	for _, file := range fileMap {
+func collectBacklinks(sourceDir string, fileMap map[string]*markdownFile, opts *Options) error {
+	files := sortedFiles(fileMap)
+	texts, errs := readSourceFiles(sourceDir, files, opts)
+	filetexts := make(map[*markdownFile][]byte)
+	for i, file := range files {
 		if file.IsNew {
 			continue
 		}
-		filename := path.Join(sourceDir, file.OriginalName)
-		log.Printf("Collecting backlinks from %s\n", filename)
-		filetext, err := ioutil.ReadFile(filename)
+		if errs[i] != nil {
+			if skipErr := skipUnreadable(file, errs[i], opts); skipErr != nil {
+				return skipErr
+			}
+			continue
+		}
+		filetext := stripMarkedText(texts[i], opts)
+		filetexts[file] = filetext
+		collectHeadings(file, noteBody(filetext))
+		// Links to the file need its URL before its frontmatter is properly read
+		file.setURL(probeFrontmatter(file, filetext), opts)
+	}
+	excludeMarkedFiles(fileMap, filetexts, opts)
+	if opts.RenameAliases {
+		err := trackRenames(filetexts, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Aliases and titles need to be known before any links are resolved
+	if opts.AliasKey != "" || opts.LinkTitles != LinkTitlesOff {
+		err := registerLinkNames(sourceDir, fileMap, filetexts, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.inPlace {
+		for file, filetext := range filetexts {
+			filetexts[file] = restoreWikilinks(filetext, fileMap, opts)
+			collectHeadings(file, noteBody(filetexts[file]))
+		}
+	}
+
+	if opts.Incremental {
+		err := loadLinkState(opts)
+		if err != nil {
+			return err
+		}
+	}
+	// Parsing is the slow part, so files are parsed several at once, but their links are
+	// added in order so that the backlinks come out the same every time
+	links := make([][]foundLink, len(files))
+	reused := make([]bool, len(files))
+	opts.inParallel(len(files), func(i int) {
+		filetext, exists := filetexts[files[i]]
+		if !exists {
+			return
+		}
+		links[i], reused[i] = cachedLinks(files[i], filetext, opts)
+		if !reused[i] {
+			links[i] = findLinks(noteBody(filetext), opts)
+		}
+	})
+	for i, file := range files {
+		filetext, exists := filetexts[file]
+		if !exists {
+			continue
+		}
+		if reused[i] {
+			opts.logf("Reusing the links found in %s\n", file.relativePath())
+		} else {
+			opts.logf("Collecting backlinks from %s\n", path.Join(sourceDir, file.relativePath()))
+			rememberLinks(file, filetext, links[i], opts)
+		}
+		addLinks(fileMap, file, links[i], opts)
+	}
+	if opts.UnlinkedMentions {
+		collectMentions(files, filetexts, fileMap, opts)
+	}
+	if opts.linkState != nil {
+		err := saveLinkState(filetexts, opts)
 		if err != nil {
 			return err
 		}
-		collectBacklinksForFile(fileMap, file, filetext)
+	}
+
+	if opts.CheckAnchors {
+		checkAnchors(fileMap, opts)
 	}
 	return nil
 }
 
+// registerLinkNames registers the aliases and titles of all of the files that were read,
+// all of the aliases first so that they win over titles.
+func registerLinkNames(sourceDir string, fileMap map[string]*markdownFile, filetexts map[*markdownFile][]byte,
+	opts *Options) error {
+	var index *resolutionIndex
+	if opts.ResolutionIndex {
+		var err error
+		index, err = loadResolutionIndex(opts)
+		if err != nil {
+			return err
+		}
+	}
+	names := make(map[*markdownFile]linkNames)
+	for _, file := range sortedFiles(fileMap) {
+		if filetext, exists := filetexts[file]; exists {
+			names[file] = readLinkNames(path.Join(sourceDir, file.relativePath()), file, filetext, index, opts)
+		}
+	}
+	if opts.AliasKey != "" {
+		for _, file := range sortedFiles(fileMap) {
+			if fileNames, exists := names[file]; exists {
+				registerAliases(fileMap, file, fileNames.Aliases, opts)
+			}
+		}
+	}
+	if opts.LinkTitles != LinkTitlesOff {
+		for _, file := range sortedFiles(fileMap) {
+			if fileNames, exists := names[file]; exists {
+				registerTitle(fileMap, file, fileNames.Title, opts)
+			}
+		}
+	}
+	if index == nil {
+		return nil
+	}
+	for name := range index.Files {
+		if _, exists := fileMap[fileKey(name)]; !exists {
+			delete(index.Files, name)
+		}
+	}
+	return opts.cache.write(resolutionIndexFile, index)
+}
+
+// registerAliases adds the file to the map under each of the alternate names listed in
+// its frontmatter, so that links can use those names too. A real filename always wins
+// over an alias.
+func registerAliases(fileMap map[string]*markdownFile, file *markdownFile, aliases []string, opts *Options) {
+	for _, alias := range aliases {
+		key := backlinkCollector{}.Normalize(alias)
+		other, exists := fileMap[key]
+		if exists && other != file {
+			opts.warnf(WarnAmbiguousLink, file.OriginalName, "alias %s is already used by %s", alias, other.OriginalName)
+			continue
+		}
+		fileMap[key] = file
+	}
+}
+
+// registerTitle makes the file's frontmatter title a name it can be linked by, under
+// Options.LinkTitles. The first file (by filename) with a given title gets it.
+func registerTitle(fileMap map[string]*markdownFile, file *markdownFile, title string, opts *Options) {
+	if title == "" {
+		return
+	}
+	key := backlinkCollector{}.Normalize(title)
+	if fileMap[key] == file {
+		return
+	}
+	if other, exists := opts.titles[key]; exists {
+		opts.warnf(WarnAmbiguousLink, file.OriginalName, "title %s is already used by %s", title, other.OriginalName)
+		return
+	}
+	if opts.titles == nil {
+		opts.titles = make(map[string]*markdownFile)
+	}
+	opts.titles[key] = file
+}
+
+// resolveLink finds the file a normalized link name refers to: the file of that name
+// or, under Options.LinkTitles, the file with that title, in the configured order.
+func (o *Options) resolveLink(fileMap map[string]*markdownFile, key string) (*markdownFile, bool) {
+	if titled, exists := o.titles[key]; exists && o.LinkTitles == LinkTitlesFirst {
+		return titled, true
+	}
+	if file, exists := fileMap[key]; exists {
+		return file, true
+	}
+	titled, exists := o.titles[key]
+	return titled, exists
+}
+
+// probeFrontmatter returns the metadata of a file that hasn't been read yet.
+func probeFrontmatter(file *markdownFile, filetext []byte) map[string]interface{} {
+	// This is a throwaway copy: the frontmatter is properly extracted (and any problems
+	// reported) when the file is generated.
+	probe := markdownFile{OriginalName: file.OriginalName}
+	scanner := bufio.NewScanner(bytes.NewReader(filetext))
+	if extractFrontmatter(&probe, scanner, &Options{}) != nil {
+		return nil
+	}
+	return probe.metadata
+}
+
 // extractFrontmatter reads the frontmatter from the file and adds it as the metadata property on
-// the `file` struct. It returns the first line of the file, in case there is no frontmatter.
-func extractFrontmatter(file *markdownFile, scanner *bufio.Scanner) error {
+// the `file` struct. When there is no frontmatter, the line it had to read to find that out
+// becomes the start of the body.
+func extractFrontmatter(file *markdownFile, scanner *bufio.Scanner, opts *Options) error {
 	var front bytes.Buffer
 	first := true
 	noMeta := false
 	foundEnd := false
+	format := yamlFrontmatter
 	var line string
 	for scanner.Scan() {
 		line = scanner.Text()
 		if first {
 			first = false
-			if line != "---" {
+			format = frontmatterFormatOf(line)
+			if format == noFrontmatter {
 				noMeta = true
 				break
 			}
+			// A JSON object is all frontmatter, with no fences around it
+			if format != jsonFrontmatter {
+				continue
+			}
+		}
+		if format == jsonFrontmatter {
+			front.WriteString(line + "\n")
+			if json.Valid(front.Bytes()) {
+				foundEnd = true
+				break
+			}
 			continue
 		}
-		if line == "---" {
+		if line == format.fence() {
 			foundEnd = true
 			break
 		}
@@ -194,25 +703,102 @@ func extractFrontmatter(file *markdownFile, scanner *bufio.Scanner) error {
 		return errors.New("no end tag found in frontmatter")
 	}
 	meta := make(map[string]interface{})
+	file.frontmatter = yamlFrontmatter
+	file.rawFrontmatter = nil
 	if !noMeta {
-		err = yaml.Unmarshal(front.Bytes(), meta)
+		file.frontmatter = format
+		err = format.unmarshal(front.Bytes(), meta)
 		if err != nil {
 			return err
 		}
 	}
+	if file.frontmatter == yamlFrontmatter && !noMeta {
+		warnDuplicateKeys(file, front.Bytes(), opts)
+		file.rawFrontmatter = front.Bytes()
+	}
 	file.metadata = meta
-	if !noMeta {
-		line = ""
+	file.setURL(meta, opts)
+	file.body = nil
+	if noMeta {
+		file.body = []string{line}
+	}
+	return nil
+}
+
+// setURL remembers the slug and url the file's frontmatter gives it, if it names either.
+func (file *markdownFile) setURL(meta map[string]interface{}, opts *Options) {
+	file.slug, _ = meta["slug"].(string)
+	file.url, _ = meta[opts.urlKey()].(string)
+}
+
+// bufferBody reads the rest of the file (everything after the frontmatter) into memory so
+// that the body can be inspected before the new frontmatter is written.
+func bufferBody(file *markdownFile, opts *Options) error {
+	for file.scanner.Scan() {
+		file.body = append(file.body, file.scanner.Text())
+	}
+	err := file.scanner.Err()
+	if err != nil {
+		return err
 	}
-	file.firstLine = line
+	// What an earlier run generated is generated afresh
+	file.body = dropUnmarkedSections(collapseMarkedSections(file.body), opts)
+	file.resetScanner()
 	return nil
 }
 
+// resetScanner points the file's scanner at the start of its buffered body.
+func (file *markdownFile) resetScanner() {
+	var rest strings.Builder
+	for _, line := range file.body {
+		rest.WriteString(line + "\n")
+	}
+	file.scanner = bufio.NewScanner(strings.NewReader(rest.String()))
+}
+
+// countWords returns the number of whitespace-separated words in the file's own body.
+func countWords(file *markdownFile, opts *Options) int {
+	count := 0
+	for _, line := range bodyWithoutGeneratedSections(file, opts) {
+		count += len(strings.Fields(line))
+	}
+	return count
+}
+
+// readingTime estimates the minutes needed to read the given number of words,
+// rounded to the nearest minute. Any text at all takes at least a minute.
+func readingTime(words int, wordsPerMinute int) int {
+	if words == 0 {
+		return 0
+	}
+	minutes := int(math.Round(float64(words) / float64(wordsPerMinute)))
+	if minutes < 1 {
+		minutes = 1
+	}
+	return minutes
+}
+
+// warnDuplicateKeys reports top level frontmatter keys that are given more than once.
+// The YAML parser quietly keeps the last value, which is rarely what was intended.
+func warnDuplicateKeys(file *markdownFile, front []byte, opts *Options) {
+	var items yaml.MapSlice
+	if yaml.Unmarshal(front, &items) != nil {
+		return
+	}
+	seen := make(map[interface{}]bool)
+	for _, item := range items {
+		if seen[item.Key] {
+			opts.warnf(WarnDuplicateKey, file.OriginalName, "frontmatter key %v appears more than once", item.Key)
+		}
+		seen[item.Key] = true
+	}
+}
+
 // adjustFrontmatter will parse the frontmatter block (if present) and gather the YAML
 // metadata. It pulls out the title and applies it to the *markdownFile.
 // If the file being processed has a filename that's just a date, that date is inserted into
 // the frontmatter.
-func adjustFrontmatter(file *markdownFile, writer io.Writer) error {
+func adjustFrontmatter(file *markdownFile, opts *Options, writer io.Writer) error {
 	meta := file.metadata
 	plainFilename := removeExtension(file.OriginalName)
 	if file.IsDateFile {
@@ -222,21 +808,20 @@ func adjustFrontmatter(file *markdownFile, writer io.Writer) error {
 		}
 		_, hasDate := meta["date"]
 		if !hasDate {
-			datetime, err := time.Parse(time.RFC3339, plainFilename+"T08:00:00-05:00")
-			if err != nil {
-				return err
-			}
-			meta["date"] = datetime
+			meta["date"], _ = opts.filenameDate(file.OriginalName)
 		}
 	}
 
-	title, hasTitle := meta["title"]
-	if hasTitle {
-		file.Title = title.(string)
-	} else {
+	if title, hasTitle := metadataTitle(meta); hasTitle {
+		file.Title = title
+	} else if _, exists := meta["title"]; !exists {
 		meta["title"] = file.Title
 	}
 
+	if date, ok := gitDate(file, opts); ok && meta["date"] == nil && opts.GitDate == GitDateFirst {
+		meta["date"] = date
+	}
+
 	if meta["date"] == nil {
 		var latest time.Time
 		for _, backlink := range file.BackLinks {
@@ -244,9 +829,9 @@ func adjustFrontmatter(file *markdownFile, writer io.Writer) error {
 			if !hasDate {
 				continue
 			}
-			otherDate, ok := otherDateInt.(time.Time)
+			otherDate, ok := opts.metadataDate(backlink.OtherFile)
 			if !ok {
-				log.Printf("[time.Parse] probable invalid date format %s", plainFilename)
+				opts.warnf(WarnBadDate, backlink.OtherFile.OriginalName, "probable invalid date format %v", otherDateInt)
 			}
 			if otherDate.After(latest) {
 				latest = otherDate
@@ -257,15 +842,45 @@ func adjustFrontmatter(file *markdownFile, writer io.Writer) error {
 		}
 	}
 
-	updatedMeta, err := yaml.Marshal(meta)
-	if err != nil {
-		return err
+	if date, ok := gitDate(file, opts); ok && meta["date"] == nil {
+		meta["date"] = date
 	}
-	_,_ = writer.Write([]byte("---\n"))
-	_,_ = writer.Write(updatedMeta)
-	_,_ = writer.Write([]byte("---\n"))
 
-	return nil
+	if opts.ReadingTime && !file.IsNew {
+		words := countWords(file, opts)
+		meta[opts.wordCountKey()] = words
+		meta[opts.readingTimeKey()] = readingTime(words, opts.wordsPerMinute())
+	}
+
+	if opts.Summary && !file.IsNew {
+		_, hasSummary := meta[opts.summaryKey()]
+		summary := extractSummary(file, opts)
+		if !hasSummary && summary != "" {
+			meta[opts.summaryKey()] = summary
+		}
+	}
+
+	applyDefaultAuthor(file, opts)
+	applyDefaultFrontmatter(file, opts)
+	applyGitLastmod(file, opts)
+	applyRenameAliases(file, opts)
+	applyPermalink(file, opts)
+	normalizeTags(file, opts)
+
+	if opts.ContentHash && !file.IsNew {
+		meta[opts.contentHashKey()] = contentHash(file, opts)
+	}
+
+	// The backlinks: false of a note that opts out is kept, rather than replaced by them
+	if opts.BacklinkParams && !file.noBacklinks {
+		params := backlinkParams(file, opts)
+		if len(params) > 0 {
+			meta[opts.backlinkParamsKey()] = params
+		}
+	}
+
+	// Frontmatter is written back in the format it was read in
+	return opts.outputFormat(file).write(writer, opts.targetMetadata(opts.outputMetadata(file)), file.rawFrontmatter)
 }
 
 // removeExtension is a simple utility that safely trims the extension from the filename
@@ -283,155 +898,640 @@ func createHugoLink(filename string) string {
 	return "./" + name + "/"
 }
 
-// convertLinksOnLine does a simple regex-based replacement of wikilinks on a single line
-// of markdown text. Each wikilink is replaced by a standard markdown link.
-func convertLinksOnLine(line string, fileMap map[string]*markdownFile) string {
-	replacer := func(s string) string {
-		linkText := s[2 : len(s)-2]
+// outputDir is the directory, relative to the destination, where file is written: the
+// directory it's in under the source, inside the section Options.Sections gives it.
+func (o *Options) outputDir(file *markdownFile) string {
+	if file.IsNew && o.StubDir != "" {
+		return strings.Trim(path.Clean(o.StubDir), "/")
+	}
+	if o.inPlace {
+		return file.dir
+	}
+	return path.Join(o.Sections.sectionFor(file), file.dir)
+}
 
-		expectedMappingName := strings.ToLower(linkText) + ".md"
-		file, exists := fileMap[expectedMappingName]
-		if !exists {
-			file = createMarkdownFile(linkText+".md", true)
-			fileMap[expectedMappingName] = file
-		}
-		linkTo := createHugoLink(file.OriginalName)
-		return fmt.Sprintf("[%s](%s)", linkText, linkTo)
+// outputPath is the path, relative to the destination, where file is written: beside
+// the other notes or, under Options.PageBundles, as the index of a bundle of its own.
+func (o *Options) outputPath(file *markdownFile) string {
+	if o.target() == TargetJekyll && file.IsDateFile && !o.inPlace {
+		return path.Join(o.outputDir(file), "_posts", o.jekyllPostName(file))
 	}
-	re := regexp.MustCompile(`\[\[[^\]]+\]\]`)
-	return re.ReplaceAllStringFunc(line, replacer)
+	if o.PageBundles && (o.target() == TargetHugo || o.target() == TargetZola) && !o.inPlace {
+		return path.Join(o.outputDir(file), removeExtension(file.OriginalName), "index.md")
+	}
+	return path.Join(o.outputDir(file), file.OriginalName)
 }
 
-// convertLinks consumes the file through the scanner, replacing all of the wikilinks in
-// the file with the proper markdown links.
-func convertLinks(firstLine string, scanner *bufio.Scanner, fileMap map[string]*markdownFile,
-	writer io.Writer) error {
-	if firstLine != "" {
-		updatedLine := convertLinksOnLine(firstLine, fileMap) + "\n"
-		_, err := writer.Write([]byte(updatedLine))
-		if err != nil {
-			return err
-		}
+// linkTo returns the URL that other pages should use to link to file. A slug in the
+// file's frontmatter takes the place of its name, and a url the place of the whole of
+// it, as they do for Hugo. The permalinks and uglyURLs of the Hugo site configuration,
+// under Options.HugoConfig, are followed too.
+func (o *Options) linkTo(file *markdownFile) string {
+	if file.url != "" {
+		return o.withBasePath("./" + strings.TrimPrefix(file.url, "/"))
 	}
-	for scanner.Scan() {
-		line := scanner.Text()
-		updatedLine := convertLinksOnLine(line, fileMap) + "\n"
-		_, err := writer.Write([]byte(updatedLine))
-		if err != nil {
-			return err
-		}
+	switch o.target() {
+	case TargetJekyll:
+		return o.withBasePath(o.jekyllLink(file))
+	case TargetEleventy:
+		return o.withBasePath(o.eleventyLink(file))
 	}
-	err := scanner.Err()
-	if err != nil {
-		return err
+	if sitePath := o.sitePath(file); sitePath != "" {
+		return o.withBasePath(o.uglyLink("./" + strings.TrimPrefix(sitePath, "/")))
 	}
-	return nil
+	link := createHugoLink(file.OriginalName)
+	if file.slug != "" {
+		link = "./" + strings.ToLower(strings.ReplaceAll(strings.Trim(file.slug, "/"), " ", "-")) + "/"
+	}
+	if dir := o.outputDir(file); dir != "" {
+		link = "./" + strings.ToLower(strings.ReplaceAll(dir, " ", "-")) + strings.TrimPrefix(link, ".")
+	}
+	return o.withBasePath(o.uglyLink(link))
 }
 
-// addBacklinks tacks additional markdown onto the file with the collection of backlink
-// references.
-func addBacklinks(file *markdownFile, fileMap map[string]*markdownFile, writer io.Writer) error {
-	if len(file.BackLinks) == 0 {
-		return nil
+// pageLink returns the link that a note's markdown should use for file, and the anchor
+// in it when fragment isn't empty. It's the shortcode or tag Options.LinkStyle names, so
+// that the site generator checks the link, or for TargetZola the path Zola resolves, and
+// otherwise the file's URL. Notes rewritten in place always use the URL, which is what
+// they're read back from.
+func (o *Options) pageLink(file *markdownFile, fragment string) string {
+	if fragment != "" {
+		fragment = "#" + fragment
 	}
-	_,_ = writer.Write([]byte(`
-## Backlinks
+	if o.target() == TargetZola && !o.inPlace {
+		return o.zolaLink(file, fragment)
+	}
+	if o.LinkStyle == LinkStyleURL || o.inPlace {
+		return o.linkTo(file) + fragment
+	}
+	if o.LinkStyle == LinkStyleJekyll {
+		return "{% link " + o.outputPath(file) + " %}" + fragment
+	}
+	return fmt.Sprintf("{{< %s %q >}}", o.LinkStyle, "/"+o.outputPath(file)+fragment)
+}
 
-`))
-	sort.Slice(file.BackLinks, func(i, j int) bool {
-		bl1 := file.BackLinks[i]
-		bl2 := file.BackLinks[j]
+// withBasePath makes a link relative to the root of the site (./page/) root-relative under
+// Options.BasePath, when there is one.
+func (o *Options) withBasePath(link string) string {
+	if o.BasePath == "" {
+		return link
+	}
+	base := o.BasePath
+	if parsed, err := url.Parse(base); err == nil && parsed.Host != "" {
+		base = parsed.Path
+	}
+	base = "/" + strings.Trim(base, "/") + "/"
+	if base == "//" {
+		base = "/"
+	}
+	return base + strings.TrimPrefix(link, "./")
+}
 
-		dateField1, hasDateField1 := bl1.OtherFile.metadata["date"]
-		dateField2, hasDateField2 := bl2.OtherFile.metadata["date"]
+var (
+	lineLinks     = regexp.MustCompile(`!?\[\[[^\]]*\]\]`)
+	lineLinksRoam = regexp.MustCompile(`[!#]?\[\[[^\]]*\]\]|#\pL[\pL\pN_/-]*`)
+)
 
-		if hasDateField1 && !hasDateField2 {
-			return true
-		} else if !hasDateField1 && hasDateField2 {
-			return false
+// convertLinksOnLine does a simple regex-based replacement of wikilinks in a snippet of
+// markdown text, such as the context of a backlink. Each wikilink is replaced by a
+// standard markdown link, except inside inline code spans, which are left as they are.
+// Under LinkSyntaxRoam, tags are converted as they are in the note. Whole notes are
+// converted by convertText instead.
+func convertLinksOnLine(line string, fileMap map[string]*markdownFile, opts *Options) string {
+	re := lineLinks
+	if opts.LinkSyntax == LinkSyntaxRoam {
+		re = lineLinksRoam
+	}
+	spans := codeSpans(line)
+	var result strings.Builder
+	last := 0
+	for _, match := range re.FindAllStringIndex(line, -1) {
+		if insideSpan(match, spans) {
+			continue
 		}
+		start, link := match[0], line[match[0]:match[1]]
+		isTag := strings.HasPrefix(link, "#") && isTagStart(line[:start])
+		// A # that can't start a tag is just text, though a wikilink after it is still one
+		if strings.HasPrefix(link, "#") && !isTag {
+			if !strings.HasPrefix(link, "#[[") {
+				continue
+			}
+			start, link = start+1, link[1:]
+		}
+		result.WriteString(line[last:start])
+		if isTag {
+			linkText := strings.TrimSuffix(strings.TrimPrefix(link[1:], "[["), "]]")
+			result.WriteString(convertTag(link, linkText, fileMap, opts))
+		} else {
+			result.WriteString(convertLink(link, fileMap, opts))
+		}
+		last = match[1]
+	}
+	result.WriteString(line[last:])
+	return result.String()
+}
 
-		if hasDateField1 && hasDateField2 {
-			date1 := dateField1.(time.Time)
-			date2 := dateField2.(time.Time)
-			return date1.After(date2)
+// convertText replaces the wikilinks in the text of a note with markdown links. The text
+// is parsed to find them, so that links in code blocks and code spans are left as they
+// are. embedding is the chain of notes being embedded, as for expandEmbed.
+func convertText(body string, fileMap map[string]*markdownFile, embedding []*markdownFile, opts *Options) string {
+	var result strings.Builder
+	last := 0
+	for _, link := range findLinks([]byte(body), opts) {
+		if link.Markdown {
+			continue
+		}
+		result.WriteString(body[last:link.start])
+		if expanded, ok := expandEmbed(link, fileMap, embedding, opts); ok {
+			result.WriteString(expanded)
+		} else if link.tag {
+			result.WriteString(convertTag(body[link.start:link.stop], link.Text, fileMap, opts))
+		} else {
+			result.WriteString(convertLink(body[link.start:link.stop], fileMap, opts))
 		}
+		last = link.stop
+	}
+	result.WriteString(body[last:])
+	return result.String()
+}
 
-		return strings.Compare(bl1.OtherFile.Title, bl2.OtherFile.Title) < 0
-	})
+// isTagStart is true when a tag can start after the text before it, as the wikilink
+// extension finds them: at the start of a line, or after a space or an opening bracket.
+func isTagStart(before string) bool {
+	previous, _ := utf8.DecodeLastRuneInString(before)
+	return before == "" || unicode.IsSpace(previous) || strings.ContainsRune("([", previous)
+}
+
+// convertTag converts a tag (under LinkSyntaxRoam) to a link labelled with the tag, as
+// it was written but without the brackets of one like #[[page]].
+func convertTag(tag string, linkText string, fileMap map[string]*markdownFile, opts *Options) string {
+	if opts.inPlace {
+		return tag
+	}
+	target, label := splitLabel(linkText)
+	if label == "" {
+		label = target
+	}
+	return convertLink("[["+target+"|#"+label+"]]", fileMap, opts)
+}
+
+// convertLink converts a single wikilink, or embed: [[page]] or ![[page]]. Embeds that
+// are still here become links, except in notes rewritten in place, where they're kept.
+func convertLink(s string, fileMap map[string]*markdownFile, opts *Options) string {
+	embedded := strings.HasPrefix(s, "!")
+	linkText := strings.TrimPrefix(s, "!")
+	linkText = linkText[2 : len(linkText)-2]
+	name, anchor := splitAnchor(linkText)
+	if found, isAttachment := opts.attachment(name); isAttachment && !isEmptyLink(linkText) {
+		return attachmentLink(linkText, found, embedded, opts)
+	}
+	if embedded && opts.inPlace {
+		return s
+	}
+	if isEmptyLink(linkText) {
+		if opts.EmptyLinks == EmptyLinksDrop {
+			return ""
+		}
+		return strings.TrimPrefix(s, "!")
+	}
+	if isInPageLink(linkText) {
+		return inPageLink(linkText, opts)
+	}
+
+	expectedMappingName := backlinkCollector{}.Normalize(linkText)
+	file, exists := opts.resolveLink(fileMap, expectedMappingName)
+	if !exists && opts.excluded[expectedMappingName] {
+		return linkLabel(linkText)
+	}
+	// Every stub that's made was found when the links were collected
+	if !exists && (opts.Stubs != StubsCreate || opts.StubMinBacklinks > 0) {
+		return opts.danglingLink(s, linkText)
+	}
+	if !exists {
+		file = opts.newStub(name)
+		fileMap[expectedMappingName] = file
+	}
+	if file.unpublished && opts.DraftLinks != DraftLinksKeep {
+		return linkLabel(linkText)
+	}
+	if file.unwritten {
+		return opts.danglingLink(s, linkText)
+	}
+	var fragment string
+	if block, isBlock := blockRef(anchor); isBlock {
+		if opts.BlockAnchors {
+			fragment = block
+		}
+	} else if anchor != "" {
+		fragment = file.anchorID(anchor)
+	}
+	return fmt.Sprintf("[%s](%s)", linkLabel(linkText), opts.pageLink(file, fragment))
+}
+
+// codeSpans finds the inline code spans on a line. A span opens with a run of backticks
+// and closes at the next run of the same length; a run that's never closed is just text.
+func codeSpans(line string) [][2]int {
+	var spans [][2]int
+	runs := regexp.MustCompile("`+").FindAllStringIndex(line, -1)
+	for i := 0; i < len(runs); i++ {
+		length := runs[i][1] - runs[i][0]
+		for j := i + 1; j < len(runs); j++ {
+			if runs[j][1]-runs[j][0] == length {
+				spans = append(spans, [2]int{runs[i][0], runs[j][1]})
+				i = j
+				break
+			}
+		}
+	}
+	return spans
+}
+
+// insideSpan is true when the match overlaps any of the spans.
+func insideSpan(match []int, spans [][2]int) bool {
+	for _, span := range spans {
+		if match[0] < span[1] && match[1] > span[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// convertLinks consumes the file through its scanner, replacing all of the wikilinks in
+// the file with the proper markdown links. The file starts the chain of notes being
+// embedded, so that it's only linked to when it embeds itself.
+func convertLinks(file *markdownFile, fileMap map[string]*markdownFile, opts *Options,
+	writer io.Writer) error {
+	scanner := file.scanner
+	anchorer := newHeadingAnchorer(opts.HeadingAnchors)
+	blocks := blockAnchorer{}
+	var body strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if opts.HeadingAnchors != HeadingAnchorsOff {
+			line = anchorer.anchor(line)
+		}
+		if opts.BlockAnchors {
+			line = blocks.anchor(line)
+		}
+		body.WriteString(line + "\n")
+	}
+	err := scanner.Err()
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write([]byte(convertText(body.String(), fileMap, []*markdownFile{file}, opts)))
+	return err
+}
+
+// generateSections returns the sections added to the end of a note, in order.
+func generateSections(file *markdownFile, fileMap map[string]*markdownFile, opts *Options) ([]generatedSection, error) {
+	var backlinks, outgoing, mentions, indirect, related bytes.Buffer
+	if (!file.IsDateFile || !opts.SkipDateFileBacklinks) && !file.noBacklinks {
+		err := addBacklinks(file, fileMap, opts, &backlinks)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := addOutgoingLinks(file, opts, &outgoing)
+	if err != nil {
+		return nil, err
+	}
+	err = addUnlinkedMentions(file, fileMap, opts, &mentions)
+	if err != nil {
+		return nil, err
+	}
+	err = addIndirectBacklinks(file, opts, &indirect)
+	if err != nil {
+		return nil, err
+	}
+	err = addRelatedNotes(file, opts, &related)
+	if err != nil {
+		return nil, err
+	}
+	sections := []generatedSection{
+		{Name: "backlinks", Content: backlinks.String()},
+		{Name: "outgoing-links", Content: outgoing.String()},
+		{Name: "unlinked-mentions", Content: mentions.String()},
+		{Name: "indirect-backlinks", Content: indirect.String()},
+		{Name: "related-notes", Content: related.String()},
+	}
+	return append(sections, relationSections(file, opts)...), nil
+}
 
-	for _, backlink := range file.BackLinks {
-		title := backlink.OtherFile.Title
-		link := createHugoLink(backlink.OtherFile.OriginalName)
-		context := convertLinksOnLine(backlink.Context, fileMap)
-		_,_ = writer.Write([]byte(fmt.Sprintf(`- [%s](%s)
+// addBacklinks tacks additional markdown onto the file with the collection of backlink
+// references.
+func addBacklinks(file *markdownFile, fileMap map[string]*markdownFile, opts *Options, writer io.Writer) error {
+	if len(file.BackLinks) == 0 {
+		return nil
+	}
+	if opts.BacklinksShortcode != "" {
+		_, _ = fmt.Fprintf(writer, "\n{{< %s %q >}}\n", opts.BacklinksShortcode, opts.dataKey(file))
+		return nil
+	}
+	if opts.backlinksTemplate != nil {
+		return renderBacklinks(file, fileMap, opts, writer)
+	}
+	if opts.HideBacklinksHeading {
+		_, _ = writer.Write([]byte("\n"))
+	} else {
+		_, _ = writer.Write([]byte(opts.sectionHeading(opts.labels().Backlinks)))
+	}
+	backlinks := listedBacklinks(file, opts)
+	var more []backlink
+	if opts.MaxBacklinks > 0 && len(backlinks) > opts.MaxBacklinks {
+		backlinks, more = backlinks[:opts.MaxBacklinks], backlinks[opts.MaxBacklinks:]
+	}
+	writeBacklinkList(backlinks, fileMap, opts, writer)
+	if len(more) == 0 {
+		return nil
+	}
+	summary := fmt.Sprintf(opts.labels().MoreBacklinks, len(more))
+	if !opts.MoreBacklinksDetails {
+		_, _ = fmt.Fprintf(writer, "\n%s\n", summary)
+		return nil
+	}
+	_, _ = fmt.Fprintf(writer, "\n<details>\n<summary>%s</summary>\n\n", summary)
+	writeBacklinkList(more, fileMap, opts, writer)
+	_, _ = writer.Write([]byte("\n</details>\n"))
+	return nil
+}
+
+// writeBacklinkList writes the backlinks as a list, under a subheading for each group.
+func writeBacklinkList(backlinks []backlink, fileMap map[string]*markdownFile, opts *Options, writer io.Writer) {
+	for _, group := range groupBacklinks(backlinks, opts) {
+		if group.name != "" {
+			_, _ = writer.Write([]byte(opts.subheading(group.name)))
+		}
+		for _, backlink := range group.backlinks {
+			title := backlink.OtherFile.Title
+			link := opts.pageLink(backlink.OtherFile, "")
+			context := backlinkContext(backlink, fileMap, opts)
+			_,_ = writer.Write([]byte(fmt.Sprintf(`- [%s](%s)
     - %s
 `, title, link, context)))
+			for _, other := range backlink.more {
+				_, _ = fmt.Fprintf(writer, "    - %s\n", backlinkContext(other, fileMap, opts))
+			}
+		}
+	}
+}
+
+// addIndirectBacklinks adds a section for the files that link to this one through other
+// files, when Options.IndirectBacklinkDepth asks for it.
+func addIndirectBacklinks(file *markdownFile, opts *Options, writer io.Writer) error {
+	if opts.IndirectBacklinkDepth < 2 {
+		return nil
+	}
+	indirect := collectIndirectBacklinks(file, opts.IndirectBacklinkDepth)
+	if len(indirect) == 0 {
+		return nil
+	}
+	sort.SliceStable(indirect, func(i, j int) bool {
+		if indirect[i].Degree != indirect[j].Degree {
+			return indirect[i].Degree < indirect[j].Degree
+		}
+		return opts.compareTitles(indirect[i].OtherFile.Title, indirect[j].OtherFile.Title) < 0
+	})
+	labels := opts.labels()
+	_, _ = writer.Write([]byte(opts.sectionHeading(labels.IndirectBacklinks)))
+	for _, ib := range indirect {
+		_, _ = writer.Write([]byte(fmt.Sprintf("- [%s](%s) %s [%s](%s)\n",
+			ib.OtherFile.Title, opts.pageLink(ib.OtherFile, ""), labels.Via, ib.Via.Title, opts.pageLink(ib.Via, ""))))
+	}
+	return nil
+}
+
+// addRelatedNotes adds a section for the files that link to the same files as this one,
+// when Options.RelatedNotesThreshold asks for it.
+func addRelatedNotes(file *markdownFile, opts *Options, writer io.Writer) error {
+	if opts.RelatedNotesThreshold < 1 {
+		return nil
+	}
+	related := collectRelatedNotes(file, opts.RelatedNotesThreshold)
+	if len(related) == 0 {
+		return nil
+	}
+	sort.SliceStable(related, func(i, j int) bool {
+		if len(related[i].Shared) != len(related[j].Shared) {
+			return len(related[i].Shared) > len(related[j].Shared)
+		}
+		return opts.compareTitles(related[i].OtherFile.Title, related[j].OtherFile.Title) < 0
+	})
+	labels := opts.labels()
+	_, _ = writer.Write([]byte(opts.sectionHeading(labels.RelatedNotes)))
+	for _, rn := range related {
+		shared := make([]string, 0, len(rn.Shared))
+		for _, other := range rn.Shared {
+			shared = append(shared, fmt.Sprintf("[%s](%s)", other.Title, opts.pageLink(other, "")))
+		}
+		_, _ = fmt.Fprintf(writer, "- [%s](%s) %s %s\n",
+			rn.OtherFile.Title, opts.pageLink(rn.OtherFile, ""), labels.Via, strings.Join(shared, ", "))
+	}
+	return nil
+}
+
+// listedBacklinks are the file's backlinks in the order they're listed, without the
+// ones that repeat a note and context, and one for each note under
+// Options.CollapseBacklinks.
+func listedBacklinks(file *markdownFile, opts *Options) []backlink {
+	sortBacklinks(file, file.BackLinks, opts)
+	backlinks := dedupeBacklinks(file.BackLinks)
+	if opts.CollapseBacklinks {
+		return collapseBacklinks(backlinks, opts.ContextMerge)
+	}
+	return backlinks
+}
+
+// backlinkContext is the context of a backlink as it's listed: plain text under
+// ContextPlain, and otherwise markdown, with its links converted.
+func backlinkContext(backlink backlink, fileMap map[string]*markdownFile, opts *Options) string {
+	if opts.ContextStyle == ContextPlain {
+		return plainText(backlink.Context)
+	} else if opts.HighlightContextLink {
+		return highlightContextLink(backlink, fileMap, opts)
+	}
+	return convertLinksOnLine(backlink.Context, fileMap, opts)
+}
+
+// highlightContextLink converts the links in the context of a backlink, wrapping the link
+// that the backlink came from in bold.
+func highlightContextLink(bl backlink, fileMap map[string]*markdownFile, opts *Options) string {
+	context := bl.Context
+	if bl.Offset < 0 || bl.Offset >= len(context) || !strings.HasPrefix(context[bl.Offset:], "[[") {
+		return convertLinksOnLine(context, fileMap, opts)
+	}
+	end := strings.Index(context[bl.Offset:], "]]")
+	if end < 0 {
+		return convertLinksOnLine(context, fileMap, opts)
+	}
+	end += bl.Offset + 2
+	return convertLinksOnLine(context[:bl.Offset], fileMap, opts) +
+		"**" + convertLinksOnLine(context[bl.Offset:end], fileMap, opts) + "**" +
+		convertLinksOnLine(context[end:], fileMap, opts)
+}
+
+// readFile reads the file's frontmatter and body from the text that was read from disk
+// (or the error reading it). New files have neither.
+func readFile(sourceDir string, file *markdownFile, filetext []byte, readErr error, opts *Options) error {
+	filename := path.Join(sourceDir, file.relativePath())
+	if file.IsNew {
+		opts.logf("%s is a new file\n", filename)
+		file.scanner = bufio.NewScanner(strings.NewReader(""))
+	} else {
+		opts.logf("Reading %s\n", filename)
+		if readErr != nil {
+			return readErr
+		}
+		file.scanner = bufio.NewScanner(bytes.NewReader(filetext))
+	}
+	err := extractFrontmatter(file, file.scanner, opts)
+	if err != nil {
+		return err
+	}
+	return bufferBody(file, opts)
+}
+
+// skipUnreadable decides what to do about a file that couldn't be read. Under
+// ReadErrorsSkip the file is left out of the output with a warning; otherwise the
+// error ends the run.
+func skipUnreadable(file *markdownFile, err error, opts *Options) error {
+	var pathErr *os.PathError
+	if opts.ReadErrors != ReadErrorsSkip || !errors.As(err, &pathErr) {
+		return err
 	}
+	opts.warnf(WarnUnreadableFile, file.OriginalName, "skipped: %v", err)
+	file.unreadable = true
 	return nil
 }
 
+// includedFiles is sortedFiles without the files that are being left out, because they
+// couldn't be read or aren't being published.
+func includedFiles(fileMap map[string]*markdownFile) []*markdownFile {
+	var result []*markdownFile
+	for _, file := range sortedFiles(fileMap) {
+		if !file.unreadable && !file.unpublished && !file.unwritten {
+			result = append(result, file)
+		}
+	}
+	return result
+}
+
 // generateFileData steps through all of the files and reads in their data, converting
 // wikilinks and adding backlinks
-func generateFileData(sourceDir string, fileMap map[string]*markdownFile) error {
-	for _, file := range fileMap {
+func generateFileData(sourceDir string, fileMap map[string]*markdownFile, opts *Options) error {
+	files := includedFiles(fileMap)
+	texts, errs := readSourceFiles(sourceDir, files, opts)
+	for i, file := range files {
+		if opts.inPlace && errs[i] == nil {
+			texts[i] = restoreWikilinks(texts[i], fileMap, opts)
+		}
 		file.newData = bytes.NewBuffer([]byte{})
-		filename := path.Join(sourceDir, file.OriginalName)
-		var scanner *bufio.Scanner
-		if file.IsNew {
-			log.Printf("%s is a new file\n", filename)
-			scanner = bufio.NewScanner(strings.NewReader(""))
-		} else {
-			log.Printf("Reading %s\n", filename)
-			fileOnDisk, err := os.Open(filename)
-			if err != nil {
-				return err
+		err := readFile(sourceDir, file, texts[i], errs[i], opts)
+		if err != nil {
+			if skipErr := skipUnreadable(file, err, opts); skipErr != nil {
+				return skipErr
 			}
-			scanner = bufio.NewScanner(fileOnDisk)
+			continue
 		}
-		file.scanner = scanner
-		err := extractFrontmatter(file, scanner)
-		if err != nil {
-			return err
+		applyTitlePolicy(file, opts)
+		// Read before BacklinkParams can put the backlinks themselves under the same key
+		file.noBacklinks = file.metadata["backlinks"] == false
+	}
+
+	if opts.SkipDrafts {
+		skipDrafts(fileMap, opts)
+	}
+
+	if opts.MergeSameDay {
+		mergeSameDayFiles(fileMap, opts)
+	}
+
+	// Titles from frontmatter need to be known before any frontmatter is written, since
+	// the frontmatter can refer to other files
+	for _, file := range includedFiles(fileMap) {
+		if title, ok := metadataTitle(file.metadata); ok {
+			file.Title = title
 		}
 	}
 
+	if len(opts.Relations) > 0 {
+		collectRelations(fileMap, opts)
+	}
+
 	// Process all of the date files first, in order to improve the reliability of
 	// finding a date for files that don't have them (especially the files
 	// which are generated just for backlinks).
 	// See https://github.com/dangoor/sharedbrain/issues/2
-	for _, file := range fileMap {
+	for _, file := range includedFiles(fileMap) {
 		if file.IsDateFile {
-			err := adjustFrontmatter(file, file.newData)
+			err := adjustFrontmatter(file, opts, file.newData)
 			if err != nil {
 				return err
 			}
 		}
 	}
 
-	for _, file := range fileMap {
+	for _, file := range includedFiles(fileMap) {
 		// We still need to adjust frontmatter for non-date files
 		if !file.IsDateFile {
-			err := adjustFrontmatter(file, file.newData)
+			err := adjustFrontmatter(file, opts, file.newData)
 			if err != nil {
 				return err
 			}
 		}
 
 		// All files need their links converted
-		err := convertLinks(file.firstLine, file.scanner, fileMap, file.newData)
+		var body bytes.Buffer
+		if opts.PageBundles && !opts.inPlace {
+			opts.bundle = file
+		}
+		err := convertLinks(file, fileMap, opts, &body)
+		opts.bundle = nil
 		if err != nil {
 			return err
 		}
+		file.convertedBody = body.String()
+	}
+
+	stubTemplate, err := opts.stubTemplate()
+	if err != nil {
+		return err
+	}
+	stubBody, err := opts.stubBodyTemplate()
+	if err != nil {
+		return err
+	}
+	err = opts.parseBacklinksTemplate()
+	if err != nil {
+		return err
 	}
 
 	// Backlinks need to be added after adjustFrontmatter has run in order to ensure
 	// that the backlink titles are correct
-	for _, file := range fileMap {
-		err := addBacklinks(file, fileMap, file.newData)
-		if err != nil {
-			return err
+	for _, file := range includedFiles(fileMap) {
+		var sections []generatedSection
+		// The graph has still been collected, but the page itself gets no backlinks
+		if !opts.SkipBacklinkSection && !opts.BacklinksDataOnly {
+			sections, err = generateSections(file, fileMap, opts)
+			if err != nil {
+				return err
+			}
+		}
+		if file.IsNew && stubTemplate != nil {
+			err = renderStub(stubTemplate, file, fileMap, sections, opts)
+			if err != nil {
+				return err
+			}
+			continue
 		}
+		if file.IsNew && stubBody != nil {
+			err = renderStubBody(stubBody, file, fileMap, opts)
+			if err != nil {
+				return err
+			}
+		}
+		file.newData.WriteString(fillMarkedSections(file.convertedBody, sections))
+		file.convertedBody = fillMarkedSections(file.convertedBody, nil)
 	}
 
 	return nil
@@ -439,20 +1539,74 @@ func generateFileData(sourceDir string, fileMap map[string]*markdownFile) error
 
 // writeFiles takes the fully processed fileMap and simply writes all of the new files
 // to disk
-func writeFiles(destDir string, fileMap map[string]*markdownFile) error {
-	for _, file := range fileMap {
-		writer, err := os.Create(path.Join(destDir, file.OriginalName))
-		if err != nil {
-			return err
+func writeFiles(destDir string, fileMap map[string]*markdownFile, opts *Options) error {
+	files := includedFiles(fileMap)
+	opts.progress(0, len(files), "")
+	for done, file := range files {
+		filename := path.Join(destDir, opts.outputPath(file))
+		dir := path.Dir(filename)
+		if opts.DryRun == nil {
+			err := os.MkdirAll(dir, 0755)
+			if err != nil {
+				return err
+			}
 		}
-		_, err = writer.Write(file.newData.Bytes())
+		err := removeReplacedStub(destDir, file, opts)
 		if err != nil {
 			return err
 		}
-		err = writer.Close()
+		if opts.JSONSidecars != SidecarsOnly {
+			err = opts.writeOutput(filename, file.newData.Bytes())
+			if err != nil {
+				return err
+			}
+		}
+		if opts.JSONSidecars != SidecarsOff {
+			err = writeSidecar(dir, file, opts)
+			if err != nil {
+				return err
+			}
+		}
+		opts.progress(done+1, len(files), file.OriginalName)
+	}
+	err := writeRandomNotes(destDir, fileMap, opts)
+	if err != nil {
+		return err
+	}
+	return writeBacklinksData(destDir, fileMap, opts)
+}
+
+// writeFile creates (or replaces) the file at filename with the given data.
+func writeFile(filename string, data []byte) error {
+	writer, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// removeReplacedStub deletes the stub left in Options.StubDir by an earlier run once the
+// file has been written for real, so that only one page exists for it.
+func removeReplacedStub(destDir string, file *markdownFile, opts *Options) error {
+	if file.IsNew || opts.StubDir == "" {
+		return nil
+	}
+	stub := createMarkdownFile(file.OriginalName, true)
+	filename := path.Join(destDir, opts.outputPath(stub))
+	if opts.DryRun != nil {
+		existing, err := ioutil.ReadFile(filename)
 		if err != nil {
-			return err
+			return nil
 		}
+		return writeUnifiedDiff(opts.DryRun, filename, string(existing), "")
+	}
+	err := os.Remove(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
 	}
 	return nil
 }
@@ -469,19 +1623,113 @@ func writeFiles(destDir string, fileMap map[string]*markdownFile) error {
 //    b. Text with links changed
 //    c. Backlinks
 func ProcessBackLinks(sourceDir string, destDir string) error {
-	files, err := getFileList(sourceDir)
+	return ProcessBackLinksWithOptions(sourceDir, destDir, Options{})
+}
+
+// ProcessBackLinksWithOptions works like ProcessBackLinks, with the optional behavior
+// described by opts.
+func ProcessBackLinksWithOptions(sourceDir string, destDir string, opts Options) error {
+	if opts.Strict && opts.Report == nil {
+		opts.Report = &Report{}
+	}
+	if opts.Incremental {
+		opts.SkipUnchanged = true
+		opts.ResolutionIndex = true
+	}
+	opts.inPlace = sameDir(sourceDir, destDir)
+	closeLog, err := openLogFile(destDir, &opts)
 	if err != nil {
-		return nil
+		return err
 	}
-	fileMap := createFileMapping(files)
-	err = collectBacklinks(sourceDir, fileMap)
+	defer closeLog()
+	closeCache, err := useCache(sourceDir, &opts)
 	if err != nil {
 		return err
 	}
-	err = generateFileData(sourceDir, fileMap)
+	defer closeCache()
+	fileMap, err := loadFiles(sourceDir, &opts)
 	if err != nil {
 		return err
 	}
-	err = writeFiles(destDir, fileMap)
-	return err
+	err = strictCheck(&opts)
+	if err != nil {
+		return err
+	}
+	err = copyAttachments(sourceDir, destDir, &opts)
+	if err != nil {
+		return err
+	}
+	err = copyBundledAttachments(sourceDir, destDir, fileMap, &opts)
+	if err != nil {
+		return err
+	}
+	if !opts.SkipUnchanged || opts.DryRun != nil {
+		return writeFiles(destDir, fileMap, &opts)
+	}
+
+	opts.manifest = &outputManifest{Files: make(map[string]string)}
+	err = opts.cache.read(manifestFile, opts.manifest)
+	if err != nil {
+		return err
+	}
+	err = writeFiles(destDir, fileMap, &opts)
+	if err != nil {
+		return err
+	}
+	return opts.cache.write(manifestFile, opts.manifest)
+}
+
+// FileMap is a fully processed set of files, keyed by lower case filename.
+type FileMap map[string]*markdownFile
+
+// LoadFiles does all of the processing that ProcessBackLinksWithOptions does, but returns
+// the processed files instead of writing them, so that they can be exported in other forms.
+func LoadFiles(sourceDir string, opts Options) (FileMap, error) {
+	closeCache, err := useCache(sourceDir, &opts)
+	if err != nil {
+		return nil, err
+	}
+	defer closeCache()
+	return loadFiles(sourceDir, &opts)
+}
+
+// loadFiles covers the first three steps of ProcessBackLinks.
+func loadFiles(sourceDir string, opts *Options) (FileMap, error) {
+	err := readHugoConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	files, err := getFileList(sourceDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = findAttachments(sourceDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	readGitHistory(sourceDir, opts)
+	fileMap := createFileMapping(files, opts)
+	err = collectBacklinks(sourceDir, fileMap, opts)
+	if err != nil {
+		return nil, err
+	}
+	skipStubs(fileMap, opts)
+	err = generateFileData(sourceDir, fileMap, opts)
+	if err != nil {
+		return nil, err
+	}
+	// The index comes before the other generated pages so that it only lists notes
+	err = addTypeIndex(fileMap, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = addTopNotes(fileMap, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = addTodoPage(fileMap, opts)
+	if err != nil {
+		return nil, err
+	}
+	return fileMap, nil
 }
@@ -3,6 +3,10 @@ package backlinker
 import (
 	"bufio"
 	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -11,10 +15,21 @@ import (
 
 )
 
+// writeVault creates a source directory with the given files and returns it,
+// along with an empty destination directory.
+func writeVault(t *testing.T, files map[string]string) (string, string) {
+	sourceDir := t.TempDir()
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(sourceDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+	return sourceDir, t.TempDir()
+}
+
 func TestCreateFileMapping(t *testing.T) {
 	require := require.New(t)
 	files := []string{"First.md", "Second.md", "third.md", "2020-04-26.md"}
-	result := createFileMapping(files)
+	result := createFileMapping(files, &Options{})
 	require.Equal(4, len(result))
 	third, exists := result["third.md"]
 	require.True(exists, "third.md should be in the map")
@@ -44,12 +59,13 @@ func TestCollectBacklinksForFile(t *testing.T) {
 - This is a line with a link to [[second]]
 - This is another line with no links
 - This links to an [[Unknown]]
-`))
+`), &Options{})
 	second := fileMap["second.md"]
 	require.Equal(1, len(second.BackLinks))
 	bl := second.BackLinks[0]
 	require.Equal("First.md", bl.OtherFile.OriginalName)
 	require.Equal("This is a line with a link to [[second]]", bl.Context)
+	require.Equal(30, bl.Offset)
 
 	unknown, exists := fileMap["unknown.md"]
 	require.True(exists, "Unknown should have been added")
@@ -65,9 +81,9 @@ func TestNoFrontmatterReturnsFirstLine(t *testing.T) {
 	}
 	scanner := bufio.NewScanner(strings.NewReader(`This is the first line
 This is the second`))
-	err := extractFrontmatter(&file, scanner)
+	err := extractFrontmatter(&file, scanner, &Options{})
 	require.Nil(err)
-	require.Equal("This is the first line", file.firstLine)
+	require.Equal([]string{"This is the first line"}, file.body)
 }
 
 func TestFrontmatterMayPassThroughUnchanged(t *testing.T) {
@@ -84,12 +100,12 @@ date: 2019-08-26T19:34:48-04:00
 	scanner := bufio.NewScanner(strings.NewReader(inputText))
 	writer := bytes.Buffer{}
 	file.scanner = scanner
-	err := extractFrontmatter(&file, scanner)
+	err := extractFrontmatter(&file, scanner, &Options{})
 	require.NoError(err, "extractFrontmatter" )
-	err = adjustFrontmatter(&file, &writer)
+	err = adjustFrontmatter(&file, &Options{}, &writer)
 	require.NoError(err, "adjustFrontmatter" )
 	require.Nil(err)
-	require.Equal("", file.firstLine)
+	require.Empty(file.body)
 	output := writer.String()
 	require.Contains(output, "2019-08-26T19:34:48-04:00")
 	require.Contains(output, "title: There's")
@@ -107,11 +123,11 @@ func TestFrontmatterForDatePages(t *testing.T) {
 	scanner := bufio.NewScanner(strings.NewReader(inputText))
 	writer := bytes.Buffer{}
 	file.scanner = scanner
-	err := extractFrontmatter(file, scanner)
+	err := extractFrontmatter(file, scanner, &Options{})
 	require.Nil(err)
-	err = adjustFrontmatter(file, &writer)
+	err = adjustFrontmatter(file, &Options{}, &writer)
 	require.Nil(err)
-	require.Equal("## This is an example", file.firstLine)
+	require.Equal([]string{"## This is an example"}, file.body)
 	output := writer.String()
 	require.True(strings.HasPrefix(output, "---\n"))
 	require.Contains(output, "date: 2020-04-19T08:00:00-05:00\n")
@@ -133,7 +149,7 @@ func TestDateAddedBasedOnBacklinks(t *testing.T) {
 		Context:   "Linking to [[Unknown]]",
 	})
 	writer := bytes.Buffer{}
-	err := adjustFrontmatter(file, &writer)
+	err := adjustFrontmatter(file, &Options{}, &writer)
 	require.Nil(err)
 	output := writer.String()
 	require.True(strings.HasPrefix(output, "---\n"))
@@ -149,7 +165,7 @@ func TestNoDateAddedIfBacklinkHasNoDate(t *testing.T) {
 		Context:   "Linking to [[Unknown]]",
 	})
 	writer := bytes.Buffer{}
-	err := adjustFrontmatter(file, &writer)
+	err := adjustFrontmatter(file, &Options{}, &writer)
 	require.Nil(err)
 	output := writer.String()
 	require.True(strings.HasPrefix(output, "---\n"))
@@ -170,13 +186,42 @@ date: '2019-08-26'
 	scanner := bufio.NewScanner(strings.NewReader(inputText))
 	writer := bytes.Buffer{}
 	file.scanner = scanner
-	err := extractFrontmatter(&file, file.scanner)
+	err := extractFrontmatter(&file, file.scanner, &Options{})
+	require.Nil(err)
+	err = adjustFrontmatter(&file, &Options{}, &writer)
+	require.Nil(err)
+	require.Empty(file.body)
+	output := writer.String()
+	require.Contains(output, "date: '2019-08-26'\n", "Untouched keys are kept as they were written")
+}
+
+func TestReadingTimeAddedToFrontmatter(t *testing.T) {
+	require := require.New(t)
+	file := createMarkdownFile("2020-05-01.md", false)
+	inputText := "## Four words ahead\n" + strings.Repeat("word ", 322) + "\n"
+	file.scanner = bufio.NewScanner(strings.NewReader(inputText))
+	err := extractFrontmatter(file, file.scanner, &Options{})
+	require.Nil(err)
+	err = bufferBody(file, &Options{})
 	require.Nil(err)
-	err = adjustFrontmatter(&file, &writer)
+	writer := bytes.Buffer{}
+	opts := Options{ReadingTime: true, WordsPerMinute: 100, ReadingTimeKey: "minutes"}
+	err = adjustFrontmatter(file, &opts, &writer)
+	require.Nil(err)
+	output := writer.String()
+	require.Contains(output, "word_count: 326\n")
+	require.Contains(output, "minutes: 3\n")
+}
+
+func TestReadingTimeSkippedForNewFiles(t *testing.T) {
+	require := require.New(t)
+	file := createMarkdownFile("Unknown.md", true)
+	writer := bytes.Buffer{}
+	err := adjustFrontmatter(file, &Options{ReadingTime: true}, &writer)
 	require.Nil(err)
-	require.Equal("", file.firstLine)
 	output := writer.String()
-	require.Contains(output, "date: \"2019-08-26\"")
+	require.NotContains(output, "word_count")
+	require.NotContains(output, "reading_time")
 }
 
 func TestConvertLinksOnLine(t *testing.T) {
@@ -188,17 +233,76 @@ func TestConvertLinksOnLine(t *testing.T) {
 		"name with spaces.md": createMarkdownFile("Name With Spaces.md", false),
 	}
 	line := "This line links to [[First]] and [[third]] and [[name with spaces]]."
-	result := convertLinksOnLine(line, fileMap)
+	result := convertLinksOnLine(line, fileMap, &Options{})
 	require.Equal("This line links to [First](./first/) and [third](./third/) and [name with spaces](./name-with-spaces/).", result)
 }
 
+func TestConvertLinksSkipsCodeSpans(t *testing.T) {
+	require := require.New(t)
+	fileMap := map[string]*markdownFile{
+		"template.md": createMarkdownFile("Template.md", false),
+		"first.md":    createMarkdownFile("First.md", false),
+	}
+	line := "Use `[[template]]` syntax to reach [[template]], or ``[[x]] ` [[y]]`` and `[[z]]` before [[First]]."
+	result := convertLinksOnLine(line, fileMap, &Options{})
+	require.Equal("Use `[[template]]` syntax to reach [template](./template/), or ``[[x]] ` [[y]]`` and `[[z]]` before [First](./first/).", result)
+	require.Equal(2, len(fileMap), "Links in code should not create new files")
+
+	line = "An unclosed ` doesn't hide [[First]]."
+	require.Equal("An unclosed ` doesn't hide [First](./first/).", convertLinksOnLine(line, fileMap, &Options{}))
+}
+
+func TestConvertTextSkipsCodeBlocks(t *testing.T) {
+	require := require.New(t)
+	fileMap := map[string]*markdownFile{
+		"first.md": createMarkdownFile("First.md", false),
+	}
+	body := "See [[First]].\n\n```\n[[fenced]]\n```\n\n    [[indented]]\n\n" +
+		"- A list item with `[[code]]` and [[First|a label]]\n"
+	result := convertText(body, fileMap, nil, &Options{})
+	require.Equal("See [First](./first/).\n\n```\n[[fenced]]\n```\n\n    [[indented]]\n\n"+
+		"- A list item with `[[code]]` and [a label](./first/)\n", result)
+	require.Equal(1, len(fileMap), "Links in code should not create new files")
+}
+
+func TestConvertLinksUnderBasePath(t *testing.T) {
+	require := require.New(t)
+	fileMap := map[string]*markdownFile{
+		"first.md":            createMarkdownFile("First.md", false),
+		"name with spaces.md": createMarkdownFile("Name With Spaces.md", false),
+	}
+	line := "Links to [[First]] and [[name with spaces]]."
+	for _, basePath := range []string{"/wiki/", "wiki", "https://example.com/wiki/"} {
+		result := convertLinksOnLine(line, fileMap, &Options{BasePath: basePath})
+		require.Equal("Links to [First](/wiki/first/) and [name with spaces](/wiki/name-with-spaces/).", result, basePath)
+	}
+	result := convertLinksOnLine(line, fileMap, &Options{BasePath: "/"})
+	require.Equal("Links to [First](/first/) and [name with spaces](/name-with-spaces/).", result)
+}
+
+func TestBacklinksUnderBasePath(t *testing.T) {
+	require := require.New(t)
+	fileMap := map[string]*markdownFile{
+		"first.md":  createMarkdownFile("First.md", false),
+		"second.md": createMarkdownFile("Second.md", false),
+	}
+	fileMap["first.md"].BackLinks = append(fileMap["first.md"].BackLinks, backlink{
+		OtherFile: fileMap["second.md"],
+		Context:   "This has a [[first]] link.",
+	})
+	writer := bytes.Buffer{}
+	err := addBacklinks(fileMap["first.md"], fileMap, &Options{BasePath: "/wiki"}, &writer)
+	require.Nil(err)
+	require.Contains(writer.String(), "- [Second](/wiki/second/)\n    - This has a [first](/wiki/first/) link.\n")
+}
+
 func TestConvertLinksForUnknownFile(t *testing.T) {
 	require := require.New(t)
 	fileMap := map[string]*markdownFile{
 		"first.md": {OriginalName: "First.md", Title: "First", BackLinks: make([]backlink, 0)},
 	}
 	line := "This line links to [[Unknown]]!"
-	result := convertLinksOnLine(line, fileMap)
+	result := convertLinksOnLine(line, fileMap, &Options{})
 	require.Equal("This line links to [Unknown](./unknown/)!", result)
 	unknown, exists := fileMap["unknown.md"]
 	require.True(exists, "Unknown file should have been created")
@@ -217,9 +321,9 @@ func TestConvertLinks(t *testing.T) {
 - And here's a reference to [[Second]] and [[third]]
 - And another [[second]]
 `
-	scanner := bufio.NewScanner(strings.NewReader(inputText))
+	file := &markdownFile{OriginalName: "Page.md", scanner: bufio.NewScanner(strings.NewReader(inputText))}
 	writer := bytes.Buffer{}
-	err := convertLinks("", scanner, fileMap, &writer)
+	err := convertLinks(file, fileMap, &Options{}, &writer)
 	require.Nil(err)
 	output := writer.String()
 	require.Equal(`## This is a heading
@@ -228,6 +332,28 @@ func TestConvertLinks(t *testing.T) {
 `, output)
 }
 
+func TestBacklinkContextHighlightsInboundLink(t *testing.T) {
+	require := require.New(t)
+	fileMap := map[string]*markdownFile{
+		"first.md":  createMarkdownFile("First.md", false),
+		"second.md": createMarkdownFile("Second.md", false),
+		"third.md":  createMarkdownFile("Third.md", false),
+	}
+	collectBacklinksForFile(fileMap, fileMap["second.md"], []byte(
+		"Both [[third]] and [[first]] and [[first]] again.\n"), &Options{})
+	first := fileMap["first.md"]
+	require.Equal(2, len(first.BackLinks))
+	require.Equal(19, first.BackLinks[0].Offset)
+	require.Equal(33, first.BackLinks[1].Offset)
+
+	writer := bytes.Buffer{}
+	first.BackLinks = first.BackLinks[1:]
+	err := addBacklinks(first, fileMap, &Options{HighlightContextLink: true}, &writer)
+	require.Nil(err)
+	require.Contains(writer.String(),
+		"    - Both [third](./third/) and [first](./first/) and **[first](./first/)** again.\n")
+}
+
 func Test_addBacklinks(t *testing.T) {
 	require := require.New(t)
 	type args struct {
@@ -246,10 +372,10 @@ func Test_addBacklinks(t *testing.T) {
 	fileMap["third.md"] = createMarkdownFile("Third.md", false)
 	fileMap["2020-04-21.md"] = createMarkdownFile("2020-04-21.md", false)
 	frontmatterWriter := bytes.Buffer{}
-	err := adjustFrontmatter(fileMap["2020-04-21.md"], &frontmatterWriter)
+	err := adjustFrontmatter(fileMap["2020-04-21.md"], &Options{}, &frontmatterWriter)
 	require.Nil(err, "Should not get an error when adjusting frontmatter")
 	fileMap["2020-04-24.md"] = createMarkdownFile("2020-04-24.md", false)
-	err = adjustFrontmatter(fileMap["2020-04-24.md"], &frontmatterWriter)
+	err = adjustFrontmatter(fileMap["2020-04-24.md"], &Options{}, &frontmatterWriter)
 	require.Nil(err, "Should not get an error when adjusting frontmatter")
 
 	fileMap["third.md"].BackLinks = append(fileMap["third.md"].BackLinks, backlink{
@@ -310,7 +436,7 @@ func Test_addBacklinks(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			writer := &bytes.Buffer{}
-			err := addBacklinks(tt.args.file, tt.args.fileMap, writer)
+			err := addBacklinks(tt.args.file, tt.args.fileMap, &Options{}, writer)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("addBacklinks() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -321,3 +447,447 @@ func Test_addBacklinks(t *testing.T) {
 		})
 	}
 }
+
+func TestStubsWrittenToStubDir(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"First.md":  "Links to [[Not Written]] and [[Second]].\n",
+		"Second.md": "Nothing here.\n",
+	})
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{StubDir: "stubs"})
+	require.NoError(err)
+
+	first, err := ioutil.ReadFile(filepath.Join(destDir, "First.md"))
+	require.NoError(err)
+	require.Contains(string(first), "[Not Written](./stubs/not-written/) and [Second](./second/)")
+	stub, err := ioutil.ReadFile(filepath.Join(destDir, "stubs", "Not Written.md"))
+	require.NoError(err)
+	require.Contains(string(stub), "- [First](./first/)")
+	require.NoFileExists(filepath.Join(destDir, "Not Written.md"))
+
+	// Once the note is written, links go to the real page and the stub goes away.
+	err = ioutil.WriteFile(filepath.Join(sourceDir, "Not Written.md"), []byte("Written now.\n"), 0644)
+	require.NoError(err)
+	err = ProcessBackLinksWithOptions(sourceDir, destDir, Options{StubDir: "stubs"})
+	require.NoError(err)
+	first, err = ioutil.ReadFile(filepath.Join(destDir, "First.md"))
+	require.NoError(err)
+	require.Contains(string(first), "[Not Written](./not-written/)")
+	require.FileExists(filepath.Join(destDir, "Not Written.md"))
+	require.NoFileExists(filepath.Join(destDir, "stubs", "Not Written.md"))
+}
+
+func TestSkipBacklinkSection(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"First.md":  "Links to [[Second]].\n",
+		"Second.md": "And back to [[First]].\n",
+	})
+	opts := Options{SkipBacklinkSection: true, IndirectBacklinkDepth: 2}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+	require.NoError(err)
+	for _, name := range []string{"First.md", "Second.md"} {
+		output, err := ioutil.ReadFile(filepath.Join(destDir, name))
+		require.NoError(err)
+		require.NotContains(string(output), "Backlinks")
+	}
+	first, err := ioutil.ReadFile(filepath.Join(destDir, "First.md"))
+	require.NoError(err)
+	require.Contains(string(first), "Links to [Second](./second/).")
+}
+
+func TestSkipDateFileBacklinks(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"2020-04-26.md": "Worked on [[Project]].\n",
+		"Project.md":    "Started on [[2020-04-26]].\n",
+	})
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{SkipDateFileBacklinks: true})
+	require.NoError(err)
+	journal, err := ioutil.ReadFile(filepath.Join(destDir, "2020-04-26.md"))
+	require.NoError(err)
+	require.NotContains(string(journal), "Backlinks")
+	require.Contains(string(journal), "Worked on [Project](./project/).")
+	project, err := ioutil.ReadFile(filepath.Join(destDir, "Project.md"))
+	require.NoError(err)
+	require.Contains(string(project), "## Backlinks\n\n- [2020-04-26](./2020-04-26/)\n")
+}
+
+func TestBacklinksFalseInFrontmatter(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Home.md":   "---\nbacklinks: false\n---\nStart at [[Garden]].\n",
+		"Garden.md": "Back [[Home]].\n",
+	})
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{BacklinkParams: true})
+	require.NoError(err)
+	home, err := ioutil.ReadFile(filepath.Join(destDir, "Home.md"))
+	require.NoError(err)
+	require.NotContains(string(home), "## Backlinks")
+	require.Contains(string(home), "backlinks: false\n")
+	require.Contains(string(home), "Start at [Garden](./garden/).")
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "Back [Home](./home/).")
+	require.Contains(string(garden), "## Backlinks\n\n- [Home](./home/)\n")
+}
+
+func TestProgressReportedPerFile(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"b.md": "Links to [[c]].\n",
+		"a.md": "Links to [[b]].\n",
+	})
+	var calls []string
+	opts := Options{Progress: func(done int, total int, current string) {
+		calls = append(calls, fmt.Sprintf("%d/%d %s", done, total, current))
+	}}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+	require.NoError(err)
+	require.Equal([]string{"0/3 ", "1/3 a.md", "2/3 b.md", "3/3 c.md"}, calls)
+}
+
+func TestAliasSelfLinksAreSkipped(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Digital Gardens.md": "---\naliases: [Garden, my garden]\n---\nThis [[garden]] is about [[Digital Gardens]].\n",
+		"Other.md":           "Visiting [[My Garden]].\n",
+	})
+	opts := Options{AliasKey: "aliases", SkipSelfBacklinks: true}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+	require.NoError(err)
+
+	gardens, err := ioutil.ReadFile(filepath.Join(destDir, "Digital Gardens.md"))
+	require.NoError(err)
+	require.Contains(string(gardens), "This [garden](./digital-gardens/) is about")
+	require.Contains(string(gardens), "- [Other](./other/)")
+	require.NotContains(string(gardens), "- [Digital Gardens](./digital-gardens/)")
+	other, err := ioutil.ReadFile(filepath.Join(destDir, "Other.md"))
+	require.NoError(err)
+	require.Contains(string(other), "Visiting [My Garden](./digital-gardens/).")
+	written, err := ioutil.ReadDir(destDir)
+	require.NoError(err)
+	require.Equal(2, len(written), "Aliases should not produce stub files")
+}
+
+func TestUnreadableFilesSkipped(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"First.md":  "Links to [[Second]] and [[Broken]].\n",
+		"Second.md": "Nothing here.\n",
+	})
+	err := os.Symlink(filepath.Join(sourceDir, "missing"), filepath.Join(sourceDir, "Broken.md"))
+	require.NoError(err)
+
+	err = ProcessBackLinksWithOptions(sourceDir, destDir, Options{})
+	require.Error(err, "Read errors end the run by default")
+
+	report := Report{}
+	err = ProcessBackLinksWithOptions(sourceDir, destDir, Options{ReadErrors: ReadErrorsSkip, Report: &report})
+	require.NoError(err)
+	require.Equal(1, len(report.Warnings))
+	require.Equal(WarnUnreadableFile, report.Warnings[0].Kind)
+	require.Equal("Broken.md", report.Warnings[0].File)
+	require.FileExists(filepath.Join(destDir, "First.md"))
+	require.FileExists(filepath.Join(destDir, "Second.md"))
+	require.NoFileExists(filepath.Join(destDir, "Broken.md"))
+	first, err := ioutil.ReadFile(filepath.Join(destDir, "First.md"))
+	require.NoError(err)
+	require.Contains(string(first), "[Broken](./broken/)")
+}
+
+func TestLinkTitlePriority(t *testing.T) {
+	require := require.New(t)
+	files := map[string]string{
+		"notes.md":      "Just notes.\n",
+		"2020-05-01.md": "---\ntitle: Notes\n---\nThe real notes.\n",
+		"Reader.md":     "Reading [[Notes]], also known as [[2020-05-01]].\n",
+	}
+	for priority, expected := range map[LinkTitlePolicy]string{
+		LinkTitlesOff:      "./notes/",
+		LinkTitlesFallback: "./notes/",
+		LinkTitlesFirst:    "./2020-05-01/",
+	} {
+		sourceDir, destDir := writeVault(t, files)
+		err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{LinkTitles: priority})
+		require.NoError(err)
+
+		reader, err := ioutil.ReadFile(filepath.Join(destDir, "Reader.md"))
+		require.NoError(err)
+		require.Contains(string(reader), "Reading [Notes]("+expected+")", priority)
+		require.Contains(string(reader), "also known as [2020-05-01](./2020-05-01/)", priority)
+
+		// The backlink goes to the same note the link does
+		notes, err := ioutil.ReadFile(filepath.Join(destDir, "notes.md"))
+		require.NoError(err)
+		dated, err := ioutil.ReadFile(filepath.Join(destDir, "2020-05-01.md"))
+		require.NoError(err)
+		if priority == LinkTitlesFirst {
+			require.NotContains(string(notes), "## Backlinks", priority)
+			require.Equal(1, strings.Count(string(dated), "- [Reader](./reader/)\n    - Reading"), "Both links lead to the dated note, from one paragraph")
+		} else {
+			require.Contains(string(notes), "- [Reader](./reader/)", priority)
+		}
+	}
+}
+
+func TestLinkTitleFallback(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"2020-05-01.md": "---\ntitle: Garden Diary\n---\nThe diary.\n",
+		"Reader.md":     "Reading the [[Garden Diary]].\n",
+	})
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{LinkTitles: LinkTitlesFallback})
+	require.NoError(err)
+	reader, err := ioutil.ReadFile(filepath.Join(destDir, "Reader.md"))
+	require.NoError(err)
+	require.Contains(string(reader), "Reading the [Garden Diary](./2020-05-01/).")
+	require.NoFileExists(filepath.Join(destDir, "Garden Diary.md"))
+}
+
+func TestFirstBodyLineTreatedLikeTheRest(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Heading.md":        "# About [[Gardens]]\nText.\n",
+		"Blank.md":          "\nAbout [[Gardens]].\n",
+		"Text.md":           "About [[Gardens]].\nMore.\n",
+		"BlankAfterMeta.md": "---\ntitle: Meta\n---\n\n[[Gardens]] again.\n",
+		"Gardens.md":        "---\ntitle: Gardens\n---\n",
+	})
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{})
+	require.NoError(err)
+
+	expected := map[string]string{
+		"Heading.md":        "---\ntitle: Heading\n---\n# About [Gardens](./gardens/)\nText.\n",
+		"Blank.md":          "---\ntitle: Blank\n---\n\nAbout [Gardens](./gardens/).\n",
+		"Text.md":           "---\ntitle: Text\n---\nAbout [Gardens](./gardens/).\nMore.\n",
+		"BlankAfterMeta.md": "---\ntitle: Meta\n---\n\n[Gardens](./gardens/) again.\n",
+		"Gardens.md":        "---\ntitle: Gardens\n---\n",
+	}
+	for name, text := range expected {
+		output, err := ioutil.ReadFile(filepath.Join(destDir, name))
+		require.NoError(err)
+		require.True(strings.HasPrefix(string(output), text), "%s:\n%s", name, output)
+	}
+	gardens, err := ioutil.ReadFile(filepath.Join(destDir, "Gardens.md"))
+	require.NoError(err)
+	require.Contains(string(gardens), "- [Heading](./heading/)\n    - About [Gardens](./gardens/)\n")
+	require.Contains(string(gardens), "- [Blank](./blank/)\n    - About [Gardens](./gardens/).\n")
+}
+
+func TestUppercaseExtensions(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Imported.MD":        "Links to [[Long Form]].\n",
+		"Long Form.Markdown": "Links back to [[imported]].\n",
+		"2024-02-01.MD":      "Journal.\n",
+		"Not a note.txt":     "[[Imported]]\n",
+	})
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{})
+	require.NoError(err)
+
+	imported, err := ioutil.ReadFile(filepath.Join(destDir, "Imported.MD"))
+	require.NoError(err)
+	require.Contains(string(imported), "Links to [Long Form](./long-form/).\n")
+	require.Contains(string(imported), "- [Long Form](./long-form/)\n")
+	longForm, err := ioutil.ReadFile(filepath.Join(destDir, "Long Form.Markdown"))
+	require.NoError(err)
+	require.Contains(string(longForm), "- [Imported](./imported/)\n")
+	require.NoFileExists(filepath.Join(destDir, "Long Form.md"), "No stub for a note that exists")
+	require.NoFileExists(filepath.Join(destDir, "Not a note.txt"))
+
+	files, err := LoadFiles(sourceDir, Options{})
+	require.NoError(err)
+	require.True(files["2024-02-01.md"].IsDateFile)
+}
+
+func TestRecursiveTraversal(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Index.md": "See [[Plan]] and [[2024-02-01]].\n",
+	})
+	nested := map[string]string{
+		"projects/Plan.md":            "Written on [[2024-02-01]], for the [[Index]].\n",
+		"journal/2024/2024-02-01.md":  "Worked on the [[plan]].\n",
+		".obsidian/Workspace.md":      "[[Plan]]\n",
+		"reference/Big Ideas/Idea.md": "Part of the [[Plan]].\n",
+	}
+	for name, text := range nested {
+		filename := filepath.Join(sourceDir, filepath.FromSlash(name))
+		require.NoError(os.MkdirAll(filepath.Dir(filename), 0755))
+		require.NoError(ioutil.WriteFile(filename, []byte(text), 0644))
+	}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{Recursive: true})
+	require.NoError(err)
+
+	plan, err := ioutil.ReadFile(filepath.Join(destDir, "projects", "Plan.md"))
+	require.NoError(err)
+	require.Contains(string(plan), "Written on [2024-02-01](./journal/2024/2024-02-01/), for the [Index](./index/).\n")
+	require.Contains(string(plan), "- [Idea](./reference/big-ideas/idea/)\n")
+	require.Contains(string(plan), "- [Index](./index/)\n")
+	require.NotContains(string(plan), "Workspace")
+	journal, err := ioutil.ReadFile(filepath.Join(destDir, "journal", "2024", "2024-02-01.md"))
+	require.NoError(err)
+	require.Contains(string(journal), "date: 2024-02-01T08:00:00-05:00\n")
+	require.Contains(string(journal), "Worked on the [plan](./projects/plan/).\n")
+	require.NoFileExists(filepath.Join(destDir, "Plan.md"), "No stub for a note in a subdirectory")
+	require.NoDirExists(filepath.Join(destDir, ".obsidian"))
+
+	flatDest := t.TempDir()
+	err = ProcessBackLinksWithOptions(sourceDir, flatDest, Options{})
+	require.NoError(err)
+	require.FileExists(filepath.Join(flatDest, "Plan.md"), "Only the top level is read by default, so Plan is a stub")
+}
+
+func TestPathQualifiedLinks(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Index.md": "[[Roadmap]], [[projects/roadmap]], [[/archive/2020/Roadmap|the old one]], [[2020/roadmap]] and [[projects/Missing]].\n",
+	})
+	for _, name := range []string{"projects/Roadmap.md", "archive/2020/Roadmap.md", "Roadmap.md"} {
+		filename := filepath.Join(sourceDir, filepath.FromSlash(name))
+		require.NoError(os.MkdirAll(filepath.Dir(filename), 0755))
+		require.NoError(ioutil.WriteFile(filename, []byte("A roadmap.\n"), 0644))
+	}
+	report := &Report{}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{Recursive: true, Report: report}))
+
+	index, err := ioutil.ReadFile(filepath.Join(destDir, "Index.md"))
+	require.NoError(err)
+	require.Contains(string(index), "[Roadmap](./roadmap/), [projects/roadmap](./projects/roadmap/), "+
+		"[the old one](./archive/2020/roadmap/), [2020/roadmap](./archive/2020/roadmap/) and [projects/Missing](./projects/missing/).\n")
+	require.FileExists(filepath.Join(destDir, "projects", "Missing.md"), "The stub goes where the link says")
+	var ambiguous []string
+	for _, warning := range report.Warnings {
+		if warning.Kind == WarnAmbiguousLink {
+			ambiguous = append(ambiguous, warning.File)
+		}
+	}
+	require.ElementsMatch([]string{"archive/2020/Roadmap.md", "projects/Roadmap.md"}, ambiguous)
+
+	fileMap := createFileMapping([]string{"archive/2020/Roadmap.md", "projects/Roadmap.md"}, &Options{})
+	require.Equal("projects/Roadmap.md", fileMap["roadmap.md"].relativePath(), "The shortest path wins")
+	require.Equal("Roadmap", linkName(fileMap, fileMap["projects/roadmap.md"]))
+	require.Equal("2020/Roadmap", linkName(fileMap, fileMap["archive/2020/roadmap.md"]))
+}
+
+func TestMarkdownLinksAsBacklinks(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Index.md": "Read [the plan](projects/My%20Plan.md#goals), not [the site](https://example.com/Plan.md) " +
+			"or [nothing](Missing.md).\n\n```\n[code](projects/My%20Plan.md)\n```\n",
+	})
+	require.NoError(os.MkdirAll(filepath.Join(sourceDir, "projects"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "projects", "My Plan.md"),
+		[]byte("## Goals\n\nBack to the [index](../Index.md).\n"), 0644))
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{Recursive: true}))
+
+	plan, err := ioutil.ReadFile(filepath.Join(destDir, "projects", "My Plan.md"))
+	require.NoError(err)
+	require.Equal(1, strings.Count(string(plan), "- [Index](./index/)\n"), "The link in code doesn't count")
+	index, err := ioutil.ReadFile(filepath.Join(destDir, "Index.md"))
+	require.NoError(err)
+	require.Contains(string(index), "- [My Plan](./projects/my-plan/)\n    - Back to the [index](../Index.md).\n")
+	require.NoFileExists(filepath.Join(destDir, "Missing.md"), "Markdown links don't make stubs")
+
+	links := findLinks([]byte("See [[Index]] and [the plan](My%20Plan.md#goals).\n"), &Options{})
+	require.Len(links, 2)
+	require.Equal(foundLink{Text: "My Plan.md#goals", Label: "the plan", Markdown: true,
+		Context: "See [[Index]] and [the plan](My%20Plan.md#goals).", start: 19, stop: 19}, links[1])
+}
+
+func TestRoamTags(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Daily.md":  "Thinking about #[[Big Ideas]] and #Garden (#1), not [[Garden#Beds]] or `#code`.\n\n#Garden\n",
+		"Garden.md": "Plants.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{LinkSyntax: LinkSyntaxRoam}))
+
+	daily, err := ioutil.ReadFile(filepath.Join(destDir, "Daily.md"))
+	require.NoError(err)
+	require.Contains(string(daily), "Thinking about [#Big Ideas](./big-ideas/) and [#Garden](./garden/) (#1), "+
+		"not [Garden#Beds](./garden/#beds) or `#code`.\n\n[#Garden](./garden/)\n")
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Equal(2, strings.Count(string(garden), "- [Daily](./daily/)\n"), "Both paragraphs, with the tag and link in the first listed once")
+	require.Contains(string(garden), "    - Thinking about [#Big Ideas](./big-ideas/) and [#Garden](./garden/) (#1), "+
+		"not [Garden#Beds](./garden/#beds) or `#code`.\n", "Tags in contexts are converted as in the note")
+	require.Contains(string(garden), "    - [#Garden](./garden/)\n")
+	require.FileExists(filepath.Join(destDir, "Big Ideas.md"))
+	require.NoFileExists(filepath.Join(destDir, "code.md"))
+	require.NoFileExists(filepath.Join(destDir, "1.md"), "A tag starts with a letter")
+
+	plain := t.TempDir()
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, plain, Options{}))
+	daily, err = ioutil.ReadFile(filepath.Join(plain, "Daily.md"))
+	require.NoError(err)
+	require.Contains(string(daily), "Thinking about #[Big Ideas](./big-ideas/) and #Garden (#1),")
+}
+
+func TestLabelledLinks(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Digital Gardens.md": "Tending [[Journal#Spring|the spring notes]].\n",
+		"Journal.md":         "# Spring\n\nSee [[Digital Gardens|my garden]], and | [[Missing Page\\|whatever]] |.\n",
+	})
+	report := &Report{}
+	opts := Options{Report: report, StubTemplate: "{{range .Backlinks}}{{.Title}} called it {{.Label}}{{end}}\n"}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+
+	journal, err := ioutil.ReadFile(filepath.Join(destDir, "Journal.md"))
+	require.NoError(err)
+	require.Contains(string(journal), "See [my garden](./digital-gardens/), and | [whatever](./missing-page/) |.\n")
+	require.Contains(string(journal), "- [Digital Gardens](./digital-gardens/)\n    - Tending [the spring notes](./journal/#spring).\n")
+	gardens, err := ioutil.ReadFile(filepath.Join(destDir, "Digital Gardens.md"))
+	require.NoError(err)
+	require.Contains(string(gardens), "- [Journal](./journal/)\n")
+	missing, err := ioutil.ReadFile(filepath.Join(destDir, "Missing Page.md"))
+	require.NoError(err)
+	require.Equal("Journal called it whatever\n", string(missing))
+	require.Len(report.Warnings, 1, "Only the link to the missing page is dangling")
+	require.Equal(WarnDanglingLink, report.Warnings[0].Kind)
+
+	fileMap, err := LoadFiles(sourceDir, Options{})
+	require.NoError(err)
+	require.Equal("my garden", fileMap["digital gardens.md"].BackLinks[0].Label)
+}
+
+func TestRelrefLinks(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "Plants by the [[Shed]], and [[Pond#Frogs|frogs]].\n",
+		"Pond.md":   "## Frogs\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{LinkStyle: LinkStyleRelref}))
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), `Plants by the [Shed]({{< relref "/Shed.md" >}}), and [frogs]({{< relref "/Pond.md#frogs" >}}).`)
+	pond, err := ioutil.ReadFile(filepath.Join(destDir, "Pond.md"))
+	require.NoError(err)
+	require.Contains(string(pond), `- [Garden]({{< relref "/Garden.md" >}})`)
+
+	bundles := t.TempDir()
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, bundles, Options{LinkStyle: LinkStyleRef, PageBundles: true}))
+	garden, err = ioutil.ReadFile(filepath.Join(bundles, "Garden", "index.md"))
+	require.NoError(err)
+	require.Contains(string(garden), `[Shed]({{< ref "/Shed/index.md" >}})`)
+}
+
+func TestSections(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"2024-02-01.md": "Planted the [[Garden]].\n",
+		"Garden.md":     "Started on [[2024-02-01]], next to the [[Shed]].\n",
+	})
+	opts := Options{Sections: Sections{DateFiles: "journal", Notes: "/notes/"}, TodoPage: "todos.md"}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "notes", "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "Started on [2024-02-01](./journal/2024-02-01/), next to the [Shed](./notes/shed/).\n")
+	journal, err := ioutil.ReadFile(filepath.Join(destDir, "journal", "2024-02-01.md"))
+	require.NoError(err)
+	require.Contains(string(journal), "Planted the [Garden](./notes/garden/).\n")
+	require.FileExists(filepath.Join(destDir, "notes", "Shed.md"), "Stubs go with the notes")
+	require.FileExists(filepath.Join(destDir, "todos.md"), "Generated pages stay at the top")
+}
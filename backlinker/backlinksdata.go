@@ -0,0 +1,90 @@
+package backlinker
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+)
+
+// dataBacklink is one backlink in the BacklinksDataFile: the note linking, and the
+// context of the link as markdown.
+type dataBacklink struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Context string `json:"context"`
+}
+
+// backlinksData gives the backlinks of each note that has any, in the order of its
+// Backlinks section, by the note's URL from the root of the site or its slug.
+func backlinksData(fileMap map[string]*markdownFile, opts *Options) map[string][]dataBacklink {
+	data := make(map[string][]dataBacklink)
+	for _, file := range includedFiles(fileMap) {
+		backlinks := append([]backlink{}, file.BackLinks...)
+		sortBacklinks(file, backlinks, opts)
+		for _, bl := range dedupeBacklinks(backlinks) {
+			if bl.OtherFile.unpublished {
+				continue
+			}
+			key := opts.dataKey(file)
+			data[key] = append(data[key], dataBacklink{
+				Title:   bl.OtherFile.Title,
+				URL:     opts.permalink(bl.OtherFile),
+				Context: convertLinksOnLine(bl.Context, fileMap, opts),
+			})
+		}
+	}
+	return data
+}
+
+// dataKey is what the file is known by in the BacklinksDataFile.
+func (o *Options) dataKey(file *markdownFile) string {
+	if o.backlinksDataKey() != DataKeySlug {
+		return o.siteLink(file)
+	}
+	if file.slug != "" {
+		return urlize(file.slug)
+	}
+	return urlize(removeExtension(file.OriginalName))
+}
+
+func (o *Options) backlinksDataFile() string {
+	if o.BacklinksDataFile == "" && o.backlinksAsData() {
+		return "data/backlinks.json"
+	}
+	return o.BacklinksDataFile
+}
+
+// backlinksAsData is true when the backlinks are rendered from the BacklinksDataFile
+// rather than written as markdown.
+func (o *Options) backlinksAsData() bool {
+	return o.BacklinksDataOnly || o.BacklinksShortcode != ""
+}
+
+func (o *Options) backlinksDataKey() DataKey {
+	switch {
+	case o.BacklinksDataKey != "":
+		return o.BacklinksDataKey
+	case o.backlinksAsData():
+		return DataKeySlug
+	}
+	return DataKeyURL
+}
+
+// writeBacklinksData writes the backlinks of every note into Options.BacklinksDataFile.
+func writeBacklinksData(destDir string, fileMap map[string]*markdownFile, opts *Options) error {
+	if opts.backlinksDataFile() == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(backlinksData(fileMap, opts), "", "  ")
+	if err != nil {
+		return err
+	}
+	filename := path.Join(destDir, opts.backlinksDataFile())
+	if opts.DryRun == nil {
+		err = os.MkdirAll(path.Dir(filename), 0755)
+		if err != nil {
+			return err
+		}
+	}
+	return opts.writeOutput(filename, append(data, '\n'))
+}
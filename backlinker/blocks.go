@@ -0,0 +1,48 @@
+package backlinker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// blockMarker matches the id Obsidian puts at the end of a block (a paragraph or list
+// item) for links like [[page#^id]] to point to.
+var blockMarker = regexp.MustCompile(`\s\^([A-Za-z0-9-]+)\s*$`)
+
+// blockAnchor matches the anchor a block's id is replaced with under Options.BlockAnchors.
+var blockAnchor = regexp.MustCompile(`\s<a id="([A-Za-z0-9-]+)"></a>$`)
+
+// blockRef returns the id of the block an anchor such as ^id points to, and whether it is
+// a block reference rather than a heading.
+func blockRef(anchor string) (string, bool) {
+	if !strings.HasPrefix(anchor, "^") {
+		return "", false
+	}
+	return anchor[1:], true
+}
+
+// blockAnchorer replaces the ids at the ends of blocks with anchors as a note's lines
+// are converted, outside of fenced code blocks.
+type blockAnchorer struct {
+	inFence bool
+}
+
+// anchor returns the line with its block id, if it has one, made into an anchor.
+func (a *blockAnchorer) anchor(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+		a.inFence = !a.inFence
+		return line
+	}
+	match := blockMarker.FindStringSubmatchIndex(line)
+	if a.inFence || match == nil {
+		return line
+	}
+	return fmt.Sprintf(`%s <a id="%s"></a>`, line[:match[0]], line[match[2]:match[3]])
+}
+
+// restoreBlockMarker turns the anchor an earlier run made of a block id back into the id.
+func restoreBlockMarker(line string) string {
+	return blockAnchor.ReplaceAllString(line, " ^$1")
+}
@@ -0,0 +1,176 @@
+package backlinker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// cacheLockWait is how long a run waits for another run to finish with the cache.
+	cacheLockWait = 30 * time.Second
+	// cacheLockStale is how old a lock has to be before it's assumed to be left over
+	// from a run that died.
+	cacheLockStale = 10 * time.Minute
+	// manifestFile is the name of the cache file recording what was last written.
+	manifestFile = "manifest.json"
+)
+
+// cacheDir is where the state kept between runs over sourceDir lives: Options.CacheDir
+// or, by default, a directory for the source under the user's cache directory.
+func (o *Options) cacheDir(sourceDir string) (string, error) {
+	if o.CacheDir != "" {
+		return o.CacheDir, nil
+	}
+	userCache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	absSource, err := filepath.Abs(sourceDir)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(absSource))
+	return filepath.Join(userCache, "sharedbrain", hex.EncodeToString(sum[:8])), nil
+}
+
+// runCache is the cache directory, held for the length of a run. A lock file keeps
+// concurrent runs over the same source from using it at the same time.
+type runCache struct {
+	dir  string
+	lock string
+}
+
+// openCache creates the cache directory if need be and waits for the lock on it.
+func openCache(sourceDir string, opts *Options) (*runCache, error) {
+	dir, err := opts.cacheDir(sourceDir)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	cache := &runCache{dir: dir, lock: filepath.Join(dir, "lock")}
+	deadline := time.Now().Add(cacheLockWait)
+	for {
+		lockFile, err := os.OpenFile(cache.lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, _ = fmt.Fprintf(lockFile, "%d\n", os.Getpid())
+			return cache, lockFile.Close()
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(cache.lock); statErr == nil && time.Since(info.ModTime()) > cacheLockStale {
+			_ = os.Remove(cache.lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("cache %s is locked by another run", dir)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// useCache opens the cache for the run when one of the options that keep state between
+// runs is set. The function returned releases it.
+func useCache(sourceDir string, opts *Options) (func(), error) {
+	if !opts.SkipUnchanged && !opts.ResolutionIndex && !opts.Incremental && !opts.RenameAliases {
+		return func() {}, nil
+	}
+	cache, err := openCache(sourceDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.cache = cache
+	return func() {
+		opts.cache = nil
+		_ = cache.close()
+	}, nil
+}
+
+// close releases the lock on the cache.
+func (c *runCache) close() error {
+	return os.Remove(c.lock)
+}
+
+// read decodes the JSON cache file called name into v, leaving v alone when there's no
+// such file yet.
+func (c *runCache) read(name string, v interface{}) error {
+	data, err := ioutil.ReadFile(filepath.Join(c.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// write replaces the cache file called name with v as JSON. It writes to a temporary
+// file first, so that the cache file is never seen half written.
+func (c *runCache) write(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(c.dir, name+".*")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(append(data, '\n'))
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), filepath.Join(c.dir, name))
+}
+
+// outputManifest records the hash of every file written, by its absolute path.
+type outputManifest struct {
+	Files map[string]string `json:"files"`
+}
+
+// writeOutput writes a file of the output. Under Options.SkipUnchanged, a file that is
+// still as the last run wrote it is left alone. Under Options.DryRun, the change to the
+// file is shown instead. A note that's being rewritten in place is backed up first.
+func (o *Options) writeOutput(filename string, data []byte) error {
+	if o.DryRun != nil {
+		existing, err := ioutil.ReadFile(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return writeUnifiedDiff(o.DryRun, filename, string(existing), string(data))
+	}
+	err := o.backUp(filename, data)
+	if err != nil {
+		return err
+	}
+	if o.manifest == nil {
+		return writeFile(filename, data)
+	}
+	absName, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	previous, written := o.manifest.Files[absName]
+	o.manifest.Files[absName] = hash
+	if written && previous == hash {
+		if _, err := os.Stat(filename); err == nil {
+			return nil
+		}
+	}
+	return writeFile(filename, data)
+}
@@ -0,0 +1,90 @@
+package backlinker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkipUnchangedUsesCacheDir(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"First.md":  "Links to [[Second]].\n",
+		"Second.md": "Just a note.\n",
+		"Third.md":  "On its own.\n",
+	})
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	opts := Options{CacheDir: cacheDir, SkipUnchanged: true}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+
+	var manifest outputManifest
+	data, err := ioutil.ReadFile(filepath.Join(cacheDir, manifestFile))
+	require.NoError(err)
+	require.NoError(json.Unmarshal(data, &manifest))
+	require.Len(manifest.Files, 3)
+	require.NoFileExists(filepath.Join(destDir, manifestFile))
+	require.NoFileExists(filepath.Join(cacheDir, "lock"), "The lock is released")
+
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, name := range []string{"First.md", "Second.md", "Third.md"} {
+		require.NoError(os.Chtimes(filepath.Join(destDir, name), past, past))
+	}
+	require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "Third.md"), []byte("Links to [[Second]] too.\n"), 0644))
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+
+	modTime := func(name string) time.Time {
+		info, err := os.Stat(filepath.Join(destDir, name))
+		require.NoError(err)
+		return info.ModTime()
+	}
+	require.Equal(past, modTime("First.md"), "Unchanged output isn't rewritten")
+	require.NotEqual(past, modTime("Second.md"), "A new backlink changes the output")
+	require.NotEqual(past, modTime("Third.md"))
+
+	// Output that has gone missing is written again
+	require.NoError(os.Remove(filepath.Join(destDir, "First.md")))
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	require.FileExists(filepath.Join(destDir, "First.md"))
+}
+
+func TestCacheLocking(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"First.md":  "Links to [[Second]].\n",
+		"Second.md": "Just a note.\n",
+	})
+	cacheDir := t.TempDir()
+	opts := Options{CacheDir: cacheDir, SkipUnchanged: true}
+
+	// A lock left behind by a run that died doesn't stop later runs
+	lock := filepath.Join(cacheDir, "lock")
+	require.NoError(ioutil.WriteFile(lock, []byte("123\n"), 0644))
+	stale := time.Now().Add(-time.Hour)
+	require.NoError(os.Chtimes(lock, stale, stale))
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(err)
+	}
+	var manifest outputManifest
+	data, err := ioutil.ReadFile(filepath.Join(cacheDir, manifestFile))
+	require.NoError(err)
+	require.NoError(json.Unmarshal(data, &manifest))
+	require.Len(manifest.Files, 2)
+}
@@ -0,0 +1,200 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigFile is the name of the configuration file looked for in the working directory.
+const ConfigFile = "sharedbrain.yaml"
+
+// Config is the contents of a configuration file: the directories to work on and the
+// options to use. Directories are relative to the file.
+type Config struct {
+	// Content is the source directory.
+	Content string `yaml:"content"`
+	// Dest is the destination directory.
+	Dest string `yaml:"dest"`
+	// BasePath sets Options.BasePath, the style of the links generated.
+	BasePath string `yaml:"base_path"`
+	// Strict sets Options.Strict.
+	Strict bool `yaml:"strict"`
+	// Labels sets Options.Labels, the headings and titles written.
+	Labels Labels `yaml:"labels"`
+	// SectionHeadingLevel and HideBacklinksHeading set Options.SectionHeadingLevel and
+	// Options.HideBacklinksHeading, how the generated sections are headed.
+	SectionHeadingLevel  int  `yaml:"section_heading_level"`
+	HideBacklinksHeading bool `yaml:"hide_backlinks_heading"`
+	// GroupBacklinks and GroupBacklinksKey set Options.GroupBacklinks and
+	// Options.GroupBacklinksKey, to list the backlinks in groups.
+	GroupBacklinks    BacklinkGrouping `yaml:"group_backlinks"`
+	GroupBacklinksKey string           `yaml:"group_backlinks_key"`
+	// MaxBacklinks and MoreBacklinksDetails set Options.MaxBacklinks and
+	// Options.MoreBacklinksDetails, to shorten long lists of backlinks.
+	MaxBacklinks         int  `yaml:"max_backlinks"`
+	MoreBacklinksDetails bool `yaml:"more_backlinks_details"`
+	// BacklinkSort and ReverseBacklinkSort set Options.BacklinkSort and
+	// Options.ReverseBacklinkSort, the order of the backlinks.
+	BacklinkSort        BacklinkSort `yaml:"backlink_sort"`
+	ReverseBacklinkSort bool         `yaml:"reverse_backlink_sort"`
+	// DateLayouts and DateOutput set Options.Dates, the layouts of the dates in
+	// frontmatter and in the names of date notes.
+	DateLayouts []string `yaml:"date_layouts"`
+	DateOutput  string   `yaml:"date_output"`
+	// GitLastmod sets Options.GitLastmod, to take each note's lastmod from git, and
+	// GitDate sets Options.GitDate, to date the notes without a date by git.
+	GitLastmod bool          `yaml:"git_lastmod"`
+	GitDate    GitDatePolicy `yaml:"git_date"`
+	// Include and Exclude set Options.Include and Options.Exclude, the patterns for the
+	// notes to read and the notes to leave out.
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+	// Recursive sets Options.Recursive, to include the notes in subdirectories.
+	Recursive bool `yaml:"recursive"`
+	// Incremental sets Options.Incremental, to only process what changed since the last
+	// run, and CacheDir sets where what's needed for that is kept.
+	Incremental bool   `yaml:"incremental"`
+	CacheDir    string `yaml:"cache_dir"`
+	// Sections sets Options.Sections, the Hugo sections kinds of notes are written to.
+	Sections Sections `yaml:"sections"`
+	// Target sets Options.Target, the static site generator the output is for.
+	Target Target `yaml:"target"`
+	// Permalink sets Options.Permalink, the pattern of the URLs of the notes for Eleventy,
+	// and BacklinksDataFile sets Options.BacklinksDataFile, a JSON file of the backlinks.
+	Permalink         string `yaml:"permalink"`
+	BacklinksDataFile string `yaml:"backlinks_data_file"`
+	// BacklinksDataKey and BacklinksDataOnly set Options.BacklinksDataKey and
+	// Options.BacklinksDataOnly, to write the backlinks as data rather than markdown.
+	BacklinksDataKey  DataKey `yaml:"backlinks_data_key"`
+	BacklinksDataOnly bool    `yaml:"backlinks_data_only"`
+	// BacklinksShortcode sets Options.BacklinksShortcode, the shortcode that renders the
+	// backlinks from their data.
+	BacklinksShortcode string `yaml:"backlinks_shortcode"`
+	// BacklinksTemplate and BacklinksTemplateFile set Options.BacklinksTemplate and
+	// Options.BacklinksTemplateFile, the template the backlinks are rendered with.
+	BacklinksTemplate     string `yaml:"backlinks_template"`
+	BacklinksTemplateFile string `yaml:"backlinks_template_file"`
+	// HugoConfig sets Options.HugoConfig, the Hugo site configuration links follow.
+	HugoConfig string `yaml:"hugo_config"`
+	// LinkStyle sets Options.LinkStyle, to link to notes with relref or ref shortcodes.
+	LinkStyle LinkStyle `yaml:"link_style"`
+	// PageBundles sets Options.PageBundles, to write each note as a Hugo leaf bundle.
+	PageBundles bool `yaml:"page_bundles"`
+	// RenameAliases sets Options.RenameAliases, to keep the old URLs of renamed notes as
+	// aliases.
+	RenameAliases bool `yaml:"rename_aliases"`
+	// Concurrency sets Options.Concurrency, how many notes are read at once.
+	Concurrency int `yaml:"concurrency"`
+	// LinkSyntax sets Options.LinkSyntax, the ways of writing links besides wikilinks.
+	LinkSyntax LinkSyntax `yaml:"link_syntax"`
+	// UnlinkedMentions sets Options.UnlinkedMentions, to list the notes that mention
+	// each note without linking to it.
+	UnlinkedMentions bool `yaml:"unlinked_mentions"`
+	// OutgoingLinks sets Options.OutgoingLinks, to list the notes each note links to.
+	OutgoingLinks bool `yaml:"outgoing_links"`
+	// RelatedNotesThreshold sets Options.RelatedNotesThreshold, to list the notes that
+	// link to the same notes as each note.
+	RelatedNotesThreshold int `yaml:"related_notes_threshold"`
+	// DefaultFrontmatter sets Options.DefaultFrontmatter, the frontmatter every note
+	// gets when it doesn't have each key itself.
+	DefaultFrontmatter map[string]interface{} `yaml:"default_frontmatter"`
+	// SkipDrafts and DraftLinks set Options.SkipDrafts and Options.DraftLinks, to leave
+	// out drafts and choose what links to them become.
+	SkipDrafts bool            `yaml:"skip_drafts"`
+	DraftLinks DraftLinkPolicy `yaml:"draft_links"`
+	// StubBody sets Options.StubBody, the template of the body of each stub.
+	StubBody string `yaml:"stub_body"`
+	// Stubs and StubClass set Options.Stubs and Options.StubClass, to make no stubs and
+	// choose what the links to missing notes become instead.
+	Stubs     StubPolicy `yaml:"stubs"`
+	StubClass string     `yaml:"stub_class"`
+	// StubMinBacklinks sets Options.StubMinBacklinks, to only make stubs of the notes
+	// linked to often enough.
+	StubMinBacklinks int `yaml:"stub_min_backlinks"`
+}
+
+// LoadConfig reads a configuration file. Unknown keys are an error, so that typos don't
+// go unnoticed.
+func LoadConfig(filename string) (Config, error) {
+	var config Config
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return config, err
+	}
+	err = yaml.UnmarshalStrict(data, &config)
+	if err != nil {
+		return config, err
+	}
+	dir := filepath.Dir(filename)
+	config.Content = resolveConfigDir(dir, config.Content)
+	config.Dest = resolveConfigDir(dir, config.Dest)
+	config.CacheDir = resolveConfigDir(dir, config.CacheDir)
+	config.HugoConfig = resolveConfigDir(dir, config.HugoConfig)
+	config.BacklinksTemplateFile = resolveConfigDir(dir, config.BacklinksTemplateFile)
+	return config, nil
+}
+
+func resolveConfigDir(configDir string, dir string) string {
+	if dir == "" || filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(configDir, dir)
+}
+
+// Options returns the options the configuration sets.
+func (c Config) Options() Options {
+	return Options{
+		BasePath:              c.BasePath,
+		Strict:                c.Strict,
+		Labels:                c.Labels,
+		SectionHeadingLevel:   c.SectionHeadingLevel,
+		HideBacklinksHeading:  c.HideBacklinksHeading,
+		GroupBacklinks:        c.GroupBacklinks,
+		GroupBacklinksKey:     c.GroupBacklinksKey,
+		MaxBacklinks:          c.MaxBacklinks,
+		MoreBacklinksDetails:  c.MoreBacklinksDetails,
+		BacklinkSort:          c.BacklinkSort,
+		ReverseBacklinkSort:   c.ReverseBacklinkSort,
+		Dates:                 DateFormat{Layouts: c.DateLayouts, Output: c.DateOutput},
+		GitLastmod:            c.GitLastmod,
+		GitDate:               c.GitDate,
+		Include:               c.Include,
+		Exclude:               c.Exclude,
+		Recursive:             c.Recursive,
+		Incremental:           c.Incremental,
+		CacheDir:              c.CacheDir,
+		RenameAliases:         c.RenameAliases,
+		PageBundles:           c.PageBundles,
+		LinkStyle:             c.LinkStyle,
+		Sections:              c.Sections,
+		HugoConfig:            c.HugoConfig,
+		Target:                c.Target,
+		Permalink:             c.Permalink,
+		BacklinksDataFile:     c.BacklinksDataFile,
+		BacklinksDataKey:      c.BacklinksDataKey,
+		BacklinksDataOnly:     c.BacklinksDataOnly,
+		BacklinksShortcode:    c.BacklinksShortcode,
+		BacklinksTemplate:     c.BacklinksTemplate,
+		BacklinksTemplateFile: c.BacklinksTemplateFile,
+		Concurrency:           c.Concurrency,
+		LinkSyntax:            c.LinkSyntax,
+		UnlinkedMentions:      c.UnlinkedMentions,
+		OutgoingLinks:         c.OutgoingLinks,
+		RelatedNotesThreshold: c.RelatedNotesThreshold,
+		DefaultFrontmatter:    c.DefaultFrontmatter,
+		SkipDrafts:            c.SkipDrafts,
+		DraftLinks:            c.DraftLinks,
+		StubBody:              c.StubBody,
+		Stubs:                 c.Stubs,
+		StubClass:             c.StubClass,
+		StubMinBacklinks:      c.StubMinBacklinks,
+	}
+}
+
+// ProcessBackLinksWithConfig is ProcessBackLinksWithOptions for the directories and
+// options of a configuration file.
+func ProcessBackLinksWithConfig(config Config) error {
+	return ProcessBackLinksWithOptions(config.Content, config.Dest, config.Options())
+}
@@ -0,0 +1,104 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessWithConfigFile(t *testing.T) {
+	require := require.New(t)
+	dir := t.TempDir()
+	sourceDir := filepath.Join(dir, "notes")
+	require.NoError(os.Mkdir(sourceDir, 0755))
+	notes := map[string]string{
+		"01.02.2024.md": "Planted the [[Garden]].\n",
+		"Garden.md":     "The garden.\n",
+		"Template.md":   "[[Garden]] goes here.\n",
+	}
+	for name, text := range notes {
+		require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, name), []byte(text), 0644))
+	}
+	configFile := filepath.Join(dir, ConfigFile)
+	require.NoError(ioutil.WriteFile(configFile, []byte(`content: notes
+dest: site
+base_path: /brain/
+labels:
+  backlinks: Linked From
+date_layouts: ["02.01.2006"]
+date_output: "2006-01-02"
+exclude: [Template.md]
+`), 0644))
+
+	config, err := LoadConfig(configFile)
+	require.NoError(err)
+	require.Equal(sourceDir, config.Content)
+	require.Equal(filepath.Join(dir, "site"), config.Dest)
+	require.NoError(ProcessBackLinksWithConfig(config))
+
+	garden, err := ioutil.ReadFile(filepath.Join(dir, "site", "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "date: \"2024-02-01\"\n")
+	require.Contains(string(garden), "## Linked From\n\n- [01.02.2024](/brain/01.02.2024/)\n")
+	require.NotContains(string(garden), "Template")
+	require.NoFileExists(filepath.Join(dir, "site", "Template.md"))
+}
+
+func TestConfigFileRejectsUnknownKeys(t *testing.T) {
+	require := require.New(t)
+	configFile := filepath.Join(t.TempDir(), ConfigFile)
+	require.NoError(ioutil.WriteFile(configFile, []byte("content: notes\nbasepath: /brain/\n"), 0644))
+	_, err := LoadConfig(configFile)
+	require.Error(err)
+}
+
+func TestBadExcludePattern(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{"Note.md": "Text.\n"})
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{Exclude: []string{"[unclosed"}})
+	require.Error(err)
+}
+
+func TestIncludeAndExcludePatterns(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "See the [[Daily]] template and my [[Diary]], and the [[Shed]].\n",
+		"Shed.md":   "---\nexclude: true\n---\nMessy, links to the [[Garden]].\n",
+		"Pond.txt":  "Not a note.\n",
+	})
+	nested := map[string]string{
+		"templates/Daily.md": "Links to the [[Garden]].\n",
+		"private/Diary.md":   "About the [[Garden]].\n",
+		"public/Orchard.md":  "Beside the [[Garden]].\n",
+	}
+	for name, text := range nested {
+		filename := filepath.Join(sourceDir, filepath.FromSlash(name))
+		require.NoError(os.MkdirAll(filepath.Dir(filename), 0755))
+		require.NoError(ioutil.WriteFile(filename, []byte(text), 0644))
+	}
+	opts := Options{Recursive: true, Exclude: []string{"templates/", "private"}}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "See the Daily template and my Diary, and the Shed.\n")
+	require.Contains(string(garden), "- [Orchard](./public/orchard/)\n")
+	require.NotContains(string(garden), "Messy")
+	require.NotContains(string(garden), "About the")
+	for _, name := range []string{"Daily.md", "Diary.md", "Shed.md", "templates", "private"} {
+		require.NoFileExists(filepath.Join(destDir, name))
+		require.NoDirExists(filepath.Join(destDir, name))
+	}
+
+	onlyPublic := t.TempDir()
+	opts = Options{Recursive: true, Include: []string{"public/"}}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, onlyPublic, opts))
+	orchard, err := ioutil.ReadFile(filepath.Join(onlyPublic, "public", "Orchard.md"))
+	require.NoError(err)
+	require.Contains(string(orchard), "Beside the Garden.\n")
+	require.NoFileExists(filepath.Join(onlyPublic, "Garden.md"))
+	require.NoFileExists(filepath.Join(onlyPublic, "templates", "Daily.md"))
+}
@@ -0,0 +1,91 @@
+package backlinker
+
+import "strings"
+
+// ContextMergePolicy chooses which context is shown for a note that links to another
+// more than once, when Options.CollapseBacklinks lists it only once.
+type ContextMergePolicy string
+
+const (
+	// ContextsFirst shows the context of the first link.
+	ContextsFirst ContextMergePolicy = ""
+	// ContextsLast shows the context of the last link.
+	ContextsLast ContextMergePolicy = "last"
+	// ContextsLongest shows the longest of the contexts.
+	ContextsLongest ContextMergePolicy = "longest"
+	// ContextsJoined shows every distinct context, one after another.
+	ContextsJoined ContextMergePolicy = "joined"
+	// ContextsNested shows every distinct context as a bullet of its own, under the note.
+	ContextsNested ContextMergePolicy = "nested"
+)
+
+// contextSeparator goes between the contexts joined under ContextsJoined.
+const contextSeparator = " … "
+
+// collapseBacklinks returns one backlink for each note linking here, in the order they
+// first appear, with its context chosen by the policy.
+func collapseBacklinks(backlinks []backlink, policy ContextMergePolicy) []backlink {
+	var collapsed []backlink
+	position := make(map[*markdownFile]int)
+	for _, bl := range backlinks {
+		index, seen := position[bl.OtherFile]
+		if !seen {
+			position[bl.OtherFile] = len(collapsed)
+			collapsed = append(collapsed, bl)
+			continue
+		}
+		existing := &collapsed[index]
+		switch policy {
+		case ContextsLast:
+			*existing = bl
+		case ContextsLongest:
+			if len(bl.Context) > len(existing.Context) {
+				*existing = bl
+			}
+		case ContextsJoined:
+			if !strings.Contains(existing.Context, bl.Context) {
+				existing.Context += contextSeparator + bl.Context
+				// The offset of one link means nothing in the joined text
+				existing.Offset = -1
+			}
+		case ContextsNested:
+			if !hasContext(*existing, bl.Context) {
+				existing.more = append(existing.more, bl)
+			}
+		}
+	}
+	return collapsed
+}
+
+// dedupeBacklinks drops the backlinks that repeat an earlier one's note and context, as
+// when one paragraph links to the same note twice, so that each is listed once.
+func dedupeBacklinks(backlinks []backlink) []backlink {
+	type key struct {
+		file    *markdownFile
+		context string
+	}
+	seen := make(map[key]bool)
+	var deduped []backlink
+	for _, bl := range backlinks {
+		k := key{bl.OtherFile, bl.Context}
+		if !seen[k] {
+			seen[k] = true
+			deduped = append(deduped, bl)
+		}
+	}
+	return deduped
+}
+
+// hasContext is true if the context is already shown for the backlink, under
+// ContextsNested.
+func hasContext(bl backlink, context string) bool {
+	if bl.Context == context {
+		return true
+	}
+	for _, other := range bl.more {
+		if other.Context == context {
+			return true
+		}
+	}
+	return false
+}
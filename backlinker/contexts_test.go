@@ -0,0 +1,69 @@
+package backlinker
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextMergePolicies(t *testing.T) {
+	require := require.New(t)
+	const (
+		short  = "A short [Target](./target/) mention."
+		long   = "A much longer paragraph that mentions [Target](./target/) again."
+		last   = "The last [Target](./target/)."
+		joined = short + " … " + long + " … " + last
+	)
+	expected := map[ContextMergePolicy]string{
+		ContextsFirst:   short,
+		ContextsLast:    last,
+		ContextsLongest: long,
+		ContextsJoined:  joined,
+		ContextsNested:  short + "\n    - " + long + "\n    - " + last,
+	}
+	for policy, context := range expected {
+		sourceDir, destDir := writeVault(t, map[string]string{
+			"Source.md": "A short [[Target]] mention.\n\nA much longer paragraph that mentions [[Target]] again.\n\nThe last [[Target]].\n",
+			"Target.md": "Linked to.\n",
+			"Other.md":  "Also [[Target]].\n",
+		})
+		opts := Options{CollapseBacklinks: true, ContextMerge: policy}
+		require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+
+		target, err := ioutil.ReadFile(filepath.Join(destDir, "Target.md"))
+		require.NoError(err)
+		require.Equal(1, bytes.Count(target, []byte("- [Source](./source/)")), policy)
+		require.Contains(string(target), "- [Source](./source/)\n    - "+context+"\n", policy)
+		require.Contains(string(target), "- [Other](./other/)\n", policy)
+	}
+}
+
+func TestBacklinksNotCollapsedByDefault(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Source.md": "First [[Target]].\n\nSecond [[Target]].\n",
+		"Target.md": "Linked to.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{}))
+
+	target, err := ioutil.ReadFile(filepath.Join(destDir, "Target.md"))
+	require.NoError(err)
+	require.Equal(2, bytes.Count(target, []byte("- [Source](./source/)")))
+}
+
+func TestRepeatedContextsListedOnce(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Source.md": "Both [[Target]] and [[Target|again]].\n\nLater [[Target]].\n",
+		"Target.md": "Linked to.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{}))
+
+	target, err := ioutil.ReadFile(filepath.Join(destDir, "Target.md"))
+	require.NoError(err)
+	require.Equal(2, bytes.Count(target, []byte("- [Source](./source/)")))
+	require.Equal(1, bytes.Count(target, []byte("    - Both [Target](./target/) and [again](./target/).\n")))
+}
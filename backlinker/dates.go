@@ -0,0 +1,120 @@
+package backlinker
+
+import (
+	"time"
+)
+
+// DateFormat configures how dates are read from frontmatter and from the filenames of
+// date notes, and how they're written back out.
+type DateFormat struct {
+	// Layouts are the layouts (in the form time.Parse takes) that dates may be given in,
+	// tried in order. Defaults to RFC 3339, then 2006-01-02.
+	Layouts []string
+	// Output is the layout dates are written into frontmatter with. By default dates are
+	// left as they were written, and the ones made up here are written in RFC 3339.
+	Output string
+	// Location is the time zone of dates that don't give one. Defaults to UTC-5.
+	Location *time.Location
+}
+
+// defaultDateLayouts are the layouts tried when DateFormat.Layouts isn't set.
+var defaultDateLayouts = []string{time.RFC3339, "2006-01-02"}
+
+// defaultDateLocation is the time zone date notes have always been given.
+var defaultDateLocation = time.FixedZone("", -5*60*60)
+
+// filenameDateHour is the time of day given to a date taken from a filename that only
+// names the day.
+const filenameDateHour = 8
+
+func (o *Options) dateLayouts() []string {
+	if len(o.Dates.Layouts) == 0 {
+		return defaultDateLayouts
+	}
+	return o.Dates.Layouts
+}
+
+func (o *Options) dateLocation() *time.Location {
+	if o.Dates.Location == nil {
+		return defaultDateLocation
+	}
+	return o.Dates.Location
+}
+
+// parseDate reads a date in any of the accepted layouts. Dates already written in the
+// Output layout are accepted too, so that output can be read back in.
+func (o *Options) parseDate(text string) (time.Time, bool) {
+	layouts := o.dateLayouts()
+	if o.Dates.Output != "" {
+		layouts = append([]string{o.Dates.Output}, layouts...)
+	}
+	for _, layout := range layouts {
+		if date, err := time.ParseInLocation(layout, text, o.dateLocation()); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// formatDate writes a date in the Output layout.
+func (o *Options) formatDate(date time.Time) string {
+	if o.Dates.Output == "" {
+		return date.Format(time.RFC3339)
+	}
+	return date.Format(o.Dates.Output)
+}
+
+// filenameDate returns the date a filename (without its extension) is named for, if it
+// is named for one.
+func (o *Options) filenameDate(filename string) (time.Time, bool) {
+	date, ok := o.parseDate(removeExtension(filename))
+	if !ok {
+		return time.Time{}, false
+	}
+	if date.Hour() == 0 && date.Minute() == 0 && date.Second() == 0 {
+		date = date.Add(filenameDateHour * time.Hour)
+	}
+	return date, true
+}
+
+// filenameDay returns the date a filename starts with (as in "2024-02-01-notes.md"),
+// or "" if it doesn't start with one.
+func (o *Options) filenameDay(filename string) string {
+	name := removeExtension(filename)
+	for _, layout := range o.dateLayouts() {
+		if len(name) < len(layout) {
+			continue
+		}
+		if _, err := time.Parse(layout, name[:len(layout)]); err == nil {
+			return name[:len(layout)]
+		}
+	}
+	return ""
+}
+
+// metadataDate returns the file's date from its frontmatter, if it has one that can be
+// understood.
+func (o *Options) metadataDate(file *markdownFile) (time.Time, bool) {
+	switch date := file.metadata["date"].(type) {
+	case time.Time:
+		return date, true
+	case string:
+		return o.parseDate(date)
+	}
+	return time.Time{}, false
+}
+
+// outputMetadata returns the frontmatter as it should be written, with its date in the
+// Output layout when one is set.
+func (o *Options) outputMetadata(file *markdownFile) map[string]interface{} {
+	date, ok := o.metadataDate(file)
+	if o.Dates.Output == "" || !ok {
+		return file.metadata
+	}
+	meta := make(map[string]interface{}, len(file.metadata))
+	for key, value := range file.metadata {
+		meta[key] = value
+	}
+	meta["date"] = o.formatDate(date)
+	return meta
+}
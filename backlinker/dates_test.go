@@ -0,0 +1,79 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDateLayouts(t *testing.T) {
+	require := require.New(t)
+	opts := Options{Dates: DateFormat{
+		Layouts: []string{"2006-01-02", "02/01/2006", "Jan 2, 2006", time.RFC3339},
+		Output:  "2006-01-02",
+	}}
+	for _, text := range []string{"2024-02-01", "01/02/2024", "Feb 1, 2024", "2024-02-01T10:30:00-05:00"} {
+		date, ok := opts.parseDate(text)
+		require.True(ok, text)
+		require.Equal("2024-02-01", opts.formatDate(date), text)
+	}
+	_, ok := opts.parseDate("sometime soon")
+	require.False(ok)
+
+	date, ok := (&Options{}).filenameDate("2020-04-26.md")
+	require.True(ok)
+	require.Equal("2020-04-26T08:00:00-05:00", date.Format(time.RFC3339), "The time date notes have always had")
+	_, ok = (&Options{}).filenameDate("2020-04-26-notes.md")
+	require.False(ok)
+	require.Equal("2020-04-26", (&Options{}).filenameDay("2020-04-26-notes.md"))
+
+	compact := Options{Dates: DateFormat{Layouts: []string{"20060102"}}}
+	_, ok = compact.filenameDate("20240201.md")
+	require.True(ok)
+	require.Equal("20240201", compact.filenameDay("20240201 standup.md"))
+	require.Equal("", compact.filenameDay("2024-02-01.md"))
+}
+
+func TestDateFormatAcrossPipeline(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"01.03.2024.md": "Mentions [[Topic]].\n",
+		"Slashes.md":    "---\ndate: 15/02/2024\n---\nMentions [[Topic]].\n",
+		"Words.md":      "---\ndate: Jan 20, 2024\n---\nMentions [[Topic]].\n",
+		"Undated.md":    "Mentions [[Topic]].\n",
+		"Topic.md":      "A topic.\n",
+	})
+	opts := Options{Dates: DateFormat{
+		Layouts:  []string{"02.01.2006", "02/01/2006", "Jan 2, 2006"},
+		Output:   "2006-01-02",
+		Location: time.UTC,
+	}}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+	require.NoError(err)
+
+	expectedDates := map[string]string{
+		"01.03.2024.md": "2024-03-01",
+		"Slashes.md":    "2024-02-15",
+		"Words.md":      "2024-01-20",
+		"Topic.md":      "2024-03-01",
+	}
+	for name, date := range expectedDates {
+		output, err := ioutil.ReadFile(filepath.Join(destDir, name))
+		require.NoError(err)
+		require.Contains(string(output), "\ndate: \""+date+"\"\n", name)
+	}
+
+	topic, err := ioutil.ReadFile(filepath.Join(destDir, "Topic.md"))
+	require.NoError(err)
+	order := []string{"[01.03.2024]", "[Slashes]", "[Words]", "[Undated]"}
+	last := -1
+	for _, title := range order {
+		index := strings.Index(string(topic), title)
+		require.Greater(index, last, "%s is out of order:\n%s", title, topic)
+		last = index
+	}
+}
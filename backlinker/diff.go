@@ -0,0 +1,166 @@
+package backlinker
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// diffContext is how many unchanged lines are shown around each change in a diff.
+const diffContext = 3
+
+// diffLine is a line of a diff: kept (' '), removed ('-') or added ('+').
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// diffLines finds the shortest edit turning a into b, with the Myers algorithm.
+func diffLines(a []string, b []string) []diffLine {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(trace, a, b, offset)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrackDiff follows the edit found by diffLines back from the end of both texts.
+func backtrackDiff(trace [][]int, a []string, b []string, offset int) []diffLine {
+	var reversed []diffLine
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, diffLine{' ', a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, diffLine{'+', b[prevY]})
+			} else {
+				reversed = append(reversed, diffLine{'-', a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	lines := make([]diffLine, len(reversed))
+	for i, line := range reversed {
+		lines[len(reversed)-1-i] = line
+	}
+	return lines
+}
+
+// splitLines splits text into lines, each with its line ending.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// writeUnifiedDiff writes a unified diff of the file changing from before to after.
+// Nothing is written if they're the same.
+func writeUnifiedDiff(w io.Writer, filename string, before string, after string) error {
+	if before == after {
+		return nil
+	}
+	lines := diffLines(splitLines(before), splitLines(after))
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", filename, filename)
+	oldLine, newLine := 0, 0
+	for start := 0; start < len(lines); {
+		if lines[start].kind == ' ' {
+			start++
+			oldLine++
+			newLine++
+			continue
+		}
+		// A hunk runs from a little before this change to a little after the last change
+		// that isn't more than twice the context away from the one before it
+		end := start
+		for i := start; i < len(lines); i++ {
+			if lines[i].kind != ' ' {
+				end = i + 1
+			} else if i-end >= 2*diffContext {
+				break
+			}
+		}
+		from := start - diffContext
+		if from < 0 {
+			from = 0
+		}
+		to := end + diffContext
+		if to > len(lines) {
+			to = len(lines)
+		}
+		oldStart, newStart := oldLine-(start-from), newLine-(start-from)
+		var hunk strings.Builder
+		oldCount, newCount := 0, 0
+		for _, line := range lines[from:to] {
+			if line.kind != '+' {
+				oldCount++
+			}
+			if line.kind != '-' {
+				newCount++
+			}
+			hunk.WriteByte(line.kind)
+			hunk.WriteString(line.text)
+			if !strings.HasSuffix(line.text, "\n") {
+				hunk.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n%s", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount), hunk.String())
+		for _, line := range lines[start:to] {
+			if line.kind != '+' {
+				oldLine++
+			}
+			if line.kind != '-' {
+				newLine++
+			}
+		}
+		start = to
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// hunkRange is the start and length of a hunk, in the form a unified diff has them: the
+// start counts from one, or is the line before for an empty range.
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,71 @@
+package backlinker
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteUnifiedDiff(t *testing.T) {
+	require := require.New(t)
+	writer := bytes.Buffer{}
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	require.NoError(writeUnifiedDiff(&writer, "note.md", before, after))
+	require.Equal(`--- note.md
++++ note.md
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -9,3 +9,4 @@
+ i
+ j
+ k
++l
+`, writer.String())
+
+	writer.Reset()
+	require.NoError(writeUnifiedDiff(&writer, "new.md", "", "x\ny"))
+	require.Equal("--- new.md\n+++ new.md\n@@ -0,0 +1,2 @@\n+x\n+y\n\\ No newline at end of file\n", writer.String())
+
+	writer.Reset()
+	require.NoError(writeUnifiedDiff(&writer, "same.md", before, before))
+	require.Empty(writer.String())
+}
+
+func TestDryRun(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "The garden.\n",
+		"Shed.md":   "Next to the [[Garden]].\n",
+	})
+	existing := "---\ntitle: Garden\n---\nThe garden.\n"
+	require.NoError(ioutil.WriteFile(filepath.Join(destDir, "Garden.md"), []byte(existing), 0644))
+
+	diff := bytes.Buffer{}
+	opts := Options{DryRun: &diff, StubDir: "stubs", LogFile: "sharedbrain.log", LogFileRotate: true}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+	require.NoError(err)
+
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Equal(existing, string(garden), "Nothing is written")
+	require.NoFileExists(filepath.Join(destDir, "Shed.md"))
+	require.NoDirExists(filepath.Join(destDir, "stubs"))
+	entries, err := ioutil.ReadDir(destDir)
+	require.NoError(err)
+	require.Len(entries, 1, "Not even the log file is written")
+
+	gardenFile := filepath.Join(destDir, "Garden.md")
+	require.Contains(diff.String(), "--- "+gardenFile+"\n+++ "+gardenFile+"\n@@ -2,3 +2,12 @@\n title: Garden\n ---\n The garden.\n+\n")
+	require.Contains(diff.String(), "+- [Shed](./shed/)\n")
+	shedFile := filepath.Join(destDir, "Shed.md")
+	require.Contains(diff.String(), "--- "+shedFile+"\n+++ "+shedFile+"\n@@ -0,0 +1,")
+}
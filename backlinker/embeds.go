@@ -0,0 +1,95 @@
+package backlinker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EmbedMode chooses what embeds like ![[page]] turn into.
+type EmbedMode string
+
+const (
+	// EmbedsLink writes embeds as plain links to the note embedded.
+	EmbedsLink EmbedMode = ""
+	// EmbedsInline writes the body of the note embedded (or of the section, for an embed
+	// like ![[page#heading]]) in place of the embed. A block is embedded with the whole
+	// of its note.
+	EmbedsInline EmbedMode = "inline"
+	// EmbedsShortcode writes a Hugo shortcode in place of the embed, given the path of the
+	// note embedded and, for an embed like ![[page#heading]], the heading's id.
+	EmbedsShortcode EmbedMode = "shortcode"
+)
+
+func (o *Options) embedShortcode() string {
+	if o.EmbedShortcode == "" {
+		return "embed"
+	}
+	return o.EmbedShortcode
+}
+
+// expandEmbed returns what Options.Embeds says an embed becomes, and whether it's been
+// expanded at all: embeds of notes that don't exist, or aren't being published, are
+// left to be made into links. embedding is the chain of notes being inlined, starting
+// with the note being converted, so that a note that ends up embedding itself is linked
+// to instead.
+func expandEmbed(link foundLink, fileMap map[string]*markdownFile, embedding []*markdownFile, opts *Options) (string, bool) {
+	if !link.embed || opts.Embeds == EmbedsLink || opts.inPlace {
+		return "", false
+	}
+	file, exists := opts.resolveLink(fileMap, backlinkCollector{}.Normalize(link.Text))
+	if !exists || file.IsNew || file.unpublished || file.unreadable || isEmbedding(file, embedding) {
+		return "", false
+	}
+	_, anchor := splitAnchor(link.Text)
+	if opts.Embeds == EmbedsShortcode {
+		return shortcodeFor(file, anchor, opts), true
+	}
+	lines := strings.Join(embeddedLines(file, anchor, opts), "\n")
+	return convertText(lines, fileMap, append(embedding, file), opts), true
+}
+
+func isEmbedding(file *markdownFile, embedding []*markdownFile) bool {
+	for _, other := range embedding {
+		if other == file {
+			return true
+		}
+	}
+	return false
+}
+
+func shortcodeFor(file *markdownFile, anchor string, opts *Options) string {
+	target := opts.outputPath(file)
+	if _, isBlock := blockRef(anchor); anchor == "" || isBlock {
+		return fmt.Sprintf("{{< %s %q >}}", opts.embedShortcode(), target)
+	}
+	return fmt.Sprintf("{{< %s %q %q >}}", opts.embedShortcode(), target, file.anchorID(anchor))
+}
+
+// embeddedLines returns the lines of the note's own body that an embed brings in: all
+// of it, or just the section under the heading the embed names.
+func embeddedLines(file *markdownFile, anchor string, opts *Options) []string {
+	body := bodyWithoutGeneratedSections(file, opts)
+	if _, isBlock := blockRef(anchor); anchor == "" || isBlock {
+		return trimTrailingBlankLines(append([]string{}, body...))
+	}
+	slug := headingSlug(anchor)
+	var section []string
+	level := 0
+	for _, line := range body {
+		match := atxHeading.FindStringSubmatch(line)
+		if match != nil {
+			depth := strings.Count(match[1], "#")
+			if level > 0 && depth <= level {
+				break
+			}
+			text := headingAttributes.ReplaceAllString(strings.TrimRight(match[2], "# \t"), "")
+			if level == 0 && headingSlug(text) == slug {
+				level = depth
+			}
+		}
+		if level > 0 {
+			section = append(section, line)
+		}
+	}
+	return trimTrailingBlankLines(section)
+}
@@ -0,0 +1,69 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeds(t *testing.T) {
+	require := require.New(t)
+	sourceDir, _ := writeVault(t, map[string]string{
+		"Page.md":   "Before.\n\n![[Recipe]]\n\n![[Recipe#Steps]]\n\nAnd ![[Nowhere]] and `![[Recipe]]`.\n",
+		"Recipe.md": "---\ntags: [food]\n---\nA [[Loop]] recipe.\n\n## Steps\n\n1. Mix.\n\n## Notes\n\nTasty.\n",
+		"Loop.md":   "Loops back: ![[Loop]]\n",
+	})
+	convert := func(opts Options) (string, string) {
+		destDir := t.TempDir()
+		require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+		page, err := ioutil.ReadFile(filepath.Join(destDir, "Page.md"))
+		require.NoError(err)
+		loop, err := ioutil.ReadFile(filepath.Join(destDir, "Loop.md"))
+		require.NoError(err)
+		return string(page), string(loop)
+	}
+
+	page, _ := convert(Options{})
+	require.Contains(page, "Before.\n\n[Recipe](./recipe/)\n\n[Recipe#Steps](./recipe/#steps)\n\nAnd [Nowhere](./nowhere/) and `![[Recipe]]`.\n")
+
+	page, loop := convert(Options{Embeds: EmbedsInline})
+	require.Contains(page, `Before.
+
+A [Loop](./loop/) recipe.
+
+## Steps
+
+1. Mix.
+
+## Notes
+
+Tasty.
+
+## Steps
+
+1. Mix.
+
+And [Nowhere](./nowhere/) and `+"`![[Recipe]]`.\n")
+	require.Contains(loop, "Loops back: [Loop](./loop/)\n", "A note embedding itself links to itself instead")
+
+	page, _ = convert(Options{Embeds: EmbedsShortcode, EmbedShortcode: "include"})
+	require.Contains(page, "Before.\n\n{{< include \"Recipe.md\" >}}\n\n{{< include \"Recipe.md\" \"steps\" >}}\n\nAnd [Nowhere](./nowhere/)")
+}
+
+func TestEmbedCycles(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"A.md": "A starts. ![[B]]\n",
+		"B.md": "B continues. ![[A]]\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{Embeds: EmbedsInline}))
+
+	a, err := ioutil.ReadFile(filepath.Join(destDir, "A.md"))
+	require.NoError(err)
+	require.Contains(string(a), "A starts. B continues. [A](./a/)\n")
+	b, err := ioutil.ReadFile(filepath.Join(destDir, "B.md"))
+	require.NoError(err)
+	require.Contains(string(b), "B continues. A starts. [B](./b/)\n")
+}
@@ -0,0 +1,234 @@
+package backlinker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// frontmatterFormat is one of the formats Hugo takes frontmatter in.
+type frontmatterFormat string
+
+const (
+	// yamlFrontmatter is between --- fences. It's what new frontmatter is written in.
+	yamlFrontmatter frontmatterFormat = ""
+	// tomlFrontmatter is between +++ fences.
+	tomlFrontmatter frontmatterFormat = "toml"
+	// jsonFrontmatter is a JSON object at the very start of the file, with no fences.
+	jsonFrontmatter frontmatterFormat = "json"
+	// noFrontmatter is for files that start with anything else.
+	noFrontmatter frontmatterFormat = "none"
+)
+
+// frontmatterFormatOf returns the format of the frontmatter a file's first line starts.
+// A JSON object has to start with { alone or with a key, so that a shortcode like
+// {{< toc >}} isn't taken for one.
+func frontmatterFormatOf(line string) frontmatterFormat {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case line == "---":
+		return yamlFrontmatter
+	case line == "+++":
+		return tomlFrontmatter
+	case trimmed == "{" || strings.HasPrefix(trimmed, `{"`):
+		return jsonFrontmatter
+	}
+	return noFrontmatter
+}
+
+// noteBody returns the text of a note after its frontmatter, in whichever format it's
+// in, so that the frontmatter isn't parsed as part of the first paragraph. Text whose
+// frontmatter never ends is returned whole, as it's reported when the note is read.
+func noteBody(filetext []byte) []byte {
+	lines := bytes.SplitAfter(filetext, []byte("\n"))
+	format := frontmatterFormatOf(strings.TrimRight(string(lines[0]), "\r\n"))
+	if format == noFrontmatter {
+		return filetext
+	}
+	var front []byte
+	for i, line := range lines {
+		if format == jsonFrontmatter {
+			front = append(front, line...)
+			if json.Valid(front) {
+				return bytes.Join(lines[i+1:], nil)
+			}
+		} else if i > 0 && strings.TrimRight(string(line), "\r\n") == format.fence() {
+			return bytes.Join(lines[i+1:], nil)
+		}
+	}
+	return filetext
+}
+
+// fence is the line that opens and closes the frontmatter, for the formats that have one.
+func (f frontmatterFormat) fence() string {
+	if f == tomlFrontmatter {
+		return "+++"
+	}
+	return "---"
+}
+
+// unmarshal reads the frontmatter (without its fences) into the metadata.
+func (f frontmatterFormat) unmarshal(front []byte, meta map[string]interface{}) error {
+	switch f {
+	case tomlFrontmatter:
+		_, err := toml.Decode(string(front), &meta)
+		return err
+	case jsonFrontmatter:
+		decoder := json.NewDecoder(bytes.NewReader(front))
+		// Numbers are kept as they were written, rather than all becoming floats
+		decoder.UseNumber()
+		return decoder.Decode(&meta)
+	}
+	return yaml.Unmarshal(front, meta)
+}
+
+// write writes the metadata as frontmatter in the format, fences and all. YAML is
+// merged into the frontmatter the file had (see mergeYAML), when it had any.
+func (f frontmatterFormat) write(writer io.Writer, meta map[string]interface{}, original []byte) error {
+	var data []byte
+	var err error
+	switch f {
+	case tomlFrontmatter:
+		var buffer bytes.Buffer
+		err = toml.NewEncoder(&buffer).Encode(stringKeyed(meta))
+		data = buffer.Bytes()
+	case jsonFrontmatter:
+		data, err = json.MarshalIndent(stringKeyed(meta), "", "  ")
+		data = append(data, '\n')
+	default:
+		var merged bool
+		data, merged = mergeYAML(original, meta)
+		if !merged {
+			data, err = yaml.Marshal(meta)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if f == jsonFrontmatter {
+		_, err = writer.Write(data)
+		return err
+	}
+	_, _ = writer.Write([]byte(f.fence() + "\n"))
+	_, _ = writer.Write(data)
+	_, err = writer.Write([]byte(f.fence() + "\n"))
+	return err
+}
+
+// mergeYAML writes the metadata over the YAML frontmatter it was read from, so that
+// what hasn't changed stays just as it was written. Each key keeps its place, and the
+// keys that haven't changed keep their lines, comments and all. Changed keys are
+// written in place of their old lines, and new keys go at the end. It reports whether
+// the frontmatter could be merged at all: it has to be a mapping.
+func mergeYAML(front []byte, meta map[string]interface{}) ([]byte, bool) {
+	var doc yamlv3.Node
+	if len(front) == 0 || yamlv3.Unmarshal(front, &doc) != nil || len(doc.Content) == 0 ||
+		doc.Content[0].Kind != yamlv3.MappingNode {
+		return nil, false
+	}
+	original := make(map[string]interface{})
+	if yaml.Unmarshal(front, original) != nil {
+		return nil, false
+	}
+	lines := strings.SplitAfter(string(front), "\n")
+	pairs := doc.Content[0].Content
+	var starts []int
+	for i := 0; i < len(pairs); i += 2 {
+		starts = append(starts, pairs[i].Line-1)
+	}
+	var merged bytes.Buffer
+	merged.WriteString(strings.Join(lines[:starts[0]], ""))
+	written := make(map[string]bool)
+	for i, start := range starts {
+		end := len(lines)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		key := pairs[2*i].Value
+		block := lines[start:end]
+		// The comments and blank lines after a value go with the key that follows it
+		valueEnd := len(block)
+		for valueEnd > 1 && isCommentOrBlank(block[valueEnd-1]) {
+			valueEnd--
+		}
+		value, kept := meta[key]
+		if kept && reflect.DeepEqual(value, original[key]) {
+			merged.WriteString(strings.Join(block[:valueEnd], ""))
+		} else if kept {
+			data, err := yaml.Marshal(yaml.MapSlice{{Key: key, Value: value}})
+			if err != nil {
+				return nil, false
+			}
+			merged.Write(data)
+		}
+		written[key] = true
+		merged.WriteString(strings.Join(block[valueEnd:], ""))
+	}
+	added := make(map[string]interface{})
+	for key, value := range meta {
+		if !written[key] {
+			added[key] = value
+		}
+	}
+	if len(added) > 0 {
+		data, err := yaml.Marshal(added)
+		if err != nil {
+			return nil, false
+		}
+		if merged.Len() > 0 && !bytes.HasSuffix(merged.Bytes(), []byte("\n")) {
+			merged.WriteString("\n")
+		}
+		merged.Write(data)
+	}
+	return merged.Bytes(), true
+}
+
+func isCommentOrBlank(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
+
+// stringKeyed converts the YAML types that can be in metadata to the ones the TOML and
+// JSON encoders take: maps with string keys, in place of ordered and untyped maps.
+func stringKeyed(value interface{}) interface{} {
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		table := make(map[string]interface{}, len(value))
+		for _, item := range value {
+			table[fmt.Sprint(item.Key)] = stringKeyed(item.Value)
+		}
+		return table
+	case map[interface{}]interface{}:
+		table := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			table[fmt.Sprint(key)] = stringKeyed(item)
+		}
+		return table
+	case map[string]interface{}:
+		table := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			table[key] = stringKeyed(item)
+		}
+		return table
+	case []yaml.MapSlice:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			list[i] = stringKeyed(item)
+		}
+		return list
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			list[i] = stringKeyed(item)
+		}
+		return list
+	}
+	return value
+}
@@ -0,0 +1,144 @@
+package backlinker
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOMLFrontmatter(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "+++\ntitle = \"The Garden\"\ndate = 2024-02-01T08:00:00Z\ntags = [\"plants\", \"outside\"]\n+++\n" +
+			"Plants, and the [[Shed]].\n",
+		"Shed.md": "---\ntitle: The Shed\ndate: 2024-02-02\n---\nNext to the [[Garden]].\n",
+	})
+	opts := Options{BacklinkParams: true}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "+++\n")
+	require.Contains(string(garden), "title = \"The Garden\"\n")
+	require.Contains(string(garden), "date = 2024-02-01T08:00:00Z\n")
+	require.Contains(string(garden), "tags = [\"plants\", \"outside\"]\n")
+	require.Contains(string(garden), "[[backlinks]]\n  date = ")
+	require.Contains(string(garden), "  permalink = \"/shed/\"\n  title = \"The Shed\"\n")
+	require.Contains(string(garden), "+++\nPlants, and the [Shed](./shed/).\n")
+	require.NotContains(string(garden), "---\n")
+	shed, err := ioutil.ReadFile(filepath.Join(destDir, "Shed.md"))
+	require.NoError(err)
+	require.Contains(string(shed), "---\n")
+	require.Contains(string(shed), "- [The Garden](./garden/)\n", "The title is read from TOML too")
+
+	sourceDir, destDir = writeVault(t, map[string]string{
+		"Year.md":   "+++\ntitle = 2024\n+++\nA year of the [[Garden]].\n",
+		"Garden.md": "Plants.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{}))
+	year, err := ioutil.ReadFile(filepath.Join(destDir, "Year.md"))
+	require.NoError(err)
+	require.True(strings.HasPrefix(string(year), "+++\ntitle = 2024\n+++\n"), string(year))
+	garden, err = ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "- [2024](./year/)\n", "A numeric title is a title")
+
+	file := createMarkdownFile("Broken.md", false)
+	scanner := bufio.NewScanner(strings.NewReader("+++\ntitle = \"Open\"\n---\n"))
+	require.Error(extractFrontmatter(file, scanner, &Options{}), "TOML frontmatter ends with +++")
+}
+
+func TestTOMLFrontmatterNotInContexts(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "+++\ntitle = \"The Garden\"\n+++\nPlants, and the [[Shed]].\n",
+		"Shed.md":   "Tools.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{}))
+
+	shed, err := ioutil.ReadFile(filepath.Join(destDir, "Shed.md"))
+	require.NoError(err)
+	require.Contains(string(shed), "- [The Garden](./garden/)\n    - Plants, and the [Shed](./shed/).\n")
+	require.NotContains(string(shed), "+++")
+}
+
+func TestJSONFrontmatter(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "{\n  \"title\": \"The Garden\",\n  \"weight\": 3,\n  \"tags\": [\"plants\"]\n}\nPlants, and the [[Shed]].\n",
+		"Shed.md":   "{\"title\": \"The Shed\"}\n\nNext to the [[Garden]].\n",
+		"Toc.md":    "{{< toc >}}\n\nNo frontmatter.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{}))
+
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.True(strings.HasPrefix(string(garden), "{\n  \"tags\": [\n    \"plants\"\n  ],\n  \"title\": \"The Garden\",\n  \"weight\": 3\n}\n"+
+		"Plants, and the [Shed](./shed/).\n"), string(garden))
+	require.Contains(string(garden), "- [The Shed](./shed/)\n")
+	shed, err := ioutil.ReadFile(filepath.Join(destDir, "Shed.md"))
+	require.NoError(err)
+	require.True(strings.HasPrefix(string(shed), "{\n  \"title\": \"The Shed\"\n}\n\nNext to"), string(shed))
+	toc, err := ioutil.ReadFile(filepath.Join(destDir, "Toc.md"))
+	require.NoError(err)
+	require.True(strings.HasPrefix(string(toc), "---\ntitle: Toc\n---\n{{< toc >}}\n"), string(toc))
+
+	file := createMarkdownFile("Broken.md", false)
+	scanner := bufio.NewScanner(strings.NewReader("{\n  \"title\": \"Open\"\n"))
+	require.Error(extractFrontmatter(file, scanner, &Options{}))
+}
+
+func TestJSONFrontmatterNotInContexts(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "{\n  \"title\": \"The Garden\"\n}\nPlants, and the [[Shed]].\n",
+		"Shed.md":   "Tools.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{}))
+
+	shed, err := ioutil.ReadFile(filepath.Join(destDir, "Shed.md"))
+	require.NoError(err)
+	require.Contains(string(shed), "- [The Garden](./garden/)\n    - Plants, and the [Shed](./shed/).\n")
+	require.NotContains(string(shed), "\"title\"")
+}
+
+func TestNumericTitles(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Year.md":   "{\n  \"title\": 2024\n}\nA year of the [[Garden]].\n",
+		"Season.md": "---\ntitle: 3\n---\nThe third season in the [[Garden]].\n",
+		"Garden.md": "Plants.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{}))
+
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "- [2024](./year/)\n")
+	require.Contains(string(garden), "- [3](./season/)\n")
+	year, err := ioutil.ReadFile(filepath.Join(destDir, "Year.md"))
+	require.NoError(err)
+	require.True(strings.HasPrefix(string(year), "{\n  \"title\": 2024\n}\n"), string(year))
+	season, err := ioutil.ReadFile(filepath.Join(destDir, "Season.md"))
+	require.NoError(err)
+	require.True(strings.HasPrefix(string(season), "---\ntitle: 3\n---\n"), string(season))
+}
+
+func TestYAMLFrontmatterKeepsOrderAndComments(t *testing.T) {
+	require := require.New(t)
+	file := loadFile(t, "Note.md", "---\n# Written by hand\ntitle: Note\ntags: [Go, go]  # the languages\n\n# When\ndate: 2024-02-01\nweight: 3\n---\nBody.\n")
+	writer := bytes.Buffer{}
+	require.NoError(adjustFrontmatter(file, &Options{NormalizeTags: true, ReadingTime: true}, &writer))
+	require.Equal("---\n# Written by hand\ntitle: Note\ntags:\n- go\n\n# When\ndate: 2024-02-01\nweight: 3\n"+
+		"reading_time: 1\nword_count: 1\n---\n", writer.String(), "Only the tags changed, and the new keys go at the end")
+
+	delete(file.metadata, "weight")
+	writer = bytes.Buffer{}
+	require.NoError(file.frontmatter.write(&writer, file.metadata, file.rawFrontmatter))
+	require.NotContains(writer.String(), "weight")
+	require.Contains(writer.String(), "date: 2024-02-01\nreading_time: 1\n")
+}
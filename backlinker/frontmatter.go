@@ -0,0 +1,145 @@
+package backlinker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// authorFor returns the default author for a file: the one configured for the closest
+// directory containing it, or else Options.DefaultAuthor.
+func authorFor(file *markdownFile, opts *Options) string {
+	dir := path.Dir(file.relativePath())
+	for {
+		if author, exists := opts.DirectoryAuthors[dir]; exists {
+			return author
+		}
+		if dir == "." || dir == "/" {
+			break
+		}
+		dir = path.Dir(dir)
+	}
+	return opts.DefaultAuthor
+}
+
+// metadataTitle is the title the frontmatter gives, if it gives one. A title written as a
+// number, such as a year, is still a title, in any of the frontmatter formats.
+func metadataTitle(meta map[string]interface{}) (string, bool) {
+	switch title := meta["title"].(type) {
+	case nil:
+		return "", false
+	case string:
+		return title, true
+	default:
+		return fmt.Sprint(title), true
+	}
+}
+
+// applyDefaultAuthor sets the author of files that don't name one. Stubs have no author.
+func applyDefaultAuthor(file *markdownFile, opts *Options) {
+	if file.IsNew {
+		return
+	}
+	if _, hasAuthor := file.metadata[opts.authorKey()]; hasAuthor {
+		return
+	}
+	if author := authorFor(file, opts); author != "" {
+		file.metadata[opts.authorKey()] = author
+	}
+}
+
+// applyDefaultFrontmatter sets each of the keys in Options.DefaultFrontmatter that the
+// file doesn't have already, stubs included.
+func applyDefaultFrontmatter(file *markdownFile, opts *Options) {
+	for key, value := range opts.DefaultFrontmatter {
+		if _, exists := file.metadata[key]; !exists {
+			file.metadata[key] = value
+		}
+	}
+}
+
+// permalink returns the root-relative URL of the file, or its absolute URL when
+// Options.BasePath is a full URL.
+func (o *Options) permalink(file *markdownFile) string {
+	rooted := *o
+	if rooted.BasePath == "" {
+		rooted.BasePath = "/"
+	}
+	link := rooted.linkTo(file)
+	if parsed, err := url.Parse(o.BasePath); err == nil && parsed.Host != "" {
+		parsed.Path = link
+		return parsed.String()
+	}
+	return link
+}
+
+// backlinkParams describes the files linking to this one in the shape Hugo templates
+// expect: one entry per file, in the order of the Backlinks section.
+func backlinkParams(file *markdownFile, opts *Options) []yaml.MapSlice {
+	backlinks := append([]backlink{}, file.BackLinks...)
+	sortBacklinks(file, backlinks, opts)
+	seen := make(map[*markdownFile]bool)
+	var params []yaml.MapSlice
+	for _, bl := range backlinks {
+		if seen[bl.OtherFile] {
+			continue
+		}
+		seen[bl.OtherFile] = true
+		param := yaml.MapSlice{
+			{Key: "title", Value: bl.OtherFile.Title},
+			{Key: "permalink", Value: opts.permalink(bl.OtherFile)},
+		}
+		if date, ok := opts.metadataDate(bl.OtherFile); ok {
+			param = append(param, yaml.MapItem{Key: "date", Value: opts.formatDate(date)})
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// isDraft is true for notes marked `draft: true` or `publish: false` in their frontmatter.
+func isDraft(file *markdownFile) bool {
+	draft, _ := file.metadata["draft"].(bool)
+	publish, hasPublish := file.metadata["publish"].(bool)
+	return draft || (hasPublish && !publish)
+}
+
+// skipDrafts leaves drafts out of the output: they aren't written and they don't count
+// as backlinks. What happens to links to them is up to Options.DraftLinks.
+func skipDrafts(fileMap map[string]*markdownFile, opts *Options) {
+	for _, file := range includedFiles(fileMap) {
+		if isDraft(file) {
+			file.unpublished = true
+		}
+	}
+	for _, file := range includedFiles(fileMap) {
+		kept := file.BackLinks[:0]
+		for _, bl := range file.BackLinks {
+			if !bl.OtherFile.unpublished {
+				kept = append(kept, bl)
+			}
+		}
+		file.BackLinks = kept
+		if opts.DraftLinks != DraftLinksError {
+			continue
+		}
+		for _, other := range file.forwardLinks {
+			if other.unpublished {
+				opts.warnf(WarnDraftLink, file.OriginalName, "links to draft %s", other.OriginalName)
+			}
+		}
+	}
+}
+
+// contentHash is the SHA-256 (in hex) of the body as the author wrote it, so it only
+// changes when they do: the frontmatter and generated sections aren't part of it.
+func contentHash(file *markdownFile, opts *Options) string {
+	body := strings.Join(bodyWithoutGeneratedSections(file, opts), "\n")
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,253 @@
+package backlinker
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultAuthorOnlyWhereMissing(t *testing.T) {
+	require := require.New(t)
+	opts := Options{DefaultAuthor: "Sam", AuthorKey: "writer"}
+
+	file := loadFile(t, "Note.md", "No frontmatter here.\n")
+	writer := bytes.Buffer{}
+	require.NoError(adjustFrontmatter(file, &opts, &writer))
+	require.Contains(writer.String(), "writer: Sam\n")
+
+	file = loadFile(t, "Note.md", "---\nwriter: Alex\n---\nBody.\n")
+	writer = bytes.Buffer{}
+	require.NoError(adjustFrontmatter(file, &opts, &writer))
+	require.Contains(writer.String(), "writer: Alex\n")
+	require.NotContains(writer.String(), "Sam")
+
+	file = createMarkdownFile("Stub.md", true)
+	writer = bytes.Buffer{}
+	require.NoError(adjustFrontmatter(file, &opts, &writer))
+	require.NotContains(writer.String(), "writer")
+}
+
+func TestDirectoryAuthors(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"top.md": "At the top.\n",
+	})
+	nested := map[string]string{
+		"journal/entry.md":              "An entry.\n",
+		"journal/work/standup/notes.md": "Standup notes.\n",
+		"projects/roadmap.md":           "The roadmap.\n",
+	}
+	for name, text := range nested {
+		filename := filepath.Join(sourceDir, filepath.FromSlash(name))
+		require.NoError(os.MkdirAll(filepath.Dir(filename), 0755))
+		require.NoError(ioutil.WriteFile(filename, []byte(text), 0644))
+	}
+	opts := Options{
+		Recursive:        true,
+		DefaultAuthor:    "Sam",
+		DirectoryAuthors: map[string]string{"journal": "Alex", "journal/work": "Kim"},
+	}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	for name, author := range map[string]string{
+		"journal/entry.md":              "Alex",
+		"journal/work/standup/notes.md": "Kim",
+		"projects/roadmap.md":           "Sam",
+		"top.md":                        "Sam",
+	} {
+		written, err := ioutil.ReadFile(filepath.Join(destDir, filepath.FromSlash(name)))
+		require.NoError(err)
+		require.Contains(string(written), "author: "+author+"\n", name)
+	}
+}
+
+func TestBacklinkParams(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"2020-05-01.md": "Visited [[Gardens]].\n",
+		"Other Note.md": "Also about [[Gardens]] and [[gardens]] again.\n",
+		"Gardens.md":    "About gardens.\n",
+	})
+	opts := Options{BacklinkParams: true, BasePath: "https://example.com/wiki/"}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+	require.NoError(err)
+	gardens, err := ioutil.ReadFile(filepath.Join(destDir, "Gardens.md"))
+	require.NoError(err)
+	require.Contains(string(gardens), `backlinks:
+- title: "2020-05-01"
+  permalink: https://example.com/wiki/2020-05-01/
+  date: "2020-05-01T08:00:00-05:00"
+- title: Other Note
+  permalink: https://example.com/wiki/other-note/
+`)
+
+	file := createMarkdownFile("Gardens.md", false)
+	require.Equal("/gardens/", (&Options{}).permalink(file))
+	require.Equal("/wiki/gardens/", (&Options{BasePath: "wiki"}).permalink(file))
+}
+
+// runDraftVault processes a vault where a published note links to a draft.
+func runDraftVault(t *testing.T, opts Options) (string, error) {
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Published.md":        "See the [[Work In Progress]].\n",
+		"Work In Progress.md": "---\ndraft: true\n---\nNot ready, unlike [[Published]].\n",
+	})
+	opts.SkipDrafts = true
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+	if err != nil {
+		return "", err
+	}
+	require.NoFileExists(t, filepath.Join(destDir, "Work In Progress.md"))
+	published, err := ioutil.ReadFile(filepath.Join(destDir, "Published.md"))
+	require.NoError(t, err)
+	require.NotContains(t, string(published), "Backlinks", "Drafts are not backlinks")
+	return string(published), nil
+}
+
+func TestDraftLinksPlain(t *testing.T) {
+	require := require.New(t)
+	published, err := runDraftVault(t, Options{})
+	require.NoError(err)
+	require.Contains(published, "See the Work In Progress.\n")
+}
+
+func TestDraftLinksKeep(t *testing.T) {
+	require := require.New(t)
+	published, err := runDraftVault(t, Options{DraftLinks: DraftLinksKeep})
+	require.NoError(err)
+	require.Contains(published, "See the [Work In Progress](./work-in-progress/).\n")
+}
+
+func TestDraftLinksError(t *testing.T) {
+	require := require.New(t)
+	report := Report{}
+	published, err := runDraftVault(t, Options{DraftLinks: DraftLinksError, Report: &report})
+	require.NoError(err)
+	require.Contains(published, "See the Work In Progress.\n")
+	require.Equal(1, len(report.Warnings))
+	require.Equal(WarnDraftLink, report.Warnings[0].Kind)
+	require.Equal("Published.md", report.Warnings[0].File)
+
+	_, err = runDraftVault(t, Options{DraftLinks: DraftLinksError, Strict: true})
+	require.Error(err)
+}
+
+func TestPublishFalseFromConfig(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Published.md": "---\npublish: true\n---\nSee the [[Private]] note.\n",
+		"Private.md":   "---\npublish: false\n---\nBack to [[Published]].\n",
+	})
+	configFile := filepath.Join(t.TempDir(), ConfigFile)
+	require.NoError(ioutil.WriteFile(configFile, []byte("skip_drafts: true\ndraft_links: keep\n"), 0644))
+	config, err := LoadConfig(configFile)
+	require.NoError(err)
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, config.Options()))
+
+	require.NoFileExists(filepath.Join(destDir, "Private.md"))
+	published, err := ioutil.ReadFile(filepath.Join(destDir, "Published.md"))
+	require.NoError(err)
+	require.Contains(string(published), "See the [Private](./private/) note.\n")
+	require.NotContains(string(published), "Backlinks")
+}
+
+func TestContentHash(t *testing.T) {
+	require := require.New(t)
+	opts := Options{ContentHash: true}
+	hashOf := func(text string) string {
+		writer := bytes.Buffer{}
+		require.NoError(adjustFrontmatter(loadFile(t, "Note.md", text), &opts, &writer))
+		for _, line := range strings.Split(writer.String(), "\n") {
+			if strings.HasPrefix(line, "content_hash: ") {
+				return strings.TrimPrefix(line, "content_hash: ")
+			}
+		}
+		return ""
+	}
+
+	original := hashOf("---\ntitle: Note\n---\nSome words.\n")
+	require.Len(original, 64)
+	require.Equal(original, hashOf("---\ntitle: Note\n---\nSome words.\n"))
+	require.Equal(original, hashOf("---\ntitle: Note\ncontent_hash: stale\n---\nSome words.\n"),
+		"The earlier hash isn't part of the content")
+	require.Equal(original, hashOf("---\ntitle: Note\n---\nSome words.\n\n## Backlinks\n\n- [Other](./other/)\n"),
+		"Generated sections aren't part of the content")
+	require.NotEqual(original, hashOf("---\ntitle: Note\n---\nSome other words.\n"))
+
+	opts.ContentHashKey = "etag"
+	writer := bytes.Buffer{}
+	require.NoError(adjustFrontmatter(loadFile(t, "Note.md", "Some words.\n"), &opts, &writer))
+	require.Contains(writer.String(), "etag: "+original+"\n")
+}
+
+func TestContentHashStableAcrossRuns(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"First.md":  "Links to [[Second]].\n",
+		"Second.md": "Just a note.\n",
+	})
+	opts := Options{ContentHash: true}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	firstRun, err := ioutil.ReadFile(filepath.Join(destDir, "Second.md"))
+	require.NoError(err)
+
+	// Second's output, backlinks and all, becomes the source of the next run
+	require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "Second.md"), firstRun, 0644))
+	againDir := t.TempDir()
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, againDir, opts))
+	secondRun, err := ioutil.ReadFile(filepath.Join(againDir, "Second.md"))
+	require.NoError(err)
+	require.Equal(string(firstRun), string(secondRun))
+	require.Contains(string(secondRun), "content_hash: ")
+}
+
+func TestDefaultFrontmatterFromConfig(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "---\ntype: orchard\n---\nPlants by the [[Shed]].\n",
+		"Pond.md":   "Still water.\n",
+	})
+	configFile := filepath.Join(t.TempDir(), ConfigFile)
+	require.NoError(ioutil.WriteFile(configFile, []byte("default_frontmatter:\n  layout: note\n  type: garden\n"), 0644))
+	config, err := LoadConfig(configFile)
+	require.NoError(err)
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, config.Options()))
+
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "---\ntype: orchard\nlayout: note\ntitle: Garden\n---\n", "The note's own type wins")
+	pond, err := ioutil.ReadFile(filepath.Join(destDir, "Pond.md"))
+	require.NoError(err)
+	require.Contains(string(pond), "---\nlayout: note\ntitle: Pond\ntype: garden\n---\n")
+	shed, err := ioutil.ReadFile(filepath.Join(destDir, "Shed.md"))
+	require.NoError(err)
+	require.Contains(string(shed), "layout: note\n", "Stubs get the defaults too")
+}
+
+func TestLinksUseSlugAndURL(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Index.md":  "See the [[Garden]], [[About]] and the [[Plan#Goals]].\n",
+		"Garden.md": "---\nslug: My Garden\n---\nBack to the [[Index]].\n",
+		"About.md":  "---\nurl: /about-me/\n---\nWho I am.\n",
+	})
+	require.NoError(os.Mkdir(filepath.Join(sourceDir, "projects"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "projects", "Plan.md"),
+		[]byte("---\nslug: the-plan\n---\n## Goals\n"), 0644))
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{Recursive: true}))
+
+	index, err := ioutil.ReadFile(filepath.Join(destDir, "Index.md"))
+	require.NoError(err)
+	require.Contains(string(index), "See the [Garden](./my-garden/), [About](./about-me/) and the [Plan#Goals](./projects/the-plan/#goals).\n")
+	require.Contains(string(index), "- [Garden](./my-garden/)\n")
+
+	rooted := t.TempDir()
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, rooted, Options{Recursive: true, BasePath: "/wiki/"}))
+	index, err = ioutil.ReadFile(filepath.Join(rooted, "Index.md"))
+	require.NoError(err)
+	require.Contains(string(index), "[About](/wiki/about-me/)")
+}
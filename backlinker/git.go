@@ -0,0 +1,83 @@
+package backlinker
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// gitDates are the dates of the first and the last commits to a file.
+type gitDates struct {
+	first time.Time
+	last  time.Time
+}
+
+// readGitHistory finds when each file in the source directory was committed, under
+// Options.GitLastmod and Options.GitDate. It takes one run of git log over the whole directory. When there's
+// no git, or the directory isn't in a repository, it warns and the notes go without.
+func readGitHistory(sourceDir string, opts *Options) {
+	if !opts.GitLastmod && opts.GitDate == GitDateOff {
+		return
+	}
+	// Paths with spaces or accents are written as they are rather than quoted
+	cmd := exec.Command("git", "-c", "core.quotepath=off", "log", "--format=%x00%cI", "--name-only",
+		"--relative", "--", ".")
+	cmd.Dir = sourceDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		message := strings.SplitN(strings.TrimSpace(stderr.String()), "\n", 2)[0]
+		if message == "" {
+			message = err.Error()
+		}
+		opts.warnf(WarnBadConfig, sourceDir, "can't read the git history: %s", message)
+		return
+	}
+	opts.gitHistory = parseGitLog(string(output))
+}
+
+// parseGitLog reads git log output in which each commit is its date, after a NUL, and
+// then the files it changed. Commits come newest first.
+func parseGitLog(output string) map[string]gitDates {
+	history := make(map[string]gitDates)
+	var date time.Time
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "\x00") {
+			date, _ = time.Parse(time.RFC3339, strings.TrimPrefix(line, "\x00"))
+			continue
+		}
+		if line == "" || date.IsZero() {
+			continue
+		}
+		dates, seen := history[line]
+		if !seen {
+			dates.last = date
+		}
+		dates.first = date
+		history[line] = dates
+	}
+	return history
+}
+
+// applyGitLastmod sets the file's lastmod to the date of its last commit, under
+// Options.GitLastmod. Files that were never committed keep whatever lastmod they have.
+func applyGitLastmod(file *markdownFile, opts *Options) {
+	if file.IsNew || file.generated {
+		return
+	}
+	if dates, exists := opts.gitHistory[file.OriginalName]; exists {
+		file.metadata[opts.frontmatterKey("lastmod")] = opts.formatDate(dates.last)
+	}
+}
+
+// gitDate is the date of the file's first commit, for a file that has no date of its
+// own, when Options.GitDate gives it one.
+func gitDate(file *markdownFile, opts *Options) (time.Time, bool) {
+	if opts.GitDate == GitDateOff || file.IsNew || file.generated {
+		return time.Time{}, false
+	}
+	dates, exists := opts.gitHistory[file.OriginalName]
+	return dates.first, exists
+}
@@ -0,0 +1,102 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// initRepo makes the directory a git repository.
+func initRepo(t *testing.T, dir string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = dir
+	require.NoError(t, cmd.Run())
+}
+
+// commitAll commits everything in the directory, as of the date given.
+func commitAll(t *testing.T, dir string, date string) {
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "-q", "-m", "Notes"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+			"GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+}
+
+func TestLastmodFromGit(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "Plants by the [[Shed]].\n",
+		"Pond.md":   "Still water.\n",
+	})
+	initRepo(t, sourceDir)
+	commitAll(t, sourceDir, "2024-03-01T10:00:00+00:00")
+	require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "Garden.md"), []byte("Plants by the [[Pond]].\n"), 0644))
+	commitAll(t, sourceDir, "2024-05-20T18:30:00+02:00")
+	require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "Orchard.md"), []byte("Not committed yet.\n"), 0644))
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{GitLastmod: true}))
+
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "lastmod: \"2024-05-20T18:30:00+02:00\"\n")
+	pond, err := ioutil.ReadFile(filepath.Join(destDir, "Pond.md"))
+	require.NoError(err)
+	require.Contains(string(pond), "lastmod: \"2024-03-01T10:00:00Z\"\n")
+	orchard, err := ioutil.ReadFile(filepath.Join(destDir, "Orchard.md"))
+	require.NoError(err)
+	require.NotContains(string(orchard), "lastmod", "Notes that were never committed have no lastmod")
+}
+
+func TestLastmodOutsideGit(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "Plants.\n",
+	})
+	report := &Report{}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{GitLastmod: true, Report: report}))
+	require.Len(report.Warnings, 1)
+	require.Equal(WarnBadConfig, report.Warnings[0].Kind)
+}
+
+func TestDateFromGit(t *testing.T) {
+	require := require.New(t)
+	sourceDir, _ := writeVault(t, map[string]string{
+		"Journal.md": "---\ndate: 2024-06-01\n---\nWorked in the [[Garden]].\n",
+		"Garden.md":  "Plants.\n",
+		"Pond.md":    "Still water.\n",
+	})
+	initRepo(t, sourceDir)
+	commitAll(t, sourceDir, "2024-03-01T10:00:00+00:00")
+
+	for policy, gardenDate := range map[GitDatePolicy]string{
+		GitDateOff:      "date: 2024-06-01T00:00:00-05:00\n",
+		GitDateFallback: "date: 2024-06-01T00:00:00-05:00\n",
+		GitDateFirst:    "date: 2024-03-01T10:00:00Z\n",
+	} {
+		destDir := t.TempDir()
+		require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{GitDate: policy}))
+		garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+		require.NoError(err)
+		require.Contains(string(garden), gardenDate, "Under %q", policy)
+		pond, err := ioutil.ReadFile(filepath.Join(destDir, "Pond.md"))
+		require.NoError(err)
+		if policy == GitDateOff {
+			require.NotContains(string(pond), "date:", "Nothing links to the pond")
+		} else {
+			require.Contains(string(pond), "date: 2024-03-01T10:00:00Z\n", "The pond has only its commit to go by")
+		}
+		journal, err := ioutil.ReadFile(filepath.Join(destDir, "Journal.md"))
+		require.NoError(err)
+		require.Contains(string(journal), "date: 2024-06-01\n", "The journal's own date is kept under %q", policy)
+	}
+}
@@ -0,0 +1,168 @@
+package backlinker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// linkingFiles returns the distinct files that link to file, in the order their
+// backlinks were found.
+func linkingFiles(file *markdownFile) []*markdownFile {
+	seen := make(map[*markdownFile]bool)
+	result := make([]*markdownFile, 0, len(file.BackLinks))
+	for _, bl := range file.BackLinks {
+		if seen[bl.OtherFile] {
+			continue
+		}
+		seen[bl.OtherFile] = true
+		result = append(result, bl.OtherFile)
+	}
+	return result
+}
+
+// indirectBacklink is a file that links to this one through one or more other files.
+type indirectBacklink struct {
+	OtherFile *markdownFile
+	// Via is the file we reach on the way to this one.
+	Via *markdownFile
+	// Degree is how many links away OtherFile is. Direct backlinks are degree 1.
+	Degree int
+}
+
+// collectIndirectBacklinks walks the backlinks of file breadth first, up to maxDegree links
+// away, and returns the files found beyond the direct backlinks. Every file is only
+// reported once, at the shortest distance, so cycles end the walk.
+func collectIndirectBacklinks(file *markdownFile, maxDegree int) []indirectBacklink {
+	visited := map[*markdownFile]bool{file: true}
+	frontier := linkingFiles(file)
+	for _, other := range frontier {
+		visited[other] = true
+	}
+	var result []indirectBacklink
+	for degree := 2; degree <= maxDegree && len(frontier) > 0; degree++ {
+		var next []*markdownFile
+		for _, via := range frontier {
+			for _, other := range linkingFiles(via) {
+				if visited[other] {
+					continue
+				}
+				visited[other] = true
+				result = append(result, indirectBacklink{OtherFile: other, Via: via, Degree: degree})
+				next = append(next, other)
+			}
+		}
+		frontier = next
+	}
+	return result
+}
+
+// secondDegreeNote is a file that links to some of the same files as another.
+type secondDegreeNote struct {
+	OtherFile *markdownFile
+	// Shared are the files both link to, in the order the other file first links to them.
+	Shared []*markdownFile
+}
+
+// collectRelatedNotes returns the files that link to at least threshold of the files
+// that file links to, in the order they're first found. Drafts that are being left out
+// are neither related nor shared, since links to them are plain text.
+func collectRelatedNotes(file *markdownFile, threshold int) []secondDegreeNote {
+	var result []secondDegreeNote
+	position := make(map[*markdownFile]int)
+	for _, target := range file.forwardLinks {
+		if target == file || target.unpublished || target.unwritten {
+			continue
+		}
+		for _, other := range linkingFiles(target) {
+			if other == file || other.unpublished {
+				continue
+			}
+			index, seen := position[other]
+			if !seen {
+				index = len(result)
+				position[other] = index
+				result = append(result, secondDegreeNote{OtherFile: other})
+			}
+			result[index].Shared = append(result[index].Shared, target)
+		}
+	}
+	kept := result[:0]
+	for _, rn := range result {
+		if len(rn.Shared) >= threshold {
+			kept = append(kept, rn)
+		}
+	}
+	return kept
+}
+
+// linkEdge is a link from one file to another.
+type linkEdge struct {
+	From *markdownFile
+	To   *markdownFile
+}
+
+// collectEdges returns every distinct link between two files, including those made by
+// relations, ordered by the names of the files at each end.
+func collectEdges(fileMap map[string]*markdownFile) []linkEdge {
+	var edges []linkEdge
+	seen := make(map[linkEdge]bool)
+	for _, to := range sortedFiles(fileMap) {
+		for _, from := range linkingFiles(to) {
+			edges = append(edges, linkEdge{From: from, To: to})
+			seen[linkEdge{From: from, To: to}] = true
+		}
+	}
+	for _, from := range sortedFiles(fileMap) {
+		for _, to := range from.relatedLinks {
+			if edge := (linkEdge{From: from, To: to}); !seen[edge] {
+				edges = append(edges, edge)
+				seen[edge] = true
+			}
+		}
+	}
+	sort.SliceStable(edges, func(i, j int) bool {
+		fromI := strings.ToLower(edges[i].From.OriginalName)
+		fromJ := strings.ToLower(edges[j].From.OriginalName)
+		if fromI != fromJ {
+			return fromI < fromJ
+		}
+		return strings.ToLower(edges[i].To.OriginalName) < strings.ToLower(edges[j].To.OriginalName)
+	})
+	return edges
+}
+
+// lookupFile finds a file by the name that would be used to link to it.
+func lookupFile(fileMap map[string]*markdownFile, name string) (*markdownFile, error) {
+	file, exists := fileMap[backlinkCollector{}.Normalize(removeExtension(name))]
+	if !exists {
+		return nil, fmt.Errorf("no file named %s", name)
+	}
+	return file, nil
+}
+
+// neighborhood does a bounded breadth first search from root, following links in both
+// directions, and returns each file found within depth links along with its distance.
+func neighborhood(fileMap map[string]*markdownFile, root *markdownFile, depth int) map[*markdownFile]int {
+	adjacent := make(map[*markdownFile][]*markdownFile)
+	for _, edge := range collectEdges(fileMap) {
+		adjacent[edge.From] = append(adjacent[edge.From], edge.To)
+		adjacent[edge.To] = append(adjacent[edge.To], edge.From)
+	}
+	distances := map[*markdownFile]int{root: 0}
+	frontier := []*markdownFile{root}
+	for distance := 1; distance <= depth && len(frontier) > 0; distance++ {
+		var next []*markdownFile
+		for _, file := range frontier {
+			for _, other := range adjacent[file] {
+				if _, seen := distances[other]; seen {
+					continue
+				}
+				distances[other] = distance
+				next = append(next, other)
+			}
+		}
+		frontier = next
+	}
+	return distances
+}
@@ -0,0 +1,100 @@
+package backlinker
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// linkFiles creates a file map from the given files and collects the backlinks
+// from each file's text.
+func linkFiles(files map[string]string) map[string]*markdownFile {
+	fileMap := make(map[string]*markdownFile)
+	for name := range files {
+		fileMap[normalizedName(name)] = createMarkdownFile(name, false)
+	}
+	for name, text := range files {
+		collectBacklinksForFile(fileMap, fileMap[normalizedName(name)], []byte(text), &Options{})
+	}
+	return fileMap
+}
+
+func normalizedName(filename string) string {
+	return backlinkCollector{}.Normalize(removeExtension(filename))
+}
+
+func TestIndirectBacklinks(t *testing.T) {
+	require := require.New(t)
+	fileMap := linkFiles(map[string]string{
+		"A.md": "A links to [[B]].\n",
+		"B.md": "B links to [[C]].\n",
+		"C.md": "C links back to [[A]].\n",
+		"D.md": "D links to [[C]] and [[B]].\n",
+	})
+	indirect := collectIndirectBacklinks(fileMap["c.md"], 2)
+	require.Equal(1, len(indirect), "D is already a direct backlink and C itself is excluded")
+	require.Equal("A.md", indirect[0].OtherFile.OriginalName)
+	require.Equal("B.md", indirect[0].Via.OriginalName)
+	require.Equal(2, indirect[0].Degree)
+
+	writer := bytes.Buffer{}
+	err := addIndirectBacklinks(fileMap["c.md"], &Options{IndirectBacklinkDepth: 2}, &writer)
+	require.Nil(err)
+	require.Equal(`
+## Indirect Backlinks
+
+- [A](./a/) via [B](./b/)
+`, writer.String())
+}
+
+func TestIndirectBacklinksDepthAndCycles(t *testing.T) {
+	require := require.New(t)
+	fileMap := linkFiles(map[string]string{
+		"A.md": "[[B]]\n",
+		"B.md": "[[C]]\n",
+		"C.md": "[[D]]\n",
+		"D.md": "[[A]]\n",
+	})
+	require.Equal(1, len(collectIndirectBacklinks(fileMap["d.md"], 2)))
+	indirect := collectIndirectBacklinks(fileMap["d.md"], 10)
+	require.Equal(2, len(indirect), "The cycle back to D must not be followed")
+	require.Equal("B.md", indirect[0].OtherFile.OriginalName)
+	require.Equal("A.md", indirect[1].OtherFile.OriginalName)
+	require.Equal(3, indirect[1].Degree)
+
+	writer := bytes.Buffer{}
+	err := addIndirectBacklinks(fileMap["d.md"], &Options{}, &writer)
+	require.Nil(err)
+	require.Equal("", writer.String())
+}
+
+func TestRelatedNotes(t *testing.T) {
+	require := require.New(t)
+	fileMap := linkFiles(map[string]string{
+		"A.md": "A links to [[X]], [[Y]] and [[Z]].\n",
+		"B.md": "B links to [[Z]] and [[Y]].\n",
+		"C.md": "C links to [[X]].\n",
+		"D.md": "D links to [[X]], [[Y]] and [[Z]] too.\n",
+		"X.md": "X links to nothing.\n",
+		"Y.md": "Y links to [[A]].\n",
+		"Z.md": "Z links to nothing.\n",
+	})
+	related := collectRelatedNotes(fileMap["a.md"], 2)
+	require.Equal(2, len(related), "C shares only X, and A itself is left out")
+
+	writer := bytes.Buffer{}
+	err := addRelatedNotes(fileMap["a.md"], &Options{RelatedNotesThreshold: 2}, &writer)
+	require.Nil(err)
+	require.Equal(`
+## Related Notes
+
+- [D](./d/) via [X](./x/), [Y](./y/), [Z](./z/)
+- [B](./b/) via [Y](./y/), [Z](./z/)
+`, writer.String())
+
+	writer.Reset()
+	err = addRelatedNotes(fileMap["a.md"], &Options{}, &writer)
+	require.Nil(err)
+	require.Equal("", writer.String())
+}
@@ -0,0 +1,74 @@
+package backlinker
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// graphJSON is the JSON form of (part of) the link graph, in the nodes and links shape
+// that most graph widgets expect.
+type graphJSON struct {
+	Root  string          `json:"root,omitempty"`
+	Nodes []graphJSONNode `json:"nodes"`
+	Links []graphJSONLink `json:"links"`
+}
+
+type graphJSONNode struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	// Distance is how many links away from the root the node is.
+	Distance int  `json:"distance"`
+	IsNew    bool `json:"isNew,omitempty"`
+}
+
+type graphJSONLink struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// graphNodeID is the id a file has in exported graphs: its lower case name.
+func graphNodeID(file *markdownFile) string {
+	return strings.ToLower(removeExtension(file.OriginalName))
+}
+
+// WriteLocalGraphJSON writes the part of the link graph within depth links of root,
+// following links in both directions, as JSON. Only the links between files in that
+// neighborhood are included.
+func WriteLocalGraphJSON(files FileMap, root string, depth int, w io.Writer) error {
+	rootFile, err := lookupFile(files, root)
+	if err != nil {
+		return err
+	}
+	distances := neighborhood(files, rootFile, depth)
+	opts := Options{}
+	graph := graphJSON{
+		Root:  graphNodeID(rootFile),
+		Nodes: []graphJSONNode{},
+		Links: []graphJSONLink{},
+	}
+	for _, file := range sortedFiles(files) {
+		distance, included := distances[file]
+		if !included {
+			continue
+		}
+		graph.Nodes = append(graph.Nodes, graphJSONNode{
+			ID:       graphNodeID(file),
+			Title:    file.Title,
+			URL:      opts.linkTo(file),
+			Distance: distance,
+			IsNew:    file.IsNew,
+		})
+	}
+	for _, edge := range collectEdges(files) {
+		_, hasFrom := distances[edge.From]
+		_, hasTo := distances[edge.To]
+		if hasFrom && hasTo {
+			graph.Links = append(graph.Links, graphJSONLink{Source: graphNodeID(edge.From), Target: graphNodeID(edge.To)})
+		}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(graph)
+}
@@ -0,0 +1,46 @@
+package backlinker
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func localGraph(t *testing.T, fileMap map[string]*markdownFile, root string, depth int) graphJSON {
+	writer := bytes.Buffer{}
+	require.NoError(t, WriteLocalGraphJSON(fileMap, root, depth, &writer))
+	var graph graphJSON
+	require.NoError(t, json.Unmarshal(writer.Bytes(), &graph))
+	return graph
+}
+
+func TestWriteLocalGraphJSON(t *testing.T) {
+	require := require.New(t)
+	fileMap := linkFiles(map[string]string{
+		"A.md": "[[B]]\n",
+		"B.md": "[[C]]\n",
+		"C.md": "[[D]]\n",
+		"D.md": "[[E]]\n",
+		"E.md": "Nothing.\n",
+	})
+
+	graph := localGraph(t, fileMap, "C", 1)
+	require.Equal("c", graph.Root)
+	require.Equal([]graphJSONNode{
+		{ID: "b", Title: "B", URL: "./b/", Distance: 1},
+		{ID: "c", Title: "C", URL: "./c/", Distance: 0},
+		{ID: "d", Title: "D", URL: "./d/", Distance: 1},
+	}, graph.Nodes)
+	require.Equal([]graphJSONLink{{Source: "b", Target: "c"}, {Source: "c", Target: "d"}}, graph.Links)
+
+	graph = localGraph(t, fileMap, "c", 2)
+	require.Equal(5, len(graph.Nodes))
+	require.Equal(graphJSONNode{ID: "a", Title: "A", URL: "./a/", Distance: 2}, graph.Nodes[0])
+	require.Equal(4, len(graph.Links))
+
+	graph = localGraph(t, fileMap, "A", 0)
+	require.Equal(1, len(graph.Nodes))
+	require.Equal(0, len(graph.Links))
+}
@@ -0,0 +1,79 @@
+package backlinker
+
+import "sort"
+
+// BacklinkGrouping chooses how the backlinks list is divided under subheadings.
+type BacklinkGrouping string
+
+const (
+	// GroupNone lists the backlinks together.
+	GroupNone BacklinkGrouping = ""
+	// GroupByFolder groups the backlinks by the directory the linking note is in.
+	GroupByFolder BacklinkGrouping = "folder"
+	// GroupByKey groups the backlinks by the value of Options.GroupBacklinksKey in the
+	// linking note's frontmatter. A note with a list of values, such as its tags, is listed
+	// under each of them.
+	GroupByKey BacklinkGrouping = "key"
+)
+
+// backlinkGroup is the backlinks listed under one subheading, or under none when its
+// name is empty.
+type backlinkGroup struct {
+	name      string
+	backlinks []backlink
+}
+
+// groupBacklinks divides the backlinks as Options.GroupBacklinks asks, keeping their
+// order within each group. The backlinks of notes without a group come first, with no
+// subheading, and the groups follow in order of their names.
+func groupBacklinks(backlinks []backlink, opts *Options) []backlinkGroup {
+	if opts.GroupBacklinks == GroupNone {
+		return []backlinkGroup{{backlinks: backlinks}}
+	}
+	var ungrouped []backlink
+	byName := make(map[string][]backlink)
+	var names []string
+	for _, bl := range backlinks {
+		groups := opts.backlinkGroupNames(bl.OtherFile)
+		if len(groups) == 0 {
+			ungrouped = append(ungrouped, bl)
+		}
+		for _, name := range groups {
+			if _, exists := byName[name]; !exists {
+				names = append(names, name)
+			}
+			byName[name] = append(byName[name], bl)
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		return opts.compareTitles(names[i], names[j]) < 0
+	})
+	var result []backlinkGroup
+	if len(ungrouped) > 0 {
+		result = append(result, backlinkGroup{backlinks: ungrouped})
+	}
+	for _, name := range names {
+		result = append(result, backlinkGroup{name: name, backlinks: byName[name]})
+	}
+	return result
+}
+
+// backlinkGroupNames are the groups a note's backlinks are listed in.
+func (o *Options) backlinkGroupNames(file *markdownFile) []string {
+	switch o.GroupBacklinks {
+	case GroupByFolder:
+		if file.dir == "" {
+			return nil
+		}
+		return []string{file.dir}
+	case GroupByKey:
+		var names []string
+		for _, name := range metadataStrings(file, o.GroupBacklinksKey) {
+			if name != "" && !containsString(names, name) {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
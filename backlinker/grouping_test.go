@@ -0,0 +1,74 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupBacklinksByFolder(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "Plants.\n",
+		"Pond.md":   "By the [[Garden]].\n",
+	})
+	nested := map[string]string{
+		"Projects/Fence.md":     "Around the [[Garden]].\n",
+		"Journal/2024-06-01.md": "Weeded the [[Garden]].\n",
+	}
+	for name, text := range nested {
+		filename := filepath.Join(sourceDir, filepath.FromSlash(name))
+		require.NoError(os.MkdirAll(filepath.Dir(filename), 0755))
+		require.NoError(ioutil.WriteFile(filename, []byte(text), 0644))
+	}
+	opts := Options{Recursive: true, GroupBacklinks: GroupByFolder}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "\n## Backlinks\n\n"+
+		"- [Pond](./pond/)\n    - By the [Garden](./garden/).\n\n"+
+		"### Journal\n\n- [2024-06-01](./journal/2024-06-01/)\n    - Weeded the [Garden](./garden/).\n\n"+
+		"### Projects\n\n- [Fence](./projects/fence/)\n    - Around the [Garden](./garden/).\n")
+}
+
+func TestGroupBacklinksByKey(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "Plants.\n",
+		"Pond.md":   "---\ntags: [water, wildlife]\n---\nBy the [[Garden]].\n",
+		"Shed.md":   "---\ntags: tools\n---\nNext to the [[Garden]].\n",
+	})
+	opts := Options{GroupBacklinks: GroupByKey, GroupBacklinksKey: "tags", SectionHeadingLevel: 6}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "\n###### tools\n\n- [Shed](./shed/)\n")
+	require.Contains(string(garden), "\n###### water\n\n- [Pond](./pond/)\n")
+	require.Contains(string(garden), "\n###### wildlife\n\n- [Pond](./pond/)\n", "A note is listed under each of its tags")
+}
+
+func TestMaxBacklinks(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "Plants.\n",
+		"Frogs.md":  "In the [[Garden]].\n",
+		"Pond.md":   "By the [[Garden]].\n",
+		"Shed.md":   "Next to the [[Garden]].\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{MaxBacklinks: 1}))
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "\n- [Frogs](./frogs/)\n    - In the [Garden](./garden/).\n\n…and 2 more references\n")
+	require.NotContains(string(garden), "Pond")
+
+	destDir = t.TempDir()
+	opts := Options{MaxBacklinks: 2, MoreBacklinksDetails: true, Labels: Labels{MoreBacklinks: "%d more"}}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	garden, err = ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "    - By the [Garden](./garden/).\n\n<details>\n<summary>1 more</summary>\n\n"+
+		"- [Shed](./shed/)\n    - Next to the [Garden](./garden/).\n\n</details>\n")
+}
@@ -0,0 +1,157 @@
+package backlinker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// hugoSite is what links are made to follow from the configuration of the Hugo site,
+// under Options.HugoConfig.
+type hugoSite struct {
+	uglyURLs bool
+	// permalinks are the patterns of the URLs of the pages in each section.
+	permalinks map[string]string
+}
+
+// permalinkToken matches the placeholders of a permalink pattern, such as :year.
+var permalinkToken = regexp.MustCompile(`:[a-z]+`)
+
+// readHugoConfig reads the Hugo site configuration named by Options.HugoConfig, in any of
+// the formats Hugo takes it in, for the links to follow. Its baseURL becomes the
+// BasePath, unless one is set already.
+func readHugoConfig(opts *Options) error {
+	if opts.HugoConfig == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(opts.HugoConfig)
+	if err != nil {
+		return err
+	}
+	config := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(opts.HugoConfig)) {
+	case ".toml":
+		_, err = toml.Decode(string(data), &config)
+	case ".json":
+		err = json.Unmarshal(data, &config)
+	default:
+		err = yaml.Unmarshal(data, &config)
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", opts.HugoConfig, err)
+	}
+	// Hugo's keys aren't case sensitive
+	site := &hugoSite{permalinks: make(map[string]string)}
+	for key, value := range config {
+		switch strings.ToLower(key) {
+		case "baseurl":
+			if baseURL, ok := value.(string); ok && opts.BasePath == "" {
+				opts.BasePath = baseURL
+			}
+		case "uglyurls":
+			site.uglyURLs, _ = value.(bool)
+		case "permalinks":
+			addPermalinks(site.permalinks, stringKeyed(value))
+		}
+	}
+	opts.hugoSite = site
+	return nil
+}
+
+// addPermalinks adds the pattern for each section. Newer versions of Hugo list the
+// sections' patterns under "page"; older ones list them directly.
+func addPermalinks(permalinks map[string]string, value interface{}) {
+	table, _ := value.(map[string]interface{})
+	for section, pattern := range table {
+		switch pattern := pattern.(type) {
+		case string:
+			permalinks[strings.ToLower(section)] = pattern
+		case map[string]interface{}:
+			if strings.ToLower(section) == "page" {
+				addPermalinks(permalinks, pattern)
+			}
+		}
+	}
+}
+
+// sitePath returns the path, from the root of the site, that Hugo publishes the file at
+// when the site configuration says how, and "" when it doesn't.
+func (o *Options) sitePath(file *markdownFile) string {
+	if o.hugoSite == nil {
+		return ""
+	}
+	dir := o.outputDir(file)
+	section := strings.SplitN(dir, "/", 2)[0]
+	pattern, exists := o.hugoSite.permalinks[strings.ToLower(section)]
+	if !exists || dir == "" {
+		return ""
+	}
+	return o.expandPermalink(pattern, file)
+}
+
+// expandPermalink fills in the placeholders of a permalink pattern for the file, in the
+// way Hugo does: :year, :month, :day, :title, :slug, :filename, :section and so on. It's
+// used for the Eleventy permalinks of Options.Permalink as well.
+func (o *Options) expandPermalink(pattern string, file *markdownFile) string {
+	dir := o.outputDir(file)
+	section := strings.SplitN(dir, "/", 2)[0]
+	date, _ := o.metadataDate(file)
+	if file.IsDateFile {
+		date, _ = o.filenameDate(file.OriginalName)
+	}
+	name := removeExtension(file.OriginalName)
+	return permalinkToken.ReplaceAllStringFunc(pattern, func(token string) string {
+		switch token {
+		case ":year":
+			return date.Format("2006")
+		case ":month":
+			return date.Format("01")
+		case ":monthname":
+			return urlize(date.Format("January"))
+		case ":day":
+			return date.Format("02")
+		case ":weekday":
+			return fmt.Sprint(int(date.Weekday()))
+		case ":weekdayname":
+			return urlize(date.Format("Monday"))
+		case ":yearday":
+			return fmt.Sprint(date.YearDay())
+		case ":section":
+			return urlize(section)
+		case ":sections":
+			return urlize(dir)
+		case ":title":
+			return urlize(file.Title)
+		case ":slug":
+			if file.slug != "" {
+				return urlize(file.slug)
+			}
+			return urlize(file.Title)
+		case ":filename", ":contentbasename":
+			return urlize(name)
+		}
+		return token
+	})
+}
+
+// urlize makes text part of a URL the way Hugo does for names: in lower case, with
+// hyphens for spaces.
+func urlize(text string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.Trim(text, "/"), " ", "-"))
+}
+
+// uglyLink makes a link to a page's directory, ./page/, into a link to its file,
+// ./page.html, when the site configuration has uglyURLs.
+func (o *Options) uglyLink(link string) string {
+	if o.hugoSite == nil || !o.hugoSite.uglyURLs || !strings.HasSuffix(link, "/") || path.Clean(link) == "." {
+		return link
+	}
+	return strings.TrimSuffix(link, "/") + ".html"
+}
@@ -0,0 +1,43 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinksFollowHugoConfig(t *testing.T) {
+	require := require.New(t)
+	sourceDir, _ := writeVault(t, map[string]string{
+		"2024-02-01.md": "Planted the [[Garden]].\n",
+		"Garden.md":     "---\ntitle: The Garden\n---\nStarted on [[2024-02-01]].\n",
+	})
+	siteDir := t.TempDir()
+	toml := filepath.Join(siteDir, "config.toml")
+	require.NoError(ioutil.WriteFile(toml, []byte(`baseURL = "https://example.com/wiki/"
+uglyURLs = true
+
+[permalinks]
+journal = "/:year/:month/:filename/"
+`), 0644))
+	destDir := t.TempDir()
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir,
+		Options{HugoConfig: toml, Sections: Sections{DateFiles: "journal"}}))
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "Started on [2024-02-01](/wiki/2024/02/2024-02-01.html).\n")
+	journal, err := ioutil.ReadFile(filepath.Join(destDir, "journal", "2024-02-01.md"))
+	require.NoError(err)
+	require.Contains(string(journal), "Planted the [Garden](/wiki/garden.html).\n")
+
+	yaml := filepath.Join(siteDir, "hugo.yaml")
+	require.NoError(ioutil.WriteFile(yaml, []byte("permalinks:\n  page:\n    notes: /topics/:slug/\n"), 0644))
+	destDir = t.TempDir()
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir,
+		Options{HugoConfig: yaml, Sections: Sections{Notes: "notes"}}))
+	journal, err = ioutil.ReadFile(filepath.Join(destDir, "2024-02-01.md"))
+	require.NoError(err)
+	require.Contains(string(journal), "Planted the [Garden](./topics/the-garden/).\n")
+}
@@ -0,0 +1,87 @@
+package backlinker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// linkStateFile is the name of the file in the cache holding the links found in each note.
+const linkStateFile = "links.json"
+
+// linkStateVersion changes whenever what's found in a note does, so that the links found
+// by an older version aren't reused. Version 1 added markdown links to other notes, and
+// version 2 left the frontmatter out of the contexts of links in the first paragraph.
+const linkStateVersion = 2
+
+// linkState is the links found in each note by the last run, by the note's path
+// relative to the source directory.
+type linkState struct {
+	Version int `json:"version"`
+	// Syntax is the Options.LinkSyntax the links were found with.
+	Syntax LinkSyntax            `json:"syntax,omitempty"`
+	Files  map[string]*noteLinks `json:"files"`
+}
+
+// noteLinks are the links found in a note, in the order they were found, and the hash
+// of the text they were found in.
+type noteLinks struct {
+	Hash  string      `json:"hash"`
+	Links []foundLink `json:"links"`
+}
+
+func loadLinkState(opts *Options) error {
+	state := &linkState{}
+	err := opts.cache.read(linkStateFile, state)
+	if err != nil {
+		return err
+	}
+	if state.Files == nil || state.Version != linkStateVersion || state.Syntax != opts.LinkSyntax {
+		state = &linkState{Version: linkStateVersion, Syntax: opts.LinkSyntax, Files: make(map[string]*noteLinks)}
+	}
+	opts.linkState = state
+	return nil
+}
+
+// saveLinkState writes the link state back to the cache, without the notes that
+// weren't read this time.
+func saveLinkState(filetexts map[*markdownFile][]byte, opts *Options) error {
+	read := make(map[string]bool)
+	for file := range filetexts {
+		read[file.relativePath()] = true
+	}
+	for name := range opts.linkState.Files {
+		if !read[name] {
+			delete(opts.linkState.Files, name)
+		}
+	}
+	return opts.cache.write(linkStateFile, opts.linkState)
+}
+
+func textHash(filetext []byte) string {
+	sum := sha256.Sum256(filetext)
+	return hex.EncodeToString(sum[:])
+}
+
+// cachedLinks returns the links the last run found in the file, if the file hasn't
+// changed since.
+func cachedLinks(file *markdownFile, filetext []byte, opts *Options) ([]foundLink, bool) {
+	if opts.linkState == nil {
+		return nil, false
+	}
+	entry, exists := opts.linkState.Files[file.relativePath()]
+	if !exists || entry.Hash != textHash(filetext) {
+		return nil, false
+	}
+	return entry.Links, true
+}
+
+// rememberLinks records the links found in the file in the link state, for next time.
+func rememberLinks(file *markdownFile, filetext []byte, links []foundLink, opts *Options) {
+	if opts.linkState == nil {
+		return
+	}
+	if links == nil {
+		links = []foundLink{}
+	}
+	opts.linkState.Files[file.relativePath()] = &noteLinks{Hash: textHash(filetext), Links: links}
+}
@@ -0,0 +1,50 @@
+package backlinker
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncrementalBuild(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "The garden, by the [[Shed]].\n",
+		"Shed.md":   "Next to the [[Garden]].\n",
+		"Pond.md":   "Still water.\n",
+	})
+	console := bytes.Buffer{}
+	opts := Options{Incremental: true, CacheDir: t.TempDir(), Logger: log.New(&console, "", 0)}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	require.Contains(console.String(), "Collecting backlinks from "+filepath.Join(sourceDir, "Garden.md"))
+	first, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+
+	console.Reset()
+	require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "Pond.md"), []byte("Frogs, and the [[Garden]].\n"), 0644))
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	require.Contains(console.String(), "Reusing the links found in Garden.md\n")
+	require.Contains(console.String(), "Reusing the links found in Shed.md\n")
+	require.Contains(console.String(), "Collecting backlinks from "+filepath.Join(sourceDir, "Pond.md"))
+	require.NotContains(console.String(), "Collecting backlinks from "+filepath.Join(sourceDir, "Shed.md"))
+
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "- [Pond](./pond/)\n    - Frogs, and the [Garden](./garden/).\n")
+	require.Contains(string(garden), "- [Shed](./shed/)\n    - Next to the [Garden](./garden/).\n", "Reused links still make backlinks")
+
+	fresh := t.TempDir()
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, fresh, Options{}))
+	for _, name := range []string{"Garden.md", "Shed.md", "Pond.md"} {
+		incremental, err := ioutil.ReadFile(filepath.Join(destDir, name))
+		require.NoError(err)
+		full, err := ioutil.ReadFile(filepath.Join(fresh, name))
+		require.NoError(err)
+		require.Equal(string(full), string(incremental), name)
+	}
+	require.NotEqual(string(first), string(garden))
+}
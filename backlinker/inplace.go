@@ -0,0 +1,136 @@
+package backlinker
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// backupExtension is added to the name of a note to make the name of its backup.
+const backupExtension = ".bak"
+
+// sameDir reports whether the source and destination are the same directory, however
+// they're written.
+func sameDir(sourceDir string, destDir string) bool {
+	source, err := filepath.Abs(sourceDir)
+	if err != nil {
+		return false
+	}
+	dest, err := filepath.Abs(destDir)
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(source); err == nil {
+		source = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(dest); err == nil {
+		dest = resolved
+	}
+	return source == dest
+}
+
+var markdownLink = regexp.MustCompile(`\[([^\[\]]+)\]\(([^()\s]+)\)`)
+
+// restoreWikilinks turns the links an earlier run wrote into a note back into the
+// wikilinks they came from, so that a note that has been rewritten in place is read as
+// it was first written. Only links exactly as they'd be written for a wikilink to a
+// note that exists count; any other markdown link is left alone. A link whose text
+// isn't the name of the note it goes to comes from a link with a label, such as
+// [[page|label]]. Links in code, whether a code span or a fenced or indented code block,
+// are left as they are.
+func restoreWikilinks(filetext []byte, fileMap map[string]*markdownFile, opts *Options) []byte {
+	byURL := make(map[string]*markdownFile)
+	for _, file := range sortedFiles(fileMap) {
+		if _, exists := byURL[opts.linkTo(file)]; !exists {
+			byURL[opts.linkTo(file)] = file
+		}
+	}
+	blocks := codeBlocks(filetext, opts)
+	lines := strings.Split(string(filetext), "\n")
+	offset := 0
+	for i, line := range lines {
+		start := offset
+		offset += len(line) + 1
+		if insideSpan([]int{start, start + len(line)}, blocks) {
+			continue
+		}
+		if opts.BlockAnchors {
+			line = restoreBlockMarker(line)
+		}
+		spans := codeSpans(line)
+		var result strings.Builder
+		last := 0
+		for _, match := range markdownLink.FindAllStringSubmatchIndex(line, -1) {
+			if insideSpan(match, spans) {
+				continue
+			}
+			linkText := line[match[2]:match[3]]
+			candidates := []string{linkText}
+			if file, exists := byURL[line[match[4]:match[5]]]; exists {
+				candidates = append(candidates, linkName(fileMap, file)+"|"+linkText)
+			}
+			wikilink := ""
+			for _, candidate := range candidates {
+				_, exists := opts.resolveLink(fileMap, backlinkCollector{}.Normalize(candidate))
+				if exists && convertLinksOnLine("[["+candidate+"]]", fileMap, opts) == line[match[0]:match[1]] {
+					wikilink = "[[" + candidate + "]]"
+					break
+				}
+			}
+			if wikilink == "" {
+				continue
+			}
+			result.WriteString(line[last:match[0]])
+			result.WriteString(wikilink)
+			last = match[1]
+		}
+		result.WriteString(line[last:])
+		lines[i] = result.String()
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// codeBlocks finds where the lines of the fenced and indented code blocks are in the
+// note's text, parsing it as findLinks does.
+func codeBlocks(filetext []byte, opts *Options) [][2]int {
+	doc, _ := parseNote(filetext, opts)
+	var blocks [][2]int
+	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch node.(type) {
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			if entering {
+				lines := node.Lines()
+				for i := 0; i < lines.Len(); i++ {
+					blocks = append(blocks, [2]int{lines.At(i).Start, lines.At(i).Stop})
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return blocks
+}
+
+// backUp copies a note that's about to be rewritten in place to its backup, unless it
+// would be rewritten exactly as it is.
+func (o *Options) backUp(filename string, data []byte) error {
+	if !o.inPlace || o.SkipBackups || !isMarkdownFile(filename) {
+		return nil
+	}
+	existing, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(existing, data) {
+		return nil
+	}
+	return writeFile(filename+backupExtension, existing)
+}
@@ -0,0 +1,83 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInPlaceRerunsAreStable(t *testing.T) {
+	require := require.New(t)
+	garden := "---\ntags: [outside]\n---\nThe garden, by the [[Shed]] and the [[Pond#Edge]].\n\nSee `[[Shed]]`, [[Shed|the hut]] and [a site](https://example.com).\n"
+	sourceDir, _ := writeVault(t, map[string]string{
+		"Garden.md": garden,
+		"Shed.md":   "Next to the [[Garden]].\n",
+		"Pond.md":   "# Edge\n\nMuddy.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, sourceDir, Options{}))
+	first := map[string]string{}
+	for _, name := range []string{"Garden.md", "Shed.md", "Pond.md"} {
+		data, err := ioutil.ReadFile(filepath.Join(sourceDir, name))
+		require.NoError(err)
+		first[name] = string(data)
+	}
+	require.Contains(first["Shed.md"], "- [Garden](./garden/)\n    - The garden, by the [Shed](./shed/) and the [Pond#Edge](./pond/#edge).\n")
+	backup, err := ioutil.ReadFile(filepath.Join(sourceDir, "Garden.md.bak"))
+	require.NoError(err)
+	require.Equal(garden, string(backup))
+
+	require.NoError(ProcessBackLinksWithOptions(sourceDir+"/.", sourceDir, Options{}))
+	for name, expected := range first {
+		data, err := ioutil.ReadFile(filepath.Join(sourceDir, name))
+		require.NoError(err)
+		require.Equal(expected, string(data), "%s is the same after the second run", name)
+	}
+	backup, err = ioutil.ReadFile(filepath.Join(sourceDir, "Garden.md.bak"))
+	require.NoError(err)
+	require.Equal(garden, string(backup), "Unchanged notes aren't backed up again")
+}
+
+func TestInPlaceLeavesCodeBlocks(t *testing.T) {
+	require := require.New(t)
+	guide := "# Guide\n\n```md\nExample: [Other](./other/)\n```\n\nIndented:\n\n    Also: [Other](./other/)\n"
+	sourceDir, _ := writeVault(t, map[string]string{
+		"Guide.md": "---\ntitle: Guide\n---\n" + guide,
+		"Other.md": "---\ntitle: Other\n---\nNothing.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, sourceDir, Options{SkipBackups: true}))
+	data, err := ioutil.ReadFile(filepath.Join(sourceDir, "Guide.md"))
+	require.NoError(err)
+	require.Equal("---\ntitle: Guide\n---\n"+guide, string(data), "Links in code blocks aren't turned into wikilinks")
+}
+
+func TestInPlaceWithoutBackups(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "The garden.\n",
+		"Shed.md":   "Next to the [[Garden]].\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, sourceDir, Options{SkipBackups: true}))
+	require.NoFileExists(filepath.Join(sourceDir, "Garden.md.bak"))
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{}))
+	require.NoFileExists(filepath.Join(destDir, "Garden.md.bak"))
+	require.NoFileExists(filepath.Join(sourceDir, "Garden.md.bak"))
+}
+
+func TestInPlaceBlockAnchors(t *testing.T) {
+	require := require.New(t)
+	sourceDir, _ := writeVault(t, map[string]string{
+		"Source.md": "As [[Target#^quote1]] says.\n",
+		"Target.md": "A famous line. ^quote1\n",
+	})
+	opts := Options{BlockAnchors: true, SkipBackups: true}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, sourceDir, opts))
+	first, err := ioutil.ReadFile(filepath.Join(sourceDir, "Target.md"))
+	require.NoError(err)
+	require.Contains(string(first), "A famous line. <a id=\"quote1\"></a>\n")
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, sourceDir, opts))
+	second, err := ioutil.ReadFile(filepath.Join(sourceDir, "Target.md"))
+	require.NoError(err)
+	require.Equal(string(first), string(second))
+}
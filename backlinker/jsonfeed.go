@@ -0,0 +1,69 @@
+package backlinker
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+// jsonFeedVersion identifies the version of the JSON Feed format written.
+const jsonFeedVersion = "https://jsonfeed.org/version/1.1"
+
+// jsonFeed is a JSON Feed (https://jsonfeed.org) document.
+type jsonFeed struct {
+	Version string         `json:"version"`
+	Title   string         `json:"title"`
+	Items   []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Title         string `json:"title"`
+	DatePublished string `json:"date_published"`
+	ContentText   string `json:"content_text"`
+}
+
+// WriteJSONFeed writes a JSON Feed of the date notes, newest first, with up to
+// Options.FeedLimit items. Each item's content is the note's summary, as plain text.
+func WriteJSONFeed(files FileMap, w io.Writer, opts Options) error {
+	type datedFile struct {
+		file *markdownFile
+		date time.Time
+	}
+	var dated []datedFile
+	for _, file := range includedFiles(files) {
+		if !file.IsDateFile || file.IsNew {
+			continue
+		}
+		if date, ok := opts.metadataDate(file); ok {
+			dated = append(dated, datedFile{file, date})
+		}
+	}
+	sort.SliceStable(dated, func(i, j int) bool {
+		return dated[i].date.After(dated[j].date)
+	})
+	if len(dated) > opts.feedLimit() {
+		dated = dated[:opts.feedLimit()]
+	}
+
+	feed := jsonFeed{
+		Version: jsonFeedVersion,
+		Title:   opts.labels().Feed,
+		Items:   []jsonFeedItem{},
+	}
+	for _, entry := range dated {
+		permalink := opts.permalink(entry.file)
+		feed.Items = append(feed.Items, jsonFeedItem{
+			ID:            permalink,
+			URL:           permalink,
+			Title:         entry.file.Title,
+			DatePublished: entry.date.Format(time.RFC3339),
+			ContentText:   plainText(extractSummary(entry.file, &opts)),
+		})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(feed)
+}
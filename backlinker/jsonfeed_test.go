@@ -0,0 +1,47 @@
+package backlinker
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJSONFeed(t *testing.T) {
+	require := require.New(t)
+	sourceDir, _ := writeVault(t, map[string]string{
+		"2024-02-01.md": "Planted the [[Garden]].\n",
+		"2024-02-03.md": "---\ntitle: Harvest Day\n---\nPicked **tomatoes**.\n\nAnd more.\n",
+		"2024-02-02.md": "Watered it.\n",
+		"Garden.md":     "The garden.\n",
+	})
+	opts := Options{BasePath: "https://example.com/notes/", FeedLimit: 2}
+	files, err := LoadFiles(sourceDir, opts)
+	require.NoError(err)
+
+	writer := bytes.Buffer{}
+	require.NoError(WriteJSONFeed(files, &writer, opts))
+	var feed map[string]interface{}
+	require.NoError(json.Unmarshal(writer.Bytes(), &feed))
+	require.Equal("https://jsonfeed.org/version/1.1", feed["version"])
+	require.Equal("Journal", feed["title"])
+
+	items := feed["items"].([]interface{})
+	require.Len(items, 2, "Limited to FeedLimit, newest first")
+	first := items[0].(map[string]interface{})
+	require.Equal("https://example.com/notes/2024-02-03/", first["id"])
+	require.Equal("https://example.com/notes/2024-02-03/", first["url"])
+	require.Equal("Harvest Day", first["title"])
+	require.Regexp(`^2024-02-03T\d\d:\d\d:\d\d`, first["date_published"])
+	require.Equal("Picked tomatoes.", first["content_text"])
+	second := items[1].(map[string]interface{})
+	require.Equal("2024-02-02", second["title"])
+}
+
+func TestWriteJSONFeedEmpty(t *testing.T) {
+	require := require.New(t)
+	writer := bytes.Buffer{}
+	require.NoError(WriteJSONFeed(linkFiles(map[string]string{"Garden.md": "No dates.\n"}), &writer, Options{}))
+	require.JSONEq(`{"version": "https://jsonfeed.org/version/1.1", "title": "Journal", "items": []}`, writer.String())
+}
@@ -0,0 +1,133 @@
+package backlinker
+
+import "strings"
+
+// Labels holds the text of everything written into the generated sections and pages,
+// so that it can be translated. Any label left empty is given in English.
+type Labels struct {
+	// Backlinks is the heading of the backlinks section. Defaults to "Backlinks".
+	Backlinks string `yaml:"backlinks"`
+	// UnlinkedMentions is the heading of the unlinked mentions section. Defaults to
+	// "Unlinked Mentions".
+	UnlinkedMentions string `yaml:"unlinked_mentions"`
+	// OutgoingLinks is the heading of the outgoing links section. Defaults to
+	// "Links from this note".
+	OutgoingLinks string `yaml:"outgoing_links"`
+	// IndirectBacklinks is the heading of the indirect backlinks section. Defaults to
+	// "Indirect Backlinks".
+	IndirectBacklinks string `yaml:"indirect_backlinks"`
+	// RelatedNotes is the heading of the related notes section. Defaults to
+	// "Related Notes".
+	RelatedNotes string `yaml:"related_notes"`
+	// Via joins an indirect backlink to the note it links through. Defaults to "via".
+	Via string `yaml:"via"`
+	// TodoPage is the title of the TodoPage. Defaults to "Todos".
+	TodoPage string `yaml:"todo_page"`
+	// Feed is the title of the JSON Feed of date notes. Defaults to "Journal".
+	Feed string `yaml:"feed"`
+	// TypeIndex is the title of the TypeIndexPage. Defaults to "Notes by Type".
+	TypeIndex string `yaml:"type_index"`
+	// Untyped heads the notes without a type on the TypeIndexPage. Defaults to "Untyped".
+	Untyped string `yaml:"untyped"`
+	// TopNotes is the title of the TopNotesPage. Defaults to "Top Notes".
+	TopNotes string `yaml:"top_notes"`
+	// MoreBacklinks sums up the backlinks left out under Options.MaxBacklinks, with %d
+	// for how many. Defaults to "…and %d more references".
+	MoreBacklinks string `yaml:"more_backlinks"`
+}
+
+// englishLabels are the labels used when none are given.
+var englishLabels = Labels{
+	Backlinks:         "Backlinks",
+	UnlinkedMentions:  "Unlinked Mentions",
+	OutgoingLinks:     "Links from this note",
+	IndirectBacklinks: "Indirect Backlinks",
+	RelatedNotes:      "Related Notes",
+	Via:               "via",
+	TodoPage:          "Todos",
+	Feed:              "Journal",
+	TypeIndex:         "Notes by Type",
+	Untyped:           "Untyped",
+	TopNotes:          "Top Notes",
+	MoreBacklinks:     "…and %d more references",
+}
+
+// labels returns the configured labels, with English for any that weren't given.
+func (o *Options) labels() Labels {
+	labels := o.Labels
+	if labels.Backlinks == "" {
+		labels.Backlinks = englishLabels.Backlinks
+	}
+	if labels.UnlinkedMentions == "" {
+		labels.UnlinkedMentions = englishLabels.UnlinkedMentions
+	}
+	if labels.OutgoingLinks == "" {
+		labels.OutgoingLinks = englishLabels.OutgoingLinks
+	}
+	if labels.IndirectBacklinks == "" {
+		labels.IndirectBacklinks = englishLabels.IndirectBacklinks
+	}
+	if labels.RelatedNotes == "" {
+		labels.RelatedNotes = englishLabels.RelatedNotes
+	}
+	if labels.Via == "" {
+		labels.Via = englishLabels.Via
+	}
+	if labels.TodoPage == "" {
+		labels.TodoPage = englishLabels.TodoPage
+	}
+	if labels.Feed == "" {
+		labels.Feed = englishLabels.Feed
+	}
+	if labels.TypeIndex == "" {
+		labels.TypeIndex = englishLabels.TypeIndex
+	}
+	if labels.Untyped == "" {
+		labels.Untyped = englishLabels.Untyped
+	}
+	if labels.TopNotes == "" {
+		labels.TopNotes = englishLabels.TopNotes
+	}
+	if labels.MoreBacklinks == "" {
+		labels.MoreBacklinks = englishLabels.MoreBacklinks
+	}
+	return labels
+}
+
+// sectionHeadings are the headings of the sections this tool appends to notes, in both
+// the configured labels and English, so that output from an earlier run in either is
+// recognized.
+func (o *Options) sectionHeadings() []string {
+	labels := o.labels()
+	return []string{
+		o.headingMarks() + " " + labels.Backlinks,
+		o.headingMarks() + " " + labels.IndirectBacklinks,
+		"## " + englishLabels.Backlinks,
+		"## " + englishLabels.IndirectBacklinks,
+	}
+}
+
+// headingMarks are the #s of the headings of the generated sections, as many as
+// Options.SectionHeadingLevel asks for.
+func (o *Options) headingMarks() string {
+	if o.SectionHeadingLevel < 1 || o.SectionHeadingLevel > 6 {
+		return "##"
+	}
+	return strings.Repeat("#", o.SectionHeadingLevel)
+}
+
+// sectionHeading is the heading a generated section starts with, with a blank line
+// before and after it.
+func (o *Options) sectionHeading(heading string) string {
+	return "\n" + o.headingMarks() + " " + heading + "\n\n"
+}
+
+// subheading is the heading of a part of a generated section, a level below
+// sectionHeading.
+func (o *Options) subheading(heading string) string {
+	marks := o.headingMarks()
+	if len(marks) < 6 {
+		marks += "#"
+	}
+	return "\n" + marks + " " + heading + "\n\n"
+}
@@ -0,0 +1,79 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomLabels(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garten.md": "Über Gärten.\n",
+		"Beet.md":   "Im [[Garten]].\n",
+		"Samen.md":  "Für das [[Beet]].\n- [ ] Samen kaufen\n",
+	})
+	opts := Options{
+		IndirectBacklinkDepth: 2,
+		TodoPage:              "aufgaben.md",
+		Labels: Labels{
+			Backlinks:         "Rückverweise",
+			IndirectBacklinks: "Indirekte Rückverweise",
+			Via:               "über",
+			TodoPage:          "Aufgaben",
+		},
+	}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+	require.NoError(err)
+
+	garten, err := ioutil.ReadFile(filepath.Join(destDir, "Garten.md"))
+	require.NoError(err)
+	require.Contains(string(garten), "\n## Rückverweise\n\n- [Beet](./beet/)\n")
+	require.Contains(string(garten), "\n## Indirekte Rückverweise\n\n- [Samen](./samen/) über [Beet](./beet/)\n")
+	require.NotContains(string(garten), "Backlinks")
+
+	todos, err := ioutil.ReadFile(filepath.Join(destDir, "aufgaben.md"))
+	require.NoError(err)
+	require.Contains(string(todos), "title: Aufgaben\n")
+}
+
+func TestCustomLabelsRecognizeEarlierOutput(t *testing.T) {
+	require := require.New(t)
+	opts := &Options{Labels: Labels{Backlinks: "Rückverweise"}}
+	file := loadFile(t, "Garten.md", "Über Gärten.\n\n## Rückverweise\n\n- [Beet](./beet/)\n")
+	require.Equal([]string{"Über Gärten."}, bodyWithoutGeneratedSections(file, opts))
+
+	file = loadFile(t, "Garten.md", "Über Gärten.\n\n## Backlinks\n\n- [Beet](./beet/)\n")
+	require.Equal([]string{"Über Gärten."}, bodyWithoutGeneratedSections(file, opts), "English headings are still recognized")
+}
+
+func TestSectionHeadingLevel(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "Plants.\n",
+		"Pond.md":   "By the [[Garden]].\n",
+		"Frogs.md":  "In the [[Pond]].\n",
+	})
+	opts := Options{
+		IndirectBacklinkDepth: 2,
+		SectionHeadingLevel:   3,
+		Labels:                Labels{Backlinks: "Linked references"},
+	}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "\n### Linked references\n\n- [Pond](./pond/)\n")
+	require.Contains(string(garden), "\n### Indirect Backlinks\n\n- [Frogs](./frogs/) via [Pond](./pond/)\n")
+
+	file := loadFile(t, "Garden.md", string(garden))
+	require.Equal([]string{"Plants."}, bodyWithoutGeneratedSections(file, &opts), "The headings are recognized at their level")
+
+	destDir = t.TempDir()
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{HideBacklinksHeading: true}))
+	garden, err = ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "<!-- sharedbrain:backlinks:start -->\n\n- [Pond](./pond/)\n")
+	require.NotContains(string(garden), "#")
+}
@@ -0,0 +1,75 @@
+package backlinker
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// maxRotatedLogs is how many old log files are kept when LogFileRotate is set.
+const maxRotatedLogs = 5
+
+// logf writes progress to the configured logger.
+func (o *Options) logf(format string, args ...interface{}) {
+	if o.Logger == nil {
+		log.Printf(format, args...)
+		return
+	}
+	o.Logger.Printf(format, args...)
+}
+
+// openLogFile starts the log file for this run, if one is configured, and points the
+// logger at it (as well as, or instead of, where it was already going). The returned
+// function closes the file and puts the logger back. A dry run writes nothing, so it
+// leaves the log file (and any old ones) alone.
+func openLogFile(destDir string, opts *Options) (func(), error) {
+	if opts.LogFile == "" || opts.DryRun != nil {
+		return func() {}, nil
+	}
+	filename := opts.LogFile
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(destDir, filename)
+	}
+	if opts.LogFileRotate {
+		err := rotateLogs(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+	logFile, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	previous := opts.Logger
+	var output io.Writer = logFile
+	if !opts.LogFileOnly {
+		if previous != nil {
+			output = io.MultiWriter(previous.Writer(), logFile)
+		} else {
+			output = io.MultiWriter(log.Writer(), logFile)
+		}
+	}
+	opts.Logger = log.New(output, "", log.LstdFlags)
+	return func() {
+		opts.Logger = previous
+		_ = logFile.Close()
+	}, nil
+}
+
+// rotateLogs moves an existing log file aside (to .1, pushing older ones to .2 and so
+// on) so that this run starts a fresh one.
+func rotateLogs(filename string) error {
+	for i := maxRotatedLogs - 1; i >= 1; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", filename, i), fmt.Sprintf("%s.%d", filename, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	err := os.Rename(filename, filename+".1")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
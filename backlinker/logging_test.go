@@ -0,0 +1,48 @@
+package backlinker
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogFile(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"First.md": "Links to [[Nowhere]].\n",
+	})
+	var console bytes.Buffer
+	opts := Options{Logger: log.New(&console, "", 0), LogFile: "sharedbrain.log"}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+	require.NoError(err)
+
+	logged, err := ioutil.ReadFile(filepath.Join(destDir, "sharedbrain.log"))
+	require.NoError(err)
+	require.Regexp(`(?m)^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d Collecting backlinks from .*First.md$`, string(logged))
+	require.Contains(string(logged), "Warning: First.md: [dangling-link] [[Nowhere]] doesn't match any file")
+	require.Contains(console.String(), "Collecting backlinks from", "The log still goes to the logger")
+}
+
+func TestLogFileOnlyAndRotation(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"First.md": "Hello.\n",
+	})
+	logFile := filepath.Join(t.TempDir(), "run.log")
+	var console bytes.Buffer
+	opts := Options{Logger: log.New(&console, "", 0), LogFile: logFile, LogFileOnly: true, LogFileRotate: true}
+	for i := 0; i < 3; i++ {
+		err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+		require.NoError(err)
+	}
+	require.Equal("", console.String())
+	require.FileExists(logFile)
+	require.FileExists(logFile + ".1")
+	require.FileExists(logFile + ".2")
+	require.NoFileExists(logFile + ".3")
+	require.NoFileExists(filepath.Join(destDir, "run.log"))
+}
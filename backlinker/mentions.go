@@ -0,0 +1,162 @@
+package backlinker
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sheldonhull/sharedbrain/wikilink"
+	"github.com/yuin/goldmark/ast"
+)
+
+// mention is a name of a note found in the text of another, and the paragraph it's in.
+type mention struct {
+	name    string
+	context string
+}
+
+// collectMentions finds the unlinked mentions of each note under Options.UnlinkedMentions:
+// any name a link to it could use, written in the text of another note without a link,
+// outside of code. Each paragraph mentioning a note counts once, and a note that
+// already links to the one it mentions is left to its backlinks.
+func collectMentions(files []*markdownFile, filetexts map[*markdownFile][]byte, fileMap map[string]*markdownFile,
+	opts *Options) {
+	pattern, names := mentionPattern(fileMap, opts)
+	if pattern == nil {
+		return
+	}
+	found := make([][]mention, len(files))
+	opts.inParallel(len(files), func(i int) {
+		if filetext, exists := filetexts[files[i]]; exists {
+			found[i] = findMentions(noteBody(filetext), pattern, opts)
+		}
+	})
+	for i, file := range files {
+		seen := make(map[*markdownFile]map[string]bool)
+		for _, m := range found[i] {
+			target := names[strings.ToLower(m.name)]
+			if target == file || linksTo(file, target) || seen[target][m.context] {
+				continue
+			}
+			if seen[target] == nil {
+				seen[target] = make(map[string]bool)
+			}
+			seen[target][m.context] = true
+			target.mentions = append(target.mentions, backlink{OtherFile: file, Context: m.context, Offset: -1})
+		}
+	}
+}
+
+// mentionPattern matches any of the names of the notes that exist, longest first so
+// that a note called "Garden Design" isn't taken for a mention of "Garden". It's
+// returned with the note each name (in lower case) belongs to.
+func mentionPattern(fileMap map[string]*markdownFile, opts *Options) (*regexp.Regexp, map[string]*markdownFile) {
+	names := make(map[string]*markdownFile)
+	keys := make([]string, 0, len(fileMap)+len(opts.titles))
+	for key := range fileMap {
+		keys = append(keys, key)
+	}
+	for key := range opts.titles {
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		name := strings.TrimSpace(strings.TrimSuffix(key, ".md"))
+		if name == "" || strings.Contains(name, "/") {
+			continue
+		}
+		if file, exists := opts.resolveLink(fileMap, key); exists && !file.IsNew && !file.generated {
+			names[name] = file
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	quoted := make([]string, 0, len(names))
+	for name := range names {
+		quoted = append(quoted, regexp.QuoteMeta(name))
+	}
+	sort.Slice(quoted, func(i, j int) bool {
+		if len(quoted[i]) != len(quoted[j]) {
+			return len(quoted[i]) > len(quoted[j])
+		}
+		return quoted[i] < quoted[j]
+	})
+	return regexp.MustCompile(`(?i)` + strings.Join(quoted, "|")), names
+}
+
+// findMentions returns the names the pattern matches as whole words in the note's text,
+// leaving out links, images, code and HTML.
+func findMentions(filetext []byte, pattern *regexp.Regexp, opts *Options) []mention {
+	doc, _ := parseNote(filetext, opts)
+	var result []mention
+	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := node.(type) {
+		case *ast.Link, *wikilink.Node, *ast.Image, *ast.AutoLink, *ast.CodeSpan, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			value := string(node.Segment.Value(filetext))
+			for _, match := range pattern.FindAllStringIndex(value, -1) {
+				if isWordAt(value, match[0], match[1]) {
+					result = append(result, mention{
+						name:    value[match[0]:match[1]],
+						context: blockText(enclosingBlock(node).Lines(), filetext),
+					})
+				}
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return result
+}
+
+// isWordAt reports whether text[start:end] isn't part of a longer word.
+func isWordAt(text string, start int, end int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:start])
+	after, _ := utf8.DecodeRuneInString(text[end:])
+	return !isWordRune(before) && !isWordRune(after)
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// linksTo reports whether the file links to other.
+func linksTo(file *markdownFile, other *markdownFile) bool {
+	for _, linked := range file.forwardLinks {
+		if linked == other {
+			return true
+		}
+	}
+	return false
+}
+
+// addUnlinkedMentions adds a section for the notes that mention this one without linking
+// to it, when Options.UnlinkedMentions asks for it.
+func addUnlinkedMentions(file *markdownFile, fileMap map[string]*markdownFile, opts *Options, writer io.Writer) error {
+	var mentions []backlink
+	for _, m := range file.mentions {
+		if !m.OtherFile.unpublished {
+			mentions = append(mentions, m)
+		}
+	}
+	if !opts.UnlinkedMentions || len(mentions) == 0 {
+		return nil
+	}
+	_, _ = writer.Write([]byte(opts.sectionHeading(opts.labels().UnlinkedMentions)))
+	sortBacklinks(file, mentions, opts)
+	for _, m := range mentions {
+		context := convertLinksOnLine(m.Context, fileMap, opts)
+		if opts.ContextStyle == ContextPlain {
+			context = plainText(m.Context)
+		}
+		_, _ = writer.Write([]byte(fmt.Sprintf("- [%s](%s)\n    - %s\n", m.OtherFile.Title, opts.pageLink(m.OtherFile, ""), context)))
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnlinkedMentions(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md":        "Plants.\n",
+		"Garden Design.md": "Ideas.\n",
+		"Gardening.md":     "Tips.\n",
+		"Walk.md":          "I walked through the garden, then read about garden design.\n\n```\nGarden\n```\n\nAnd `Garden` again.\n",
+		"Shed.md":          "Next to the [[Garden]], as the garden is.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{UnlinkedMentions: true}))
+
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "<!-- sharedbrain:backlinks:end -->\n\n<!-- sharedbrain:unlinked-mentions:start -->\n")
+	mentions := string(garden)[strings.Index(string(garden), "## Unlinked Mentions"):]
+	require.Equal("## Unlinked Mentions\n\n- [Walk](./walk/)\n"+
+		"    - I walked through the garden, then read about garden design.\n\n"+
+		"<!-- sharedbrain:unlinked-mentions:end -->\n", mentions, "Shed already links, and code doesn't count")
+	design, err := ioutil.ReadFile(filepath.Join(destDir, "Garden Design.md"))
+	require.NoError(err)
+	require.Contains(string(design), "## Unlinked Mentions\n\n- [Walk](./walk/)\n")
+	gardening, err := ioutil.ReadFile(filepath.Join(destDir, "Gardening.md"))
+	require.NoError(err)
+	require.NotContains(string(gardening), "Unlinked Mentions")
+
+	plain := t.TempDir()
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, plain, Options{}))
+	garden, err = ioutil.ReadFile(filepath.Join(plain, "Garden.md"))
+	require.NoError(err)
+	require.NotContains(string(garden), "Unlinked Mentions")
+}
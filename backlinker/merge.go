@@ -0,0 +1,118 @@
+package backlinker
+
+// mergeSameDayFiles combines the notes whose filenames start with the same date into a
+// single journal entry. The note named for just the date (or else the first by name)
+// is kept; the others' bodies are appended to it under their titles, their links and
+// backlinks move to it, and links to them go to it instead. Frontmatter from the kept
+// note wins, then the others fill in missing keys (other than title and date) in
+// filename order.
+func mergeSameDayFiles(fileMap map[string]*markdownFile, opts *Options) {
+	days := make(map[string][]*markdownFile)
+	var order []string
+	for _, file := range includedFiles(fileMap) {
+		day := opts.filenameDay(file.OriginalName)
+		if day == "" || file.IsNew {
+			continue
+		}
+		if _, exists := days[day]; !exists {
+			order = append(order, day)
+		}
+		days[day] = append(days[day], file)
+	}
+	for _, day := range order {
+		files := days[day]
+		if len(files) < 2 {
+			continue
+		}
+		primary := files[0]
+		for _, file := range files {
+			if file.IsDateFile {
+				primary = file
+				break
+			}
+		}
+		for _, other := range files {
+			if other != primary {
+				opts.logf("Merging %s into %s\n", other.OriginalName, primary.OriginalName)
+				mergeInto(fileMap, primary, other)
+			}
+		}
+	}
+}
+
+// mergeInto moves everything about other into primary.
+func mergeInto(fileMap map[string]*markdownFile, primary *markdownFile, other *markdownFile) {
+	title := other.Title
+	if metaTitle, ok := other.metadata["title"].(string); ok {
+		title = metaTitle
+	}
+	lines := trimTrailingBlankLines(primary.body)
+	lines = append(lines, "", "---", "", "## "+title, "")
+	lines = append(lines, other.body...)
+	primary.body = lines
+	primary.resetScanner()
+
+	for key, value := range other.metadata {
+		// The title and date belong to the note being merged in, not the journal entry
+		if key == "title" || key == "date" {
+			continue
+		}
+		if _, exists := primary.metadata[key]; !exists {
+			primary.metadata[key] = value
+		}
+	}
+
+	for _, bl := range other.BackLinks {
+		if bl.OtherFile != primary {
+			primary.BackLinks = append(primary.BackLinks, bl)
+		}
+	}
+	for _, file := range sortedFiles(fileMap) {
+		kept := file.BackLinks[:0]
+		for _, bl := range file.BackLinks {
+			if bl.OtherFile == other {
+				if file == primary {
+					continue
+				}
+				bl.OtherFile = primary
+			}
+			kept = append(kept, bl)
+		}
+		file.BackLinks = kept
+	}
+	for _, linked := range other.forwardLinks {
+		if linked != primary {
+			primary.addForwardLink(linked)
+		}
+	}
+	for key, file := range fileMap {
+		if file == other {
+			fileMap[key] = primary
+		}
+		file.forwardLinks = replaceFile(file.forwardLinks, other, primary)
+	}
+	primary.forwardLinks = replaceFile(primary.forwardLinks, primary, nil)
+}
+
+// replaceFile swaps one file for another in a list, dropping the duplicate if both were
+// there already. A nil replacement removes the file.
+func replaceFile(files []*markdownFile, old *markdownFile, replacement *markdownFile) []*markdownFile {
+	result := files[:0]
+	hasReplacement := false
+	for _, file := range files {
+		if file == old {
+			if replacement == nil {
+				continue
+			}
+			file = replacement
+		}
+		if file == replacement {
+			if hasReplacement {
+				continue
+			}
+			hasReplacement = true
+		}
+		result = append(result, file)
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeSameDayFiles(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"2024-02-01.md":       "---\ntags: [journal]\n---\nMorning thoughts on [[Gardens]].\n",
+		"2024-02-01-notes.md": "---\ntitle: Meeting Notes\ntags: [work]\nauthor: me\n---\nWe discussed [[Gardens]] too.\n\n",
+		"Gardens.md":          "See what happened on [[2024-02-01-notes]].\n",
+	})
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{MergeSameDay: true})
+	require.NoError(err)
+	require.NoFileExists(filepath.Join(destDir, "2024-02-01-notes.md"))
+
+	journal, err := ioutil.ReadFile(filepath.Join(destDir, "2024-02-01.md"))
+	require.NoError(err)
+	require.Equal(`---
+tags: [journal]
+author: me
+date: 2024-02-01T08:00:00-05:00
+title: "2024-02-01"
+---
+Morning thoughts on [Gardens](./gardens/).
+
+---
+
+## Meeting Notes
+
+We discussed [Gardens](./gardens/) too.
+
+
+<!-- sharedbrain:backlinks:start -->
+
+## Backlinks
+
+- [Gardens](./gardens/)
+    - See what happened on [2024-02-01-notes](./2024-02-01/).
+
+<!-- sharedbrain:backlinks:end -->
+`, string(journal))
+
+	gardens, err := ioutil.ReadFile(filepath.Join(destDir, "Gardens.md"))
+	require.NoError(err)
+	require.Contains(string(gardens), "See what happened on [2024-02-01-notes](./2024-02-01/).")
+	require.Contains(string(gardens), `
+## Backlinks
+
+- [2024-02-01](./2024-02-01/)
+    - We discussed [Gardens](./gardens/) too.
+- [2024-02-01](./2024-02-01/)
+    - Morning thoughts on [Gardens](./gardens/).
+`)
+}
@@ -0,0 +1,80 @@
+package backlinker
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// WriteGraphMermaid writes the link graph as a Mermaid flowchart (`graph LR`), with a
+// node for every file labeled by its title and an edge for every link between files.
+// Files that aren't published, like skipped drafts and those that couldn't be read, are
+// left out, as they are from the site.
+func WriteGraphMermaid(files FileMap, w io.Writer) error {
+	return writeMermaid(files, nil, w)
+}
+
+// WriteLocalGraphMermaid works like WriteGraphMermaid, limited to the files within depth
+// links of root in either direction.
+func WriteLocalGraphMermaid(files FileMap, root string, depth int, w io.Writer) error {
+	rootFile, err := lookupFile(files, root)
+	if err != nil {
+		return err
+	}
+	return writeMermaid(files, neighborhood(files, rootFile, depth), w)
+}
+
+// writeMermaid writes the graph of the published files, limited to the files in include
+// if it's not nil.
+func writeMermaid(fileMap map[string]*markdownFile, include map[*markdownFile]int, w io.Writer) error {
+	included := func(file *markdownFile) bool {
+		if file.unreadable || file.unpublished || file.unwritten {
+			return false
+		}
+		if include == nil {
+			return true
+		}
+		_, ok := include[file]
+		return ok
+	}
+	ids := mermaidIDs(fileMap)
+	var out strings.Builder
+	out.WriteString("graph LR\n")
+	for _, file := range sortedFiles(fileMap) {
+		if included(file) {
+			out.WriteString(fmt.Sprintf("    %s[\"%s\"]\n", ids[file], mermaidLabel(file.Title)))
+		}
+	}
+	for _, edge := range collectEdges(fileMap) {
+		if included(edge.From) && included(edge.To) {
+			out.WriteString(fmt.Sprintf("    %s --> %s\n", ids[edge.From], ids[edge.To]))
+		}
+	}
+	_, err := w.Write([]byte(out.String()))
+	return err
+}
+
+// mermaidIDs gives every file a node id made only of characters Mermaid accepts,
+// adding a number where two names would otherwise end up the same.
+func mermaidIDs(fileMap map[string]*markdownFile) map[*markdownFile]string {
+	unsafe := regexp.MustCompile(`[^A-Za-z0-9_]+`)
+	ids := make(map[*markdownFile]string)
+	used := make(map[string]bool)
+	for _, file := range sortedFiles(fileMap) {
+		base := unsafe.ReplaceAllString(strings.ToLower(removeExtension(file.OriginalName)), "_")
+		base = "n_" + strings.Trim(base, "_")
+		id := base
+		for i := 2; used[id]; i++ {
+			id = fmt.Sprintf("%s_%d", base, i)
+		}
+		used[id] = true
+		ids[file] = id
+	}
+	return ids
+}
+
+// mermaidLabel escapes a title for use inside a quoted Mermaid label.
+func mermaidLabel(title string) string {
+	return strings.ReplaceAll(title, `"`, "#quot;")
+}
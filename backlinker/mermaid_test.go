@@ -0,0 +1,71 @@
+package backlinker
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteGraphMermaid(t *testing.T) {
+	require := require.New(t)
+	fileMap := linkFiles(map[string]string{
+		"Digital Gardens.md": "See [[Notes]] and [[Notes]] again.\n",
+		"Notes.md":           "Back to [[Digital Gardens]] and on to [[Quote \"Me\"]].\n",
+		"Quote \"Me\".md":    "Nothing.\n",
+	})
+	fileMap["notes.md"].Title = "My Notes"
+	writer := bytes.Buffer{}
+	err := WriteGraphMermaid(fileMap, &writer)
+	require.Nil(err)
+	require.Equal(`graph LR
+    n_digital_gardens["Digital Gardens"]
+    n_notes["My Notes"]
+    n_quote_me["Quote #quot;Me#quot;"]
+    n_digital_gardens --> n_notes
+    n_notes --> n_digital_gardens
+    n_notes --> n_quote_me
+`, writer.String())
+}
+
+func TestMermaidLeavesOutUnpublished(t *testing.T) {
+	require := require.New(t)
+	fileMap := linkFiles(map[string]string{
+		"A.md":      "[[B]] [[Draft]] [[Broken]]\n",
+		"B.md":      "[[Draft]]\n",
+		"Draft.md":  "[[A]]\n",
+		"Broken.md": "Nothing.\n",
+	})
+	fileMap["draft.md"].unpublished = true
+	fileMap["broken.md"].unreadable = true
+	writer := bytes.Buffer{}
+	require.NoError(WriteGraphMermaid(fileMap, &writer))
+	require.Equal(`graph LR
+    n_a["A"]
+    n_b["B"]
+    n_a --> n_b
+`, writer.String())
+}
+
+func TestWriteLocalGraphMermaid(t *testing.T) {
+	require := require.New(t)
+	fileMap := linkFiles(map[string]string{
+		"A.md": "[[B]]\n",
+		"B.md": "[[C]]\n",
+		"C.md": "[[D]]\n",
+		"D.md": "Nothing.\n",
+	})
+	writer := bytes.Buffer{}
+	err := WriteLocalGraphMermaid(fileMap, "B", 1, &writer)
+	require.Nil(err)
+	require.Equal(`graph LR
+    n_a["A"]
+    n_b["B"]
+    n_c["C"]
+    n_a --> n_b
+    n_b --> n_c
+`, writer.String())
+
+	err = WriteLocalGraphMermaid(fileMap, "Missing", 1, &writer)
+	require.Error(err)
+}
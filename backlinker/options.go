@@ -0,0 +1,591 @@
+package backlinker
+
+import (
+	"io"
+	"log"
+	"text/template"
+
+	"golang.org/x/text/collate"
+)
+
+// Options controls the optional behavior of ProcessBackLinksWithOptions.
+// The zero value reproduces the behavior of ProcessBackLinks.
+type Options struct {
+	// Recursive includes the notes in subdirectories of the source directory (other than
+	// hidden ones), which are written to the same subdirectories of the destination.
+	// Links find notes by name, whichever directory they're in.
+	Recursive bool
+	// Exclude lists patterns (in the form path.Match takes) for the notes to leave out, as
+	// if they weren't in the source directory. A pattern can match a note's name or its
+	// path relative to the source directory, or a directory the note is in ("private/"
+	// leaves out everything under private). Notes marked `exclude: true` in their
+	// frontmatter are left out too. Links to notes that are left out are written as
+	// plain text, rather than making stubs of them.
+	Exclude []string
+	// Include, when set, lists patterns (of the same form as Exclude) that notes have to
+	// match to be read at all. Exclude still applies to the notes it includes.
+	Include  []string
+	excluded map[string]bool
+
+	// SkipBackups stops the notes being backed up when the source and destination are
+	// the same directory. Otherwise each note is copied to a .bak file next to it before
+	// it's rewritten.
+	SkipBackups bool
+	inPlace     bool
+
+	// DryRun, when set, stops anything being written to the destination. A unified diff
+	// of how each file there would change is written to it instead.
+	DryRun io.Writer
+
+	// ReadingTime adds a word count and an estimated reading time (in minutes)
+	// to the frontmatter of every file that has content of its own.
+	ReadingTime bool
+	// WordsPerMinute is the reading speed used for the reading time estimate.
+	// Defaults to 200.
+	WordsPerMinute int
+	// WordCountKey is the frontmatter key for the word count. Defaults to "word_count".
+	WordCountKey string
+	// ReadingTimeKey is the frontmatter key for the reading time. Defaults to "reading_time".
+	ReadingTimeKey string
+
+	// Strict turns warnings (dangling links, ambiguous links, bad dates, duplicate
+	// frontmatter keys) into an error once the whole vault has been checked.
+	// Nothing is written when that happens.
+	Strict bool
+	// Report, when set, receives the warnings found during the run.
+	Report *Report
+
+	// BasePath makes links root-relative under the given path (for example "/wiki/"
+	// gives "/wiki/slug/") instead of relative to the current page ("./slug/").
+	// A full URL may be given, in which case only its path is used.
+	BasePath string
+
+	// Summary adds a summary of the body to the frontmatter of files that don't have
+	// one: the text before a <!--more--> marker or, without one, the first paragraph.
+	Summary bool
+	// SummaryKey is the frontmatter key for the summary. Defaults to "summary".
+	SummaryKey string
+
+	// HighlightContextLink wraps the link that a backlink came from in bold when
+	// showing the backlink's context.
+	HighlightContextLink bool
+	// CollapseBacklinks lists each note linking here once, however many times it links.
+	CollapseBacklinks bool
+	// ContextMerge decides which context is shown for a note that links here more than
+	// once when CollapseBacklinks is set. By default it's the first.
+	ContextMerge ContextMergePolicy
+	// ContextStyle decides how the context of each backlink is shown. By default it's
+	// markdown with its links converted; ContextPlain strips the formatting (and with it
+	// any highlighting).
+	ContextStyle ContextStyle
+
+	// Collation is the locale (a BCP 47 tag such as "fr" or "de") used when sorting
+	// by title. Without one, titles are sorted by byte order.
+	Collation string
+	collator  *collate.Collator
+	// BacklinkSort chooses the order the backlinks are listed in, and ReverseBacklinkSort
+	// turns it around. By default they're listed newest first, then by title. A note can
+	// choose its own with backlinks_sort and backlinks_sort_reverse in its frontmatter.
+	BacklinkSort        BacklinkSort
+	ReverseBacklinkSort bool
+
+	// UnlinkedMentions adds an "Unlinked Mentions" section, below the backlinks, listing
+	// the notes that mention this one by name (or by any other name a link could use)
+	// without linking to it.
+	UnlinkedMentions bool
+
+	// OutgoingLinks adds a "Links from this note" section, below the backlinks, listing
+	// the notes this one links to in the order they're first linked, as Obsidian's
+	// outgoing links pane does.
+	OutgoingLinks bool
+
+	// IndirectBacklinkDepth adds an "Indirect Backlinks" section listing the notes that
+	// link here through other notes, up to this many links away. 2 shows the notes
+	// linking to the direct backlinks; 0 or 1 leaves the section out.
+	IndirectBacklinkDepth int
+	// RelatedNotesThreshold adds a "Related Notes" section listing the notes that link to
+	// at least this many of the same notes as this one does, most shared links first.
+	// 0 leaves the section out.
+	RelatedNotesThreshold int
+
+	// StubTemplate is a text/template that renders the whole of each stub, frontmatter
+	// and all, in place of the usual frontmatter and backlinks. It's given the stub's
+	// Title, Date (RFC 3339, or empty), Backlinks (each with a Title, URL, Label and Context)
+	// and the Sections that would otherwise be written.
+	StubTemplate string
+	// StubBody is a text/template for the body of each stub, such as "{{ .Title }} hasn't
+	// been written yet.", which the backlinks follow as they do in other notes. It's given
+	// what StubTemplate is, but for the Sections.
+	StubBody string
+	// BacklinksTemplate is a text/template that renders the backlinks section of each
+	// note with backlinks, in place of the usual heading and list. It's given the note's
+	// Title, URL and frontmatter as Params, the Heading the section would have, and its
+	// Backlinks (each with a Title, URL, Label and Context, as they'd be listed).
+	BacklinksTemplate string
+	// BacklinksTemplateFile is a file to read the BacklinksTemplate from, when that
+	// isn't set itself.
+	BacklinksTemplateFile string
+	backlinksTemplate     *template.Template
+
+	// Stubs decides whether pages are made for the notes that are linked to but don't
+	// exist, and what the links to them become when they aren't.
+	Stubs StubPolicy
+	// StubMinBacklinks is the fewest links a missing note needs for a stub to be made of
+	// it. The links to the rest become text, as under StubsPlain (or StubsSpan, when that's
+	// the policy). 0 makes a stub of every missing note.
+	StubMinBacklinks int
+	// StubClass is the class of the spans links to missing notes become under StubsSpan.
+	// Defaults to "missing-note".
+	StubClass string
+
+	// StubDir is a directory, relative to the destination, for the pages created only
+	// because something links to them. By default they sit alongside the other pages.
+	StubDir string
+	// Sections sends kinds of notes to Hugo sections of their own, such as date notes
+	// to the journal.
+	Sections Sections
+
+	// SkipBacklinkSection leaves the backlinks sections off every page, so that only
+	// links and frontmatter are converted. The link graph is still collected.
+	SkipBacklinkSection bool
+	// SkipDateFileBacklinks leaves the Backlinks section off date notes only, so that
+	// journal entries don't fill up with links from everything written about that day.
+	// Any note can leave it off itself (a home or about page, say) with
+	// `backlinks: false` in its frontmatter.
+	SkipDateFileBacklinks bool
+
+	// Progress, when set, is called once with the total number of files before any are
+	// written, and then after each file is written, with the number done so far.
+	Progress func(done int, total int, current string)
+
+	// LinkSyntax adds other ways of writing links to the wikilinks that are always
+	// recognized, such as Roam's tags.
+	LinkSyntax LinkSyntax
+
+	// AliasKey is the frontmatter key listing other names a note can be linked by,
+	// such as Obsidian's "aliases". Aliases aren't used unless this is set.
+	AliasKey string
+	// LinkTitles lets notes be linked by their frontmatter title as well as their
+	// filename, and decides which wins when a link matches both.
+	LinkTitles LinkTitlePolicy
+	titles     map[string]*markdownFile
+	// SkipSelfBacklinks leaves out backlinks from a note to itself, including links
+	// made through one of its aliases.
+	SkipSelfBacklinks bool
+
+	// Relations are frontmatter keys naming related notes, which are added to the graph
+	// and listed in sections of their own.
+	Relations []Relation
+
+	// JSONSidecars writes a JSON file with each note's data next to (or instead of)
+	// its markdown.
+	JSONSidecars SidecarMode
+
+	// TitlePolicy decides what to do with notes that have both a frontmatter title and
+	// a leading H1. By default both are kept.
+	TitlePolicy TitlePolicy
+
+	// CheckAnchors warns about links like [[page#heading]] where the page has no such
+	// heading.
+	CheckAnchors bool
+
+	// HeadingAnchors gives every heading in the output an explicit id, made the same way
+	// as the anchors of links like [[page#heading]].
+	HeadingAnchors HeadingAnchorStyle
+	// BlockAnchors makes links to blocks, like [[page#^id]], go to the block itself, with
+	// the id at the end of the block (" ^id") replaced by an anchor. Otherwise they go to
+	// the top of the note, and the ids are left as they are.
+	BlockAnchors bool
+
+	// Embeds decides what embeds like ![[page]] become: links to the note, by default,
+	// the note's body itself, or a shortcode. They're left as they are in notes that are
+	// rewritten in place, where what was embedded couldn't be told from the note's own
+	// text the next time.
+	Embeds EmbedMode
+	// EmbedShortcode is the name of the shortcode used under EmbedsShortcode. It
+	// defaults to "embed".
+	EmbedShortcode string
+
+	// AttachmentDir is a directory, relative to the source directory, holding the images
+	// and other files notes link to (they're always found next to the notes too).
+	// Attachments that are linked to are copied to the same place in the destination,
+	// and embeds of images, like ![[diagram.png]], become markdown images.
+	AttachmentDir   string
+	attachments     map[string]string
+	usedAttachments map[string]bool
+	// PageBundles writes each note as a Hugo leaf bundle, name/index.md, with copies of
+	// the attachments its body links to inside it, so that they're resources of the page.
+	// Zola takes notes written this way too, as pages with their assets beside them.
+	// The attachments are still copied next to the notes too, for the links to them in
+	// the contexts of backlinks.
+	PageBundles bool
+	// Target is the static site generator the output is for. Defaults to Hugo.
+	Target Target
+	// Permalink is the pattern of the URLs of the notes under TargetEleventy, with the
+	// placeholders of Hugo's permalinks, such as "/notes/:slug/". It's written into each
+	// note's frontmatter as its permalink. By default Eleventy's own URLs are followed.
+	Permalink string
+	// HugoConfig is the Hugo site's configuration file (config.toml, hugo.yaml and so
+	// on), whose baseURL, permalinks and uglyURLs links are made to match, so that they're
+	// the URLs the pages are published at. Its baseURL is the BasePath unless one is set.
+	HugoConfig string
+	hugoSite   *hugoSite
+	// LinkStyle chooses how links to notes are written in markdown: as their URLs, or as
+	// Hugo shortcodes that find them by their paths.
+	LinkStyle LinkStyle
+	// bundle is the note whose body is being converted under PageBundles.
+	bundle *markdownFile
+
+	// EmptyLinks decides what happens to links like [[]] that don't name a note. By
+	// default they're left as they are.
+	EmptyLinks EmptyLinkPolicy
+
+	// ReadErrors decides whether a file that can't be read ends the run (the default)
+	// or is skipped with a warning.
+	ReadErrors ReadErrorPolicy
+
+	// Dates configures how dates in frontmatter and filenames are read and written.
+	Dates DateFormat
+	// GitLastmod sets each note's lastmod to the date of the last commit to it, from the
+	// git repository the source directory is in. Hugo's enableGitInfo can't, since the
+	// notes it's given are copies outside of that repository.
+	GitLastmod bool
+	// GitDate decides whether notes without a date (other than date notes) are dated by
+	// their first commit, and whether that comes before or after the date of the latest
+	// note linking to them.
+	GitDate    GitDatePolicy
+	gitHistory map[string]gitDates
+
+	// TodoPage is the filename (such as "todos.md") of a page gathering every unchecked
+	// task from the notes. No page is made unless it's set.
+	TodoPage string
+	// TodoPageDates adds each note's date next to its tasks on the TodoPage.
+	TodoPageDates bool
+
+	// TypeIndexPage is the filename (such as "by-type.md") of a page listing the notes
+	// grouped by their type. No page is made unless it's set.
+	TypeIndexPage string
+	// TypeKey is the frontmatter key for a note's type. Defaults to "type".
+	TypeKey string
+
+	// TopNotesPage is the filename (such as "top-notes.md") of a page listing the notes
+	// with the most backlinks. No page is made unless it's set.
+	TopNotesPage string
+	// TopNotesLimit is how many notes the TopNotesPage lists. Defaults to 10.
+	TopNotesLimit int
+
+	// RandomNotesFile is the filename, relative to the destination, of a JSON list of the
+	// URLs of every note, for a "random note" link to pick from. Stubs, generated pages
+	// and drafts aren't listed. No list is written unless it's set.
+	RandomNotesFile string
+	// RandomNotesSkipDateFiles leaves date notes out of the RandomNotesFile.
+	RandomNotesSkipDateFiles bool
+
+	// BacklinksDataFile is the filename, relative to the destination, of a JSON object
+	// giving the backlinks of each note by its URL from the root of the site, for
+	// templates to use (such as "_data/backlinks.json" for Eleventy). No file is written
+	// unless it's set.
+	BacklinksDataFile string
+	// BacklinksDataKey chooses what the notes are known by in the BacklinksDataFile.
+	// Defaults to DataKeyURL, or to DataKeySlug under BacklinksDataOnly.
+	BacklinksDataKey DataKey
+	// BacklinksDataOnly writes the backlinks into the BacklinksDataFile in place of the
+	// generated sections, which are left off the pages as under SkipBacklinkSection, so
+	// that a template (such as a Hugo partial) renders them. The BacklinksDataFile
+	// defaults to "data/backlinks.json", where Hugo looks for data.
+	BacklinksDataOnly bool
+	// BacklinksShortcode is the name of a Hugo shortcode written in place of the list of
+	// backlinks, given the note's key in the BacklinksDataFile, as in
+	// {{< backlinks "page" >}}, so that the theme renders the backlinks from the data.
+	// The BacklinksDataFile and its keys default as under BacklinksDataOnly.
+	BacklinksShortcode string
+
+	// MergeSameDay combines notes whose filenames start with the same date (such as
+	// 2024-02-01.md and 2024-02-01-notes.md) into a single journal entry.
+	MergeSameDay bool
+
+	// DefaultAuthor is set as the author of notes that don't have one.
+	DefaultAuthor string
+	// DirectoryAuthors sets the default author for the notes in a directory (relative
+	// to the source directory, "." for the top), overriding DefaultAuthor.
+	DirectoryAuthors map[string]string
+	// AuthorKey is the frontmatter key for the author. Defaults to "author".
+	AuthorKey string
+
+	// DefaultFrontmatter is added to the frontmatter of every note (and stub) that
+	// doesn't already have each key, such as a layout or type for all of them.
+	DefaultFrontmatter map[string]interface{}
+
+	// BacklinkParams lists each note's backlinks in its frontmatter, so that Hugo
+	// templates can use them as .Params.backlinks. Each has a title, a root-relative (or,
+	// when BasePath is a full URL, absolute) permalink, and the date when there is one.
+	BacklinkParams bool
+	// BacklinkParamsKey is the frontmatter key for BacklinkParams. Defaults to "backlinks".
+	BacklinkParamsKey string
+
+	// SkipDrafts leaves notes marked `draft: true` (or `publish: false`, as Obsidian
+	// Publish has it) out of the output and out of other notes' backlinks.
+	SkipDrafts bool
+	// DraftLinks decides what links to a skipped draft become. By default they're
+	// plain text.
+	DraftLinks DraftLinkPolicy
+
+	// Logger receives progress and warnings. Defaults to the standard logger.
+	Logger *log.Logger
+	// LogFile is a file (relative to the destination unless it's an absolute path)
+	// that the run's log is also written to, with timestamps. It's replaced each run,
+	// except for dry runs, which don't write one.
+	LogFile string
+	// LogFileOnly sends the log only to LogFile rather than to Logger as well.
+	LogFileOnly bool
+	// LogFileRotate keeps the logs of the last few runs, as LogFile.1, LogFile.2 and
+	// so on, rather than replacing LogFile.
+	LogFileRotate bool
+
+	// ContentHash adds a hash of each note's body to its frontmatter, for spotting
+	// changed notes downstream.
+	ContentHash bool
+	// ContentHashKey is the frontmatter key for the hash. Defaults to "content_hash".
+	ContentHashKey string
+
+	// NormalizeTags lower-cases and trims each tag in the frontmatter, and removes the
+	// duplicates that leaves.
+	NormalizeTags bool
+	// TagAliases replaces tags with the tag they're an alias of (such as "golang" with
+	// "go"), removing any duplicates that leaves.
+	TagAliases map[string]string
+	// SortTags sorts the tags in the frontmatter.
+	SortTags bool
+	// TagsAsList writes tags given as a comma-separated string as a list.
+	TagsAsList bool
+
+	// FeedLimit is the most items WriteJSONFeed includes. Defaults to 20.
+	FeedLimit int
+
+	// CacheDir is where state is kept from one run to the next. Defaults to a directory
+	// for the source directory under the user's cache directory (see os.UserCacheDir).
+	CacheDir string
+	// SkipUnchanged leaves alone the output files that are just as the last run (by way
+	// of the cache) wrote them, so their modification times only change with them.
+	SkipUnchanged bool
+	manifest      *outputManifest
+	// ResolutionIndex keeps the names each note can be linked by (its aliases and title)
+	// in the cache, so that they're only read from notes that have changed.
+	ResolutionIndex bool
+	cache           *runCache
+	// Incremental keeps the links found in each note in the cache, with a hash of the
+	// note, so that only the notes that have changed are parsed again. It turns on
+	// SkipUnchanged and ResolutionIndex too, so that only the output that has changed is
+	// written.
+	Incremental bool
+	linkState   *linkState
+	// RenameAliases adds the URLs a note had before it was renamed or moved to its Hugo
+	// aliases, so that links and bookmarks to them still work. The cache keeps each
+	// note's URL from one run to the next, and a renamed note is recognized by its text
+	// being just as it was under its old name.
+	RenameAliases bool
+
+	// Concurrency is how many notes are read and parsed at once. It defaults to the number
+	// of CPUs.
+	Concurrency int
+
+	// Labels replaces the English text of the generated sections and pages.
+	Labels Labels
+	// SectionHeadingLevel is the level of the headings of the generated sections, from 1
+	// for "# Backlinks" to 6. It defaults to 2.
+	SectionHeadingLevel int
+	// HideBacklinksHeading leaves the heading off the backlinks section, for notes (or
+	// themes) that give the list a heading of their own.
+	HideBacklinksHeading bool
+	// GroupBacklinks divides the backlinks section under subheadings, by the folder of
+	// each linking note or by the value of GroupBacklinksKey in its frontmatter.
+	GroupBacklinks    BacklinkGrouping
+	GroupBacklinksKey string
+	// MaxBacklinks is the most backlinks listed for a note, with the number of the rest
+	// after them, as in Labels.MoreBacklinks. 0 lists them all.
+	MaxBacklinks int
+	// MoreBacklinksDetails lists the backlinks past MaxBacklinks as well, in a <details>
+	// block that's collapsed until the number is clicked.
+	MoreBacklinksDetails bool
+}
+
+// LinkTitlePolicy chooses whether links can match frontmatter titles, and whether a
+// title or a filename match comes first.
+type LinkTitlePolicy string
+
+const (
+	// LinkTitlesOff matches links against filenames (and aliases) only.
+	LinkTitlesOff LinkTitlePolicy = ""
+	// LinkTitlesFallback matches a link against titles when no filename matches it.
+	LinkTitlesFallback LinkTitlePolicy = "fallback"
+	// LinkTitlesFirst matches a link against titles before filenames.
+	LinkTitlesFirst LinkTitlePolicy = "first"
+)
+
+// LinkStyle chooses how links to notes are written.
+type LinkStyle string
+
+const (
+	// LinkStyleURL links to notes by their URLs, such as ./page/.
+	LinkStyleURL LinkStyle = ""
+	// LinkStyleRelref links to notes with Hugo's relref shortcode, given the path of the
+	// page, so that Hugo fails the build on links to pages that don't exist, and the
+	// links follow the site's permalink configuration.
+	LinkStyleRelref LinkStyle = "relref"
+	// LinkStyleRef is LinkStyleRelref with Hugo's ref shortcode, for absolute links.
+	LinkStyleRef LinkStyle = "ref"
+	// LinkStyleJekyll links to notes with Jekyll's link tag, given the path of the page,
+	// for TargetJekyll.
+	LinkStyleJekyll LinkStyle = "link"
+)
+
+// DataKey chooses what notes are known by in data files.
+type DataKey string
+
+const (
+	// DataKeyURL knows notes by their URLs from the root of the site, such as
+	// "/garden/": what Hugo's .RelPermalink and Eleventy's page.url give.
+	DataKeyURL DataKey = "url"
+	// DataKeySlug knows notes by their slugs, or by their names as they are in URLs when
+	// they don't have one, such as "garden".
+	DataKeySlug DataKey = "slug"
+)
+
+// GitDatePolicy chooses whether notes without a date take the date of their first
+// commit, and whether that or the date of the notes linking to them comes first.
+type GitDatePolicy string
+
+const (
+	// GitDateOff dates notes by the latest of the notes linking to them only.
+	GitDateOff GitDatePolicy = ""
+	// GitDateFallback dates notes by their first commit when no note linking to them has
+	// a date.
+	GitDateFallback GitDatePolicy = "fallback"
+	// GitDateFirst dates notes by their first commit, and by the notes linking to them
+	// only when they've never been committed.
+	GitDateFirst GitDatePolicy = "first"
+)
+
+// EmptyLinkPolicy chooses what happens to empty links.
+type EmptyLinkPolicy string
+
+const (
+	// EmptyLinksLiteral leaves empty links in the text as they are.
+	EmptyLinksLiteral EmptyLinkPolicy = ""
+	// EmptyLinksDrop removes empty links from the text.
+	EmptyLinksDrop EmptyLinkPolicy = "drop"
+	// EmptyLinksError leaves empty links as they are and reports them as warnings,
+	// which fail the run in strict mode.
+	EmptyLinksError EmptyLinkPolicy = "error"
+)
+
+// DraftLinkPolicy chooses what happens to links to drafts when drafts are skipped.
+type DraftLinkPolicy string
+
+const (
+	// DraftLinksPlain turns links to drafts into plain text.
+	DraftLinksPlain DraftLinkPolicy = ""
+	// DraftLinksKeep leaves links to drafts as links, even though the page won't exist.
+	DraftLinksKeep DraftLinkPolicy = "keep"
+	// DraftLinksError turns links to drafts into plain text and reports them as
+	// warnings, which fail the run in strict mode.
+	DraftLinksError DraftLinkPolicy = "error"
+)
+
+// ReadErrorPolicy chooses what happens when a source file can't be read.
+type ReadErrorPolicy string
+
+const (
+	// ReadErrorsFail stops processing at the first file that can't be read.
+	ReadErrorsFail ReadErrorPolicy = ""
+	// ReadErrorsSkip leaves unreadable files out of the output and reports them.
+	ReadErrorsSkip ReadErrorPolicy = "skip"
+)
+
+// SidecarMode chooses whether JSON sidecar files are written.
+type SidecarMode string
+
+const (
+	// SidecarsOff writes only markdown.
+	SidecarsOff SidecarMode = ""
+	// SidecarsAlongside writes a JSON sidecar next to each markdown file.
+	SidecarsAlongside SidecarMode = "alongside"
+	// SidecarsOnly writes JSON sidecars instead of markdown.
+	SidecarsOnly SidecarMode = "only"
+)
+
+func (o *Options) wordsPerMinute() int {
+	if o.WordsPerMinute <= 0 {
+		return 200
+	}
+	return o.WordsPerMinute
+}
+
+func (o *Options) wordCountKey() string {
+	if o.WordCountKey == "" {
+		return "word_count"
+	}
+	return o.WordCountKey
+}
+
+func (o *Options) readingTimeKey() string {
+	if o.ReadingTimeKey == "" {
+		return "reading_time"
+	}
+	return o.ReadingTimeKey
+}
+
+func (o *Options) summaryKey() string {
+	if o.SummaryKey == "" {
+		return "summary"
+	}
+	return o.SummaryKey
+}
+
+func (o *Options) progress(done int, total int, current string) {
+	if o.Progress != nil {
+		o.Progress(done, total, current)
+	}
+}
+
+func (o *Options) authorKey() string {
+	if o.AuthorKey == "" {
+		return "author"
+	}
+	return o.AuthorKey
+}
+
+func (o *Options) backlinkParamsKey() string {
+	if o.BacklinkParamsKey == "" {
+		return "backlinks"
+	}
+	return o.BacklinkParamsKey
+}
+
+func (o *Options) contentHashKey() string {
+	if o.ContentHashKey == "" {
+		return "content_hash"
+	}
+	return o.ContentHashKey
+}
+
+func (o *Options) feedLimit() int {
+	if o.FeedLimit <= 0 {
+		return 20
+	}
+	return o.FeedLimit
+}
+
+func (o *Options) topNotesLimit() int {
+	if o.TopNotesLimit <= 0 {
+		return 10
+	}
+	return o.TopNotesLimit
+}
+
+func (o *Options) typeKey() string {
+	if o.TypeKey == "" {
+		return "type"
+	}
+	return o.TypeKey
+}
@@ -0,0 +1,72 @@
+package backlinker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// outboundLink is a link from a note, as written by WriteOutboundLinks.
+type outboundLink struct {
+	Target string `json:"target"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+	// Resolved is false for a dangling link: one to a note that doesn't exist, which
+	// goes to the stub written for it instead.
+	Resolved bool `json:"resolved"`
+}
+
+// noteSlug is the last part of the URL of a file: its lower case name with spaces
+// replaced by hyphens.
+func noteSlug(file *markdownFile) string {
+	return strings.Trim(strings.TrimPrefix(createHugoLink(file.OriginalName), "."), "/")
+}
+
+// WriteOutboundLinks writes, for each note, the notes it links to and their URLs in the
+// order they're first linked, as a JSON object keyed by the note's slug.
+func WriteOutboundLinks(files FileMap, w io.Writer) error {
+	opts := Options{}
+	outbound := make(map[string][]outboundLink)
+	for _, file := range includedFiles(files) {
+		if file.IsNew {
+			continue
+		}
+		links := []outboundLink{}
+		for _, other := range file.forwardLinks {
+			links = append(links, outboundLink{
+				Target:   noteSlug(other),
+				Title:    other.Title,
+				URL:      opts.linkTo(other),
+				Resolved: !other.IsNew,
+			})
+		}
+		outbound[noteSlug(file)] = links
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(outbound)
+}
+
+// addOutgoingLinks adds a section for the notes the file links to, when
+// Options.OutgoingLinks asks for it. Drafts that are being left out aren't listed, since
+// the links to them aren't links any more.
+func addOutgoingLinks(file *markdownFile, opts *Options, writer io.Writer) error {
+	if !opts.OutgoingLinks {
+		return nil
+	}
+	var links []*markdownFile
+	for _, other := range file.forwardLinks {
+		if !other.unpublished && !other.unwritten {
+			links = append(links, other)
+		}
+	}
+	if len(links) == 0 {
+		return nil
+	}
+	_, _ = writer.Write([]byte(opts.sectionHeading(opts.labels().OutgoingLinks)))
+	for _, other := range links {
+		_, _ = fmt.Fprintf(writer, "- [%s](%s)\n", other.Title, opts.pageLink(other, ""))
+	}
+	return nil
+}
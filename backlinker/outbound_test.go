@@ -0,0 +1,51 @@
+package backlinker
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteOutboundLinks(t *testing.T) {
+	require := require.New(t)
+	sourceDir, _ := writeVault(t, map[string]string{
+		"Garden Plan.md": "Plant [[Tomatoes]] next to [[Basil]], then more [[Tomatoes]].\n",
+		"Tomatoes.md":    "Red.\n",
+		"Quiet.md":       "No links.\n",
+	})
+	files, err := LoadFiles(sourceDir, Options{})
+	require.NoError(err)
+
+	writer := bytes.Buffer{}
+	require.NoError(WriteOutboundLinks(files, &writer))
+	var outbound map[string][]outboundLink
+	require.NoError(json.Unmarshal(writer.Bytes(), &outbound))
+	require.Equal([]outboundLink{
+		{Target: "tomatoes", Title: "Tomatoes", URL: "./tomatoes/", Resolved: true},
+		{Target: "basil", Title: "Basil", URL: "./basil/", Resolved: false},
+	}, outbound["garden-plan"])
+	require.Equal([]outboundLink{}, outbound["quiet"])
+	require.NotContains(outbound, "basil", "Stubs have no links of their own")
+}
+
+func TestOutgoingLinksSection(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden Plan.md": "Plant [[Tomatoes]] next to [[Basil]], then more [[Tomatoes]].\n",
+		"Tomatoes.md":    "---\ntitle: Red Tomatoes\n---\nFor the [[Garden Plan]].\n",
+		"Quiet.md":       "No links.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{OutgoingLinks: true}))
+
+	plan, err := ioutil.ReadFile(filepath.Join(destDir, "Garden Plan.md"))
+	require.NoError(err)
+	require.Contains(string(plan), "## Links from this note\n\n- [Red Tomatoes](./tomatoes/)\n- [Basil](./basil/)\n")
+	require.Regexp(`(?s)## Backlinks.*## Links from this note`, string(plan))
+	quiet, err := ioutil.ReadFile(filepath.Join(destDir, "Quiet.md"))
+	require.NoError(err)
+	require.NotContains(string(quiet), "Links from this note")
+}
@@ -0,0 +1,38 @@
+package backlinker
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v2"
+)
+
+// addGeneratedPage adds a page made up entirely by this tool (an index or a summary of
+// the vault) to the map so that it is written out with the others. It takes the place
+// of a stub of the same name, but never of a real file.
+func addGeneratedPage(fileMap map[string]*markdownFile, filename string, title string, body string,
+	opts *Options) error {
+	key := fileKey(filename)
+	if existing, exists := fileMap[key]; exists && !existing.IsNew {
+		opts.warnf(WarnBadConfig, filename, "not generated because a note with that name already exists")
+		return nil
+	}
+	page := createMarkdownFile(filename, false)
+	page.Title = title
+	page.generated = true
+	page.metadata["title"] = title
+	page.convertedBody = body
+	frontmatter, err := yaml.Marshal(page.metadata)
+	if err != nil {
+		return err
+	}
+	page.newData = bytes.NewBuffer([]byte{})
+	page.newData.WriteString("---\n")
+	page.newData.Write(frontmatter)
+	page.newData.WriteString("---\n")
+	page.newData.WriteString(body)
+	if existing, exists := fileMap[key]; exists {
+		page.BackLinks = existing.BackLinks
+	}
+	fileMap[key] = page
+	return nil
+}
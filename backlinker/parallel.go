@@ -0,0 +1,55 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path"
+	"runtime"
+	"sync"
+)
+
+func (o *Options) concurrency() int {
+	if o.Concurrency <= 0 {
+		return runtime.NumCPU()
+	}
+	return o.Concurrency
+}
+
+// inParallel calls work for each index below count, on up to Options.Concurrency
+// goroutines at once, and returns once they've all finished. Work must only touch what
+// belongs to its own index: anything shared is left for afterwards, in order, so that
+// the results are the same however many goroutines there are.
+func (o *Options) inParallel(count int, work func(i int)) {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	workers := o.concurrency()
+	if workers > count {
+		workers = count
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				work(i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// readSourceFiles reads the text of each of the files (other than stubs, which have
+// none) from the source directory, several at once.
+func readSourceFiles(sourceDir string, files []*markdownFile, opts *Options) ([][]byte, []error) {
+	texts := make([][]byte, len(files))
+	errs := make([]error, len(files))
+	opts.inParallel(len(files), func(i int) {
+		if !files[i].IsNew {
+			texts[i], errs[i] = ioutil.ReadFile(path.Join(sourceDir, files[i].relativePath()))
+		}
+	})
+	return texts, errs
+}
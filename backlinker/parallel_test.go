@@ -0,0 +1,54 @@
+package backlinker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyDoesNotChangeOutput(t *testing.T) {
+	require := require.New(t)
+	notes := map[string]string{}
+	for i := 0; i < 20; i++ {
+		notes[fmt.Sprintf("Note %d.md", i)] = fmt.Sprintf("Links to [[Note %d]] and [[Note %d]].\n\nAlso [[Missing %d]].\n",
+			(i+1)%20, (i+7)%20, i%3)
+	}
+	sourceDir, serialDir := writeVault(t, notes)
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, serialDir, Options{Concurrency: 1}))
+	parallelDir := t.TempDir()
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, parallelDir, Options{Concurrency: 8}))
+
+	serial, err := ioutil.ReadDir(serialDir)
+	require.NoError(err)
+	require.Len(serial, 23, "The notes and their three stubs")
+	for _, info := range serial {
+		expected, err := ioutil.ReadFile(filepath.Join(serialDir, info.Name()))
+		require.NoError(err)
+		actual, err := ioutil.ReadFile(filepath.Join(parallelDir, info.Name()))
+		require.NoError(err)
+		require.Equal(string(expected), string(actual), info.Name())
+	}
+}
+
+func TestInParallel(t *testing.T) {
+	require := require.New(t)
+	opts := Options{Concurrency: 3}
+	var mutex sync.Mutex
+	seen := map[int]int{}
+	opts.inParallel(50, func(i int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		seen[i]++
+	})
+	require.Len(seen, 50)
+	for i := 0; i < 50; i++ {
+		require.Equal(1, seen[i], "index %d", i)
+	}
+	opts.inParallel(0, func(i int) {
+		t.Errorf("called with %d when there was nothing to do", i)
+	})
+}
@@ -0,0 +1,70 @@
+package backlinker
+
+import (
+	"path"
+	"strings"
+)
+
+// linkKeys are the keys a file is stored under in the file map: its name alone, as given
+// by fileKey, and then its name with more and more of the directories it's in, up to its
+// whole path relative to the source directory. A link such as [[projects/roadmap]] can
+// then pick out one of several notes called roadmap.
+func linkKeys(filename string) []string {
+	parts := strings.Split(strings.ToLower(removeExtension(filename)), "/")
+	keys := make([]string, 0, len(parts))
+	for i := len(parts) - 1; i >= 0; i-- {
+		keys = append(keys, strings.Join(parts[i:], "/")+".md")
+	}
+	return keys
+}
+
+// pathDepth counts the directories a file is in, below the source directory.
+func pathDepth(filename string) int {
+	return strings.Count(path.Clean(filename), "/")
+}
+
+// linkName is the shortest name a link to the file can use and still mean it: its name
+// alone, unless another note closer to the top of the source directory has that name.
+func linkName(fileMap map[string]*markdownFile, file *markdownFile) string {
+	parts := strings.Split(removeExtension(file.relativePath()), "/")
+	for i := len(parts) - 1; i > 0; i-- {
+		name := strings.Join(parts[i:], "/")
+		if fileMap[strings.ToLower(name)+".md"] == file {
+			return name
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// newStub makes the stub for a link to a note that doesn't exist. A link with a path,
+// such as [[projects/roadmap]], gets its stub in that directory.
+func (o *Options) newStub(name string) *markdownFile {
+	name = strings.Trim(path.Clean(name), "/")
+	file := o.newMarkdownFile(path.Base(name)+".md", true)
+	if dir := path.Dir(name); dir != "." {
+		file.dir = dir
+	}
+	return file
+}
+
+// Sections are the Hugo sections (directories of the destination) that kinds of notes are
+// written to, such as "journal" for date notes and "notes" for the rest. Links between
+// notes follow them there. Notes are written to the top of the destination when their
+// kind has no section, and the pages made here, such as the TodoPage, always are.
+type Sections struct {
+	// DateFiles is the section of the notes named for a date.
+	DateFiles string `yaml:"date_files"`
+	// Notes is the section of every other note, stubs included unless Options.StubDir
+	// says otherwise.
+	Notes string `yaml:"notes"`
+}
+
+func (s Sections) sectionFor(file *markdownFile) string {
+	switch {
+	case file.generated:
+		return ""
+	case file.IsDateFile:
+		return strings.Trim(path.Clean("/"+s.DateFiles), "/")
+	}
+	return strings.Trim(path.Clean("/"+s.Notes), "/")
+}
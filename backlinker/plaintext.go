@@ -0,0 +1,65 @@
+package backlinker
+
+import (
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// ContextStyle chooses how the context of each backlink is shown.
+type ContextStyle string
+
+const (
+	// ContextConverted shows the context as markdown, with its wikilinks converted.
+	ContextConverted ContextStyle = ""
+	// ContextPlain shows the context as plain text, with links, emphasis, code and the
+	// like reduced to their text.
+	ContextPlain ContextStyle = "plain"
+)
+
+// plainText renders a snippet of markdown as a single line of readable text.
+func plainText(markdown string) string {
+	source := []byte(markdown)
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+	document := md.Parser().Parse(text.NewReader(source))
+	// Wikilinks aren't parsed here, so they're reduced to their text afterwards, one run
+	// of text at a time so that those inside code spans are left alone.
+	var textRuns []string
+	var current strings.Builder
+	endRun := func() {
+		textRuns = append(textRuns, unwrapWikilinks(current.String()))
+		current.Reset()
+	}
+	_ = ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.CodeSpan:
+			endRun()
+			var code strings.Builder
+			for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+				if t, ok := child.(*ast.Text); ok {
+					code.Write(t.Segment.Value(source))
+				}
+			}
+			textRuns = append(textRuns, code.String())
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			current.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				current.WriteString(" ")
+			}
+		case *ast.AutoLink:
+			current.Write(n.Label(source))
+		case *ast.Paragraph, *ast.TextBlock, *ast.Heading:
+			current.WriteString(" ")
+		}
+		return ast.WalkContinue, nil
+	})
+	endRun()
+	return strings.Join(strings.Fields(strings.Join(textRuns, "")), " ")
+}
@@ -0,0 +1,36 @@
+package backlinker
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlainText(t *testing.T) {
+	require := require.New(t)
+	require.Equal("Some bold and italic words about Gardens with code and a link.",
+		plainText("Some **bold** and _italic_ words about [[Gardens]] with `code` and [a link](https://example.com)."))
+	require.Equal("A task list item", plainText("- [ ] A task list item"))
+	require.Equal("A heading", plainText("## A heading"))
+	require.Equal("See https://example.com and the alt text", plainText("See <https://example.com> and ![the alt text](pic.png)"))
+}
+
+func TestPlainContexts(t *testing.T) {
+	require := require.New(t)
+	fileMap := linkFiles(map[string]string{
+		"First.md":  "Read **all** about [[Second]] and `[[Third]]`.\n",
+		"Second.md": "Nothing here.\n",
+	})
+	second := fileMap["second.md"]
+
+	writer := bytes.Buffer{}
+	err := addBacklinks(second, fileMap, &Options{ContextStyle: ContextPlain, HighlightContextLink: true}, &writer)
+	require.NoError(err)
+	require.Equal(`
+## Backlinks
+
+- [First](./first/)
+    - Read all about Second and [[Third]].
+`, writer.String())
+}
@@ -0,0 +1,41 @@
+package backlinker
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+)
+
+// randomNoteURLs returns the URLs of the notes a "random note" link may go to, in
+// filename order: every note other than stubs, generated pages and drafts (whether or
+// not they're written), and date notes too unless Options.RandomNotesSkipDateFiles is set.
+func randomNoteURLs(fileMap map[string]*markdownFile, opts *Options) []string {
+	urls := []string{}
+	for _, file := range includedFiles(fileMap) {
+		if file.IsNew || file.generated || isDraft(file) || (file.IsDateFile && opts.RandomNotesSkipDateFiles) {
+			continue
+		}
+		urls = append(urls, opts.permalink(file))
+	}
+	return urls
+}
+
+// writeRandomNotes writes the list of URLs for a "random note" link to pick from, as a
+// JSON array, into Options.RandomNotesFile.
+func writeRandomNotes(destDir string, fileMap map[string]*markdownFile, opts *Options) error {
+	if opts.RandomNotesFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(randomNoteURLs(fileMap, opts), "", "  ")
+	if err != nil {
+		return err
+	}
+	filename := path.Join(destDir, opts.RandomNotesFile)
+	if opts.DryRun == nil {
+		err = os.MkdirAll(path.Dir(filename), 0755)
+		if err != nil {
+			return err
+		}
+	}
+	return opts.writeOutput(filename, append(data, '\n'))
+}
@@ -0,0 +1,45 @@
+package backlinker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandomNotes(t *testing.T) {
+	require := require.New(t)
+	vault := map[string]string{
+		"Garden.md":     "Links to [[Missing]].\n",
+		"Recipes.md":    "Food.\n",
+		"Secret.md":     "---\ndraft: true\n---\nHidden.\n",
+		"2024-02-01.md": "A day.\n",
+	}
+	readList := func(opts Options) []string {
+		sourceDir, destDir := writeVault(t, vault)
+		opts.RandomNotesFile = "random.json"
+		opts.TypeIndexPage = "by-type.md"
+		opts.BasePath = "/notes/"
+		require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+		data, err := ioutil.ReadFile(filepath.Join(destDir, "random.json"))
+		require.NoError(err)
+		var urls []string
+		require.NoError(json.Unmarshal(data, &urls))
+		return urls
+	}
+
+	require.Equal([]string{"/notes/2024-02-01/", "/notes/garden/", "/notes/recipes/"},
+		readList(Options{SkipDrafts: true}))
+	require.Equal([]string{"/notes/garden/", "/notes/recipes/"},
+		readList(Options{RandomNotesSkipDateFiles: true}), "Drafts are left out even when they're written")
+
+	sourceDir, destDir := writeVault(t, vault)
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{}))
+	require.NoFileExists(filepath.Join(destDir, "random.json"))
+
+	sourceDir, destDir = writeVault(t, vault)
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{RandomNotesFile: "static/data/random.json"}))
+	require.FileExists(filepath.Join(destDir, "static", "data", "random.json"), "The directories the list goes in are made")
+}
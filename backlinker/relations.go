@@ -0,0 +1,93 @@
+package backlinker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Relation is a frontmatter key that names related notes, such as `parent: project-x`
+// or `children: [a, b]`. The notes named become links in the graph and are listed in a
+// section of their own.
+type Relation struct {
+	// Key is the frontmatter key, holding a note's name or a list of them.
+	Key string
+	// Heading is the heading of the section, on the note with the key, listing the
+	// notes it names.
+	Heading string
+	// InverseHeading is the heading of the section, on each note named, listing the
+	// notes naming it. Without one, the notes named don't get a section.
+	InverseHeading string
+}
+
+// relatedNote is a note listed in one of a note's relation sections.
+type relatedNote struct {
+	Heading string
+	Other   *markdownFile
+}
+
+// collectRelations resolves the notes named by each of Options.Relations, recording them
+// on both notes. The note with the key also links to the notes it names, for the graph.
+func collectRelations(fileMap map[string]*markdownFile, opts *Options) {
+	for _, file := range includedFiles(fileMap) {
+		for _, relation := range opts.Relations {
+			for _, name := range metadataStrings(file, relation.Key) {
+				key := backlinkCollector{}.Normalize(strings.TrimSpace(name))
+				other, exists := opts.resolveLink(fileMap, key)
+				if !exists || other.IsNew || other.unpublished || other.unreadable {
+					opts.warnf(WarnDanglingLink, file.OriginalName, "%s: %s doesn't match any file", relation.Key, name)
+					continue
+				}
+				file.relatedLinks = append(file.relatedLinks, other)
+				file.related = append(file.related, relatedNote{Heading: relation.Heading, Other: other})
+				if relation.InverseHeading != "" {
+					other.related = append(other.related, relatedNote{Heading: relation.InverseHeading, Other: file})
+				}
+			}
+		}
+	}
+}
+
+// relationHeadings returns the headings of the relation sections, in the order they're
+// written.
+func (o *Options) relationHeadings() []string {
+	var headings []string
+	seen := make(map[string]bool)
+	for _, relation := range o.Relations {
+		for _, heading := range []string{relation.Heading, relation.InverseHeading} {
+			if heading != "" && !seen[heading] {
+				seen[heading] = true
+				headings = append(headings, heading)
+			}
+		}
+	}
+	return headings
+}
+
+// relationSections returns one section for each relation heading the file has notes
+// under, each listing its notes by title.
+func relationSections(file *markdownFile, opts *Options) []generatedSection {
+	var sections []generatedSection
+	for _, heading := range opts.relationHeadings() {
+		var others []*markdownFile
+		seen := make(map[*markdownFile]bool)
+		for _, related := range file.related {
+			if related.Heading == heading && !seen[related.Other] {
+				seen[related.Other] = true
+				others = append(others, related.Other)
+			}
+		}
+		var content strings.Builder
+		if len(others) > 0 {
+			sort.SliceStable(others, func(i, j int) bool {
+				return opts.compareTitles(others[i].Title, others[j].Title) < 0
+			})
+			content.WriteString(opts.sectionHeading(heading))
+			for _, other := range others {
+				content.WriteString(fmt.Sprintf("- [%s](%s)\n", other.Title, opts.pageLink(other, "")))
+			}
+		}
+		sections = append(sections, generatedSection{Name: "relation-" + headingSlug(heading), Content: content.String()})
+	}
+	return sections
+}
@@ -0,0 +1,74 @@
+package backlinker
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var parentRelations = []Relation{
+	{Key: "parent", Heading: "Parent", InverseHeading: "Children"},
+	{Key: "children", Heading: "Children", InverseHeading: "Parent"},
+}
+
+func TestParentRelation(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Project X.md": "---\nchildren: [Design]\n---\nThe project.\n",
+		"Kickoff.md":   "---\nparent: project x\n---\nFirst meeting.\n",
+		"Design.md":    "---\nparent: Project X\n---\nThe design.\n",
+		"Orphan.md":    "---\nparent: Nowhere\n---\nLost.\n",
+	})
+	report := Report{}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{Relations: parentRelations, Report: &report})
+	require.NoError(err)
+
+	project, err := ioutil.ReadFile(filepath.Join(destDir, "Project X.md"))
+	require.NoError(err)
+	require.Equal(`---
+children: [Design]
+title: Project X
+---
+The project.
+
+<!-- sharedbrain:relation-children:start -->
+
+## Children
+
+- [Design](./design/)
+- [Kickoff](./kickoff/)
+
+<!-- sharedbrain:relation-children:end -->
+`, string(project))
+
+	kickoff, err := ioutil.ReadFile(filepath.Join(destDir, "Kickoff.md"))
+	require.NoError(err)
+	require.Contains(string(kickoff), "\n## Parent\n\n- [Project X](./project-x/)\n")
+	design, err := ioutil.ReadFile(filepath.Join(destDir, "Design.md"))
+	require.NoError(err)
+	require.Equal(1, bytes.Count(design, []byte("- [Project X](./project-x/)")), "Named from both ends, listed once")
+
+	require.Len(report.Warnings, 1)
+	require.Equal(WarnDanglingLink, report.Warnings[0].Kind)
+	require.NoFileExists(filepath.Join(destDir, "Nowhere.md"))
+}
+
+func TestRelationsInGraph(t *testing.T) {
+	require := require.New(t)
+	sourceDir, _ := writeVault(t, map[string]string{
+		"Project X.md": "The project.\n",
+		"Design.md":    "---\nparent: Project X\n---\nThe design, for [[Project X]].\n",
+		"Kickoff.md":   "---\nparent: Project X\n---\nFirst meeting.\n",
+	})
+	opts := Options{Relations: parentRelations}
+	files, err := LoadFiles(sourceDir, opts)
+	require.NoError(err)
+	var edges []string
+	for _, edge := range collectEdges(files) {
+		edges = append(edges, edge.From.OriginalName+" -> "+edge.To.OriginalName)
+	}
+	require.Equal([]string{"Design.md -> Project X.md", "Kickoff.md -> Project X.md"}, edges)
+}
@@ -0,0 +1,130 @@
+package backlinker
+
+import (
+	"fmt"
+	"sort"
+)
+
+// urlStateFile is the name of the cache file holding the URL of each note.
+const urlStateFile = "urls.json"
+
+// urlState is the URL each note had last run, by the note's path relative to the source
+// directory, under Options.RenameAliases.
+type urlState struct {
+	Files map[string]*noteURL `json:"files"`
+}
+
+// noteURL is a note's URL, the URLs it had before, and the hash of its text, which is
+// how a note that's been renamed is recognized.
+type noteURL struct {
+	Hash    string   `json:"hash"`
+	URL     string   `json:"url"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// trackRenames finds the notes that have moved since the last run, under
+// Options.RenameAliases, and gives each the URLs it had before. A note has moved when it
+// has a new URL at the same path, or when it's at a path that wasn't read last run and
+// the one note that was, but is gone now, had the same text. The URLs are saved for the
+// next run.
+func trackRenames(filetexts map[*markdownFile][]byte, opts *Options) error {
+	previous := &urlState{}
+	err := opts.cache.read(urlStateFile, previous)
+	if err != nil {
+		return err
+	}
+	files := make([]*markdownFile, 0, len(filetexts))
+	for file := range filetexts {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].relativePath() < files[j].relativePath()
+	})
+
+	// A hash that more than one note has (such as that of an empty note) can't tell which
+	// of them was renamed, so it's left out
+	current := make(map[string]bool)
+	newNotes := make(map[string][]*markdownFile)
+	for _, file := range files {
+		current[file.relativePath()] = true
+		if _, existed := previous.Files[file.relativePath()]; !existed {
+			hash := textHash(filetexts[file])
+			newNotes[hash] = append(newNotes[hash], file)
+		}
+	}
+	goneNotes := make(map[string][]*noteURL)
+	for name, entry := range previous.Files {
+		if !current[name] {
+			goneNotes[entry.Hash] = append(goneNotes[entry.Hash], entry)
+		}
+	}
+
+	state := &urlState{Files: make(map[string]*noteURL, len(files))}
+	for _, file := range files {
+		hash := textHash(filetexts[file])
+		entry := &noteURL{Hash: hash, URL: opts.siteLink(file)}
+		before, existed := previous.Files[file.relativePath()]
+		if !existed && len(newNotes[hash]) == 1 && len(goneNotes[hash]) == 1 {
+			before = goneNotes[hash][0]
+			opts.logf("%s was renamed from %s\n", file.relativePath(), before.URL)
+		}
+		if before != nil {
+			for _, alias := range append(before.Aliases, before.URL) {
+				if alias != entry.URL && !containsString(entry.Aliases, alias) {
+					entry.Aliases = append(entry.Aliases, alias)
+				}
+			}
+		}
+		file.oldURLs = entry.Aliases
+		state.Files[file.relativePath()] = entry
+	}
+	if opts.DryRun != nil {
+		return nil
+	}
+	return opts.cache.write(urlStateFile, state)
+}
+
+// siteLink is the link to the file from the root of the site, whatever Options.BasePath is.
+func (o *Options) siteLink(file *markdownFile) string {
+	rooted := *o
+	rooted.BasePath = "/"
+	return rooted.linkTo(file)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRenameAliases adds the URLs the file had before it was renamed to its aliases,
+// after any it has already.
+func applyRenameAliases(file *markdownFile, opts *Options) {
+	if len(file.oldURLs) == 0 {
+		return
+	}
+	var aliases []interface{}
+	switch existing := file.metadata[opts.frontmatterKey("aliases")].(type) {
+	case string:
+		aliases = append(aliases, existing)
+	case []interface{}:
+		aliases = append(aliases, existing...)
+	}
+	for _, url := range file.oldURLs {
+		if !containsString(stringsOf(aliases), url) {
+			aliases = append(aliases, url)
+		}
+	}
+	file.metadata[opts.frontmatterKey("aliases")] = aliases
+}
+
+func stringsOf(list []interface{}) []string {
+	result := make([]string, len(list))
+	for i, item := range list {
+		result[i] = fmt.Sprint(item)
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameAliases(t *testing.T) {
+	require := require.New(t)
+	sourceDir, _ := writeVault(t, map[string]string{
+		"Garden.md": "Plants by the [[Pond]].\n",
+		"Pond.md":   "Still water.\n",
+		"Notes.md":  "",
+		"Draft.md":  "",
+	})
+	opts := Options{RenameAliases: true, CacheDir: t.TempDir(), BasePath: "/notes/"}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, t.TempDir(), opts))
+
+	rename := func(from string, to string) {
+		require.NoError(os.Rename(filepath.Join(sourceDir, from), filepath.Join(sourceDir, to)))
+	}
+	rename("Pond.md", "Lily Pond.md")
+	rename("Notes.md", "Ideas.md")
+	rename("Draft.md", "Plans.md")
+	require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "Garden.md"),
+		[]byte("---\nslug: the-garden\naliases: [/plants/]\n---\nPlants by the [[Lily Pond]].\n"), 0644))
+	destDir := t.TempDir()
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	pond, err := ioutil.ReadFile(filepath.Join(destDir, "Lily Pond.md"))
+	require.NoError(err)
+	require.Contains(string(pond), "aliases:\n- /pond/\n", "Aliases are from the root of the site")
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "aliases:\n- /plants/\n- /garden/\n", "A new slug is a new URL too")
+	ideas, err := ioutil.ReadFile(filepath.Join(destDir, "Ideas.md"))
+	require.NoError(err)
+	require.NotContains(string(ideas), "aliases", "Either empty note could have been renamed")
+
+	rename("Lily Pond.md", "Frog Pond.md")
+	destDir = t.TempDir()
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	pond, err = ioutil.ReadFile(filepath.Join(destDir, "Frog Pond.md"))
+	require.NoError(err)
+	require.Contains(string(pond), "aliases:\n- /pond/\n- /lily-pond/\n", "Every earlier URL is kept")
+	garden, err = ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "aliases:\n- /plants/\n- /garden/\n")
+}
@@ -0,0 +1,82 @@
+package backlinker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WarningKind identifies the kind of problem a Warning describes.
+type WarningKind string
+
+const (
+	// WarnDanglingLink is a link to a file that doesn't exist in the source directory.
+	WarnDanglingLink WarningKind = "dangling-link"
+	// WarnDanglingAnchor is a link to a heading that doesn't exist in the file linked to.
+	WarnDanglingAnchor WarningKind = "dangling-anchor"
+	// WarnAmbiguousLink is a link name that could refer to more than one file.
+	WarnAmbiguousLink WarningKind = "ambiguous-link"
+	// WarnBadDate is a date in frontmatter that couldn't be understood.
+	WarnBadDate WarningKind = "bad-date"
+	// WarnDuplicateKey is a frontmatter key that appears more than once.
+	WarnDuplicateKey WarningKind = "duplicate-key"
+	// WarnUnreadableFile is a file that was skipped because it couldn't be read.
+	WarnUnreadableFile WarningKind = "unreadable-file"
+	// WarnDraftLink is a link to a draft that is being left out of the output.
+	WarnDraftLink WarningKind = "draft-link"
+	// WarnEmptyLink is a link like [[]] that doesn't name a note.
+	WarnEmptyLink WarningKind = "empty-link"
+	// WarnBadConfig is an option that couldn't be used as given.
+	WarnBadConfig WarningKind = "bad-config"
+)
+
+// Warning is a problem found during processing that doesn't stop the run
+// (unless Options.Strict is set).
+type Warning struct {
+	Kind    WarningKind
+	File    string
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: [%s] %s", w.File, w.Kind, w.Message)
+}
+
+// Report collects everything noteworthy that happened during a run.
+type Report struct {
+	Warnings []Warning
+}
+
+// StrictError is returned in strict mode when a run produced any warnings.
+type StrictError struct {
+	Warnings []Warning
+}
+
+func (e *StrictError) Error() string {
+	lines := make([]string, 0, len(e.Warnings))
+	for _, warning := range e.Warnings {
+		lines = append(lines, "  "+warning.String())
+	}
+	return fmt.Sprintf("strict mode: %d warning(s)\n%s", len(e.Warnings), strings.Join(lines, "\n"))
+}
+
+// warnf logs a warning and records it in the report, if there is one.
+func (o *Options) warnf(kind WarningKind, file string, format string, args ...interface{}) {
+	warning := Warning{
+		Kind:    kind,
+		File:    file,
+		Message: fmt.Sprintf(format, args...),
+	}
+	o.logf("Warning: %s\n", warning)
+	if o.Report != nil {
+		o.Report.Warnings = append(o.Report.Warnings, warning)
+	}
+}
+
+// strictCheck is the final gate for strict mode: it turns any warnings collected
+// during the run into an error.
+func strictCheck(opts *Options) error {
+	if !opts.Strict || opts.Report == nil || len(opts.Report.Warnings) == 0 {
+		return nil
+	}
+	return &StrictError{Warnings: opts.Report.Warnings}
+}
@@ -0,0 +1,53 @@
+package backlinker
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictModeFailsOnDanglingLink(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"First.md":  "This points at [[Second]] and [[Nowhere]].\n",
+		"Second.md": "Nothing to see here.\n",
+	})
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{Strict: true})
+	require.Error(err)
+	var strictErr *StrictError
+	require.True(errors.As(err, &strictErr))
+	require.Equal(1, len(strictErr.Warnings))
+	require.Equal(WarnDanglingLink, strictErr.Warnings[0].Kind)
+	require.Equal("First.md", strictErr.Warnings[0].File)
+	require.Contains(err.Error(), "[[Nowhere]]")
+
+	written, err := ioutil.ReadDir(destDir)
+	require.NoError(err)
+	require.Equal(0, len(written), "Nothing should be written when strict mode fails")
+}
+
+func TestStrictModePassesOnCleanVault(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"First.md":  "This points at [[Second]].\n",
+		"Second.md": "---\ntitle: Second\n---\nAnd back to [[first]].\n",
+	})
+	report := Report{}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{Strict: true, Report: &report})
+	require.NoError(err)
+	require.Equal(0, len(report.Warnings))
+}
+
+func TestDuplicateFrontmatterKeysAreReported(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"First.md": "---\ntitle: One\ntitle: Two\n---\nBody\n",
+	})
+	report := Report{}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{Report: &report})
+	require.NoError(err, "Warnings are not errors outside of strict mode")
+	require.Equal(1, len(report.Warnings))
+	require.Equal(WarnDuplicateKey, report.Warnings[0].Kind)
+}
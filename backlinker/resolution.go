@@ -0,0 +1,82 @@
+package backlinker
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// resolutionIndexFile is the name of the cache file holding the resolution index.
+const resolutionIndexFile = "index.json"
+
+// linkNames are the names, besides its filename, that a note can be linked by.
+type linkNames struct {
+	Aliases []string `json:"aliases,omitempty"`
+	Title   string   `json:"title,omitempty"`
+}
+
+// resolutionIndex is the cached linkNames of each note, by filename, under
+// Options.ResolutionIndex. An entry is only used while the note's size and
+// modification time are unchanged.
+type resolutionIndex struct {
+	AliasKey string                     `json:"aliasKey"`
+	Files    map[string]resolutionEntry `json:"files"`
+}
+
+type resolutionEntry struct {
+	ModTime time.Time `json:"modTime"`
+	Size    int64     `json:"size"`
+	Names   linkNames `json:"names"`
+}
+
+// loadResolutionIndex reads the resolution index from the cache. An index made with a
+// different AliasKey is no use and is started afresh.
+func loadResolutionIndex(opts *Options) (*resolutionIndex, error) {
+	index := &resolutionIndex{}
+	err := opts.cache.read(resolutionIndexFile, index)
+	if err != nil {
+		return nil, err
+	}
+	if index.Files == nil || index.AliasKey != opts.AliasKey {
+		index.AliasKey = opts.AliasKey
+		index.Files = make(map[string]resolutionEntry)
+	}
+	return index, nil
+}
+
+// readLinkNames returns the names a note can be linked by, from the index when it has
+// an entry for the note as it is now, and otherwise from the note's frontmatter.
+func readLinkNames(filename string, file *markdownFile, filetext []byte, index *resolutionIndex,
+	opts *Options) linkNames {
+	var info os.FileInfo
+	if index != nil {
+		var err error
+		info, err = os.Stat(filename)
+		if err == nil {
+			entry, exists := index.Files[file.relativePath()]
+			if exists && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+				return entry.Names
+			}
+		}
+	}
+
+	var names linkNames
+	meta := probeFrontmatter(file, filetext)
+	if opts.AliasKey != "" {
+		switch value := meta[opts.AliasKey].(type) {
+		case string:
+			names.Aliases = append(names.Aliases, value)
+		case []interface{}:
+			for _, item := range value {
+				names.Aliases = append(names.Aliases, fmt.Sprint(item))
+			}
+		}
+	}
+	if title, ok := meta["title"].(string); ok {
+		names.Title = title
+	}
+	if index != nil && info != nil {
+		index.Files[file.relativePath()] = resolutionEntry{ModTime: info.ModTime(), Size: info.Size(), Names: names}
+	}
+	return names
+}
@@ -0,0 +1,68 @@
+package backlinker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolutionIndex(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Digital Gardens.md": "---\naliases: [Garden]\n---\nAbout gardens.\n",
+		"2020-05-01.md":      "---\ntitle: Diary\n---\nA diary.\n",
+		"Reader.md":          "Visiting the [[Garden]] and the [[Diary]].\n",
+	})
+	cacheDir := t.TempDir()
+	opts := Options{AliasKey: "aliases", LinkTitles: LinkTitlesFallback, ResolutionIndex: true, CacheDir: cacheDir}
+	readReader := func() string {
+		reader, err := ioutil.ReadFile(filepath.Join(destDir, "Reader.md"))
+		require.NoError(err)
+		return string(reader)
+	}
+	readIndex := func() resolutionIndex {
+		var index resolutionIndex
+		data, err := ioutil.ReadFile(filepath.Join(cacheDir, resolutionIndexFile))
+		require.NoError(err)
+		require.NoError(json.Unmarshal(data, &index))
+		return index
+	}
+
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	expected := "Visiting the [Garden](./digital-gardens/) and the [Diary](./2020-05-01/).\n"
+	require.Contains(readReader(), expected)
+	index := readIndex()
+	require.Equal([]string{"Garden"}, index.Files["Digital Gardens.md"].Names.Aliases)
+	require.Equal("Diary", index.Files["2020-05-01.md"].Names.Title)
+	require.Len(index.Files, 3)
+
+	// Resolving with the index gives the same result
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	require.Contains(readReader(), expected)
+
+	// The index is what's used while a file is unchanged...
+	entry := index.Files["Digital Gardens.md"]
+	entry.Names.Aliases = []string{"Diary"}
+	index.Files["Digital Gardens.md"] = entry
+	data, err := json.Marshal(index)
+	require.NoError(err)
+	require.NoError(ioutil.WriteFile(filepath.Join(cacheDir, resolutionIndexFile), data, 0644))
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	require.Contains(readReader(), "and the [Diary](./digital-gardens/).")
+
+	// ...and not once it has changed
+	later := time.Now().Add(time.Minute)
+	require.NoError(os.Chtimes(filepath.Join(sourceDir, "Digital Gardens.md"), later, later))
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	require.Contains(readReader(), expected)
+
+	// Deleted files leave the index
+	require.NoError(os.Remove(filepath.Join(sourceDir, "2020-05-01.md")))
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	require.NotContains(readIndex().Files, "2020-05-01.md")
+}
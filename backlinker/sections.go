@@ -0,0 +1,192 @@
+package backlinker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// bodyWithoutGeneratedSections returns the lines of the body as the author wrote them.
+// A file that is the output of an earlier run ends with generated sections, which must
+// not count towards word counts, summaries and the like.
+func bodyWithoutGeneratedSections(file *markdownFile, opts *Options) []string {
+	body := file.body
+	if start := generatedTail(body, opts); start >= 0 {
+		body = body[:start]
+	}
+	var lines []string
+	for _, line := range body {
+		if _, _, isMarker := parseMarker(line); !isMarker {
+			lines = append(lines, line)
+		}
+	}
+	return trimTrailingBlankLines(lines)
+}
+
+// generatedLine matches the lines of the unmarked sections earlier versions wrote, other
+// than their headings: blank lines, the notes listed, and the contexts under them.
+var generatedLine = regexp.MustCompile(`^(- \[[^\]]*\]\([^)]*\).*|    - .*|\s*)$`)
+
+// generatedTail returns where the unmarked generated sections at the end of the lines
+// start, or -1 if they don't end with any. A section heading only counts when everything
+// from it to the end is just as those sections were written, so that a section of the
+// author's own with the same heading is never mistaken for one.
+func generatedTail(lines []string, opts *Options) int {
+	for start, line := range lines {
+		if !isGeneratedSectionHeading(line, opts) {
+			continue
+		}
+		generated := true
+		for _, rest := range lines[start+1:] {
+			_, _, isMarker := parseMarker(rest)
+			if !isMarker && !isGeneratedSectionHeading(rest, opts) && !generatedLine.MatchString(rest) {
+				generated = false
+				break
+			}
+		}
+		if generated {
+			return start
+		}
+	}
+	return -1
+}
+
+func isGeneratedSectionHeading(line string, opts *Options) bool {
+	trimmed := strings.TrimSpace(line)
+	for _, heading := range opts.sectionHeadings() {
+		if trimmed == heading {
+			return true
+		}
+	}
+	return false
+}
+
+func trimTrailingBlankLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// generatedSection is a section generated for a note, written between markers named
+// after it so that a later run can find it again.
+type generatedSection struct {
+	Name    string
+	Content string
+}
+
+// sectionMarker matches the comments that open and close a generated section. Earlier
+// versions didn't put "sharedbrain:" in front of the name.
+var sectionMarker = regexp.MustCompile(`^<!-- (sharedbrain:)?([\p{Ll}\p{N}_-]+):(start|end) -->$`)
+
+func startMarker(name string) string {
+	return "<!-- sharedbrain:" + name + ":start -->"
+}
+
+func endMarker(name string) string {
+	return "<!-- sharedbrain:" + name + ":end -->"
+}
+
+// parseMarker returns the name of the section a marker line opens or closes. Markers
+// without "sharedbrain:" only count for the sections earlier versions wrote, so that
+// the markers of other tools are left alone.
+func parseMarker(line string) (name string, isStart bool, ok bool) {
+	match := sectionMarker.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return "", false, false
+	}
+	name = match[2]
+	if match[1] == "" && name != "backlinks" && name != "indirect-backlinks" && !strings.HasPrefix(name, "relation-") {
+		return "", false, false
+	}
+	return name, match[3] == "start", true
+}
+
+// collapseMarkedSections reduces each section an earlier run wrapped in markers to just
+// its start marker, which holds its place until the section is generated afresh. The
+// blank lines separating it from what comes before go too, since they're written with
+// the section. A start marker that is never closed runs to the end of the file.
+func collapseMarkedSections(lines []string) []string {
+	var kept []string
+	inSection := ""
+	for _, line := range lines {
+		name, isStart, isMarker := parseMarker(line)
+		if inSection != "" {
+			if isMarker && !isStart && name == inSection {
+				inSection = ""
+			}
+			continue
+		}
+		if isMarker && isStart {
+			inSection = name
+			kept = append(trimTrailingBlankLines(kept), startMarker(name))
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return kept
+}
+
+// dropUnmarkedSections removes the generated sections from a note rewritten in place
+// before sections had markers, from their first heading to the end of the note, so that
+// they're replaced rather than repeated. Only sections just as they were generated go
+// (see generatedTail); notes with markers, and notes that aren't rewritten in place, are
+// left as they are.
+func dropUnmarkedSections(lines []string, opts *Options) []string {
+	if !opts.inPlace {
+		return lines
+	}
+	for _, line := range lines {
+		if _, _, isMarker := parseMarker(line); isMarker {
+			return lines
+		}
+	}
+	if start := generatedTail(lines, opts); start >= 0 {
+		return trimTrailingBlankLines(lines[:start])
+	}
+	return lines
+}
+
+// stripMarkedText is collapseMarkedSections (and dropUnmarkedSections) for the whole
+// text of a file.
+func stripMarkedText(filetext []byte, opts *Options) []byte {
+	text := string(filetext)
+	if !strings.Contains(text, ":start -->") && !strings.Contains(text, "## ") {
+		return filetext
+	}
+	lines := dropUnmarkedSections(collapseMarkedSections(strings.Split(text, "\n")), opts)
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// fillMarkedSections writes each section into the body in place of its start marker,
+// or at the end of the body when there isn't one. Sections with no content aren't
+// written at all, and neither are the markers of sections that weren't generated.
+func fillMarkedSections(body string, sections []generatedSection) string {
+	content := make(map[string]string)
+	for _, section := range sections {
+		content[section.Name] = section.Content
+	}
+	var filled strings.Builder
+	written := make(map[string]bool)
+	writeSection := func(name string) {
+		written[name] = true
+		if content[name] != "" {
+			fmt.Fprintf(&filled, "\n%s\n%s\n%s\n", startMarker(name), content[name], endMarker(name))
+		}
+	}
+	for _, line := range strings.SplitAfter(body, "\n") {
+		if name, isStart, isMarker := parseMarker(line); isMarker && isStart {
+			if !written[name] {
+				writeSection(name)
+			}
+			continue
+		}
+		filled.WriteString(line)
+	}
+	for _, section := range sections {
+		if !written[section.Name] {
+			writeSection(section.Name)
+		}
+	}
+	return filled.String()
+}
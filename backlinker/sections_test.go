@@ -0,0 +1,251 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const previouslyGenerated = `---
+title: Gardens
+---
+Five words about [[digital]] gardens.
+
+## Backlinks
+
+- [Other Note](./other-note/)
+    - Lots and lots of words about [[Gardens]] that aren't really here.
+
+## Indirect Backlinks
+
+- [Third](./third/) via [Other Note](./other-note/)
+`
+
+func TestBodyWithoutGeneratedSections(t *testing.T) {
+	require := require.New(t)
+	file := loadFile(t, "Gardens.md", previouslyGenerated)
+	require.Equal([]string{"Five words about [[digital]] gardens."}, bodyWithoutGeneratedSections(file, &Options{}))
+
+	file = loadFile(t, "Plain.md", "Just a note.\n\nWith two paragraphs.\n")
+	require.Equal([]string{"Just a note.", "", "With two paragraphs."}, bodyWithoutGeneratedSections(file, &Options{}))
+}
+
+func TestCountsExcludeGeneratedSections(t *testing.T) {
+	require := require.New(t)
+	file := loadFile(t, "Gardens.md", previouslyGenerated)
+	require.Equal(5, countWords(file, &Options{}))
+	require.Equal("Five words about digital gardens.", extractSummary(file, &Options{}))
+
+	file = loadFile(t, "Gardens.md", "## Backlinks\n\n- [Other](./other/)\n    - context\n")
+	require.Equal(0, countWords(file, &Options{}))
+	require.Equal("", extractSummary(file, &Options{}))
+}
+
+func TestCollapseMarkedSections(t *testing.T) {
+	require := require.New(t)
+	lines := []string{
+		"Body.",
+		"",
+		"<!-- backlinks:start -->",
+		"",
+		"## Backlinks",
+		"",
+		"- [Other](./other/)",
+		"<!-- backlinks:end -->",
+		"After.",
+	}
+	require.Equal([]string{"Body.", "<!-- sharedbrain:backlinks:start -->", "After."}, collapseMarkedSections(lines))
+	require.Equal([]string{"Body.", "<!-- sharedbrain:backlinks:start -->"}, collapseMarkedSections(lines[:6]), "An unclosed section runs to the end")
+	require.Equal([]string{"Body.", "", "<!-- backlinks:end -->"}, collapseMarkedSections([]string{"Body.", "", "<!-- backlinks:end -->"}))
+}
+
+func TestFillMarkedSections(t *testing.T) {
+	require := require.New(t)
+	sections := []generatedSection{
+		{Name: "backlinks", Content: "\n## Backlinks\n\n- [Other](./other/)\n"},
+		{Name: "indirect-backlinks", Content: ""},
+		{Name: "related", Content: "\n## Related\n\n- [Third](./third/)\n"},
+	}
+	body := "Body.\n<!-- sharedbrain:backlinks:start -->\n\nAfter.\n<!-- sharedbrain:unknown:start -->\n<!-- sharedbrain:backlinks:start -->\n"
+	require.Equal(`Body.
+
+<!-- sharedbrain:backlinks:start -->
+
+## Backlinks
+
+- [Other](./other/)
+
+<!-- sharedbrain:backlinks:end -->
+
+After.
+
+<!-- sharedbrain:related:start -->
+
+## Related
+
+- [Third](./third/)
+
+<!-- sharedbrain:related:end -->
+`, fillMarkedSections(body, sections))
+	require.Equal("Body.\n\nAfter.\n", fillMarkedSections(body, nil))
+}
+
+func TestRerunOverOwnOutput(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"First.md":  "Links to [[Second]].\n",
+		"Second.md": "Just a note.\n",
+		"Third.md":  "Nobody links here.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{}))
+	firstRun, err := ioutil.ReadFile(filepath.Join(destDir, "Second.md"))
+	require.NoError(err)
+	require.Equal(`---
+title: Second
+---
+Just a note.
+
+<!-- sharedbrain:backlinks:start -->
+
+## Backlinks
+
+- [First](./first/)
+    - Links to [Second](./second/).
+
+<!-- sharedbrain:backlinks:end -->
+`, string(firstRun))
+
+	// Running again over Second's output (and a section with links of its own, which
+	// mustn't be counted) gives the same page, not a second set of backlinks
+	withLinks := strings.Replace(string(firstRun), "## Backlinks", "## Backlinks\n\nSee [[Third]].", 1)
+	require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "Second.md"), []byte(withLinks), 0644))
+	for i := 0; i < 2; i++ {
+		require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{}))
+		secondRun, err := ioutil.ReadFile(filepath.Join(destDir, "Second.md"))
+		require.NoError(err)
+		require.Equal(string(firstRun), string(secondRun))
+	}
+	third, err := ioutil.ReadFile(filepath.Join(destDir, "Third.md"))
+	require.NoError(err)
+	require.NotContains(string(third), "Backlinks")
+}
+
+func TestRerunUpdatesSectionsInPlace(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"First.md": "Links to [[Second]].\n",
+		"Second.md": `Just a note.
+
+<!-- backlinks:start -->
+
+## Backlinks
+
+- [Gone](./gone/)
+    - A note that doesn't link here any more.
+
+<!-- backlinks:end -->
+
+Written by hand after the backlinks.
+`,
+	})
+	for i := 0; i < 2; i++ {
+		require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{}))
+		second, err := ioutil.ReadFile(filepath.Join(destDir, "Second.md"))
+		require.NoError(err)
+		require.Equal(`---
+title: Second
+---
+Just a note.
+
+<!-- sharedbrain:backlinks:start -->
+
+## Backlinks
+
+- [First](./first/)
+    - Links to [Second](./second/).
+
+<!-- sharedbrain:backlinks:end -->
+
+Written by hand after the backlinks.
+`, string(second))
+		require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "Second.md"), second, 0644))
+	}
+
+	// A change to the graph updates the section where it is
+	require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "Third.md"), []byte("Also [[Second]].\n"), 0644))
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{}))
+	second, err := ioutil.ReadFile(filepath.Join(destDir, "Second.md"))
+	require.NoError(err)
+	require.Equal(`---
+title: Second
+---
+Just a note.
+
+<!-- sharedbrain:backlinks:start -->
+
+## Backlinks
+
+- [First](./first/)
+    - Links to [Second](./second/).
+- [Third](./third/)
+    - Also [Second](./second/).
+
+<!-- sharedbrain:backlinks:end -->
+
+Written by hand after the backlinks.
+`, string(second))
+}
+
+func TestRerunOverUnmarkedOutput(t *testing.T) {
+	require := require.New(t)
+	sourceDir, _ := writeVault(t, map[string]string{
+		"First.md":  "Links to [[Second]].\n",
+		"Second.md": "Just a note.\n\n<!-- toc:start -->\n- Kept\n<!-- toc:end -->\n\n## Backlinks\n\n- [Gone](./gone/)\n    - Old.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, sourceDir, Options{SkipBackups: true}))
+	second, err := ioutil.ReadFile(filepath.Join(sourceDir, "Second.md"))
+	require.NoError(err)
+	require.Equal(`---
+title: Second
+---
+Just a note.
+
+<!-- toc:start -->
+- Kept
+<!-- toc:end -->
+
+<!-- sharedbrain:backlinks:start -->
+
+## Backlinks
+
+- [First](./first/)
+    - Links to [Second](./second/).
+
+<!-- sharedbrain:backlinks:end -->
+`, string(second))
+	require.Equal(1, strings.Count(string(second), "## Backlinks"))
+}
+
+func TestOwnBacklinksHeadingKept(t *testing.T) {
+	require := require.New(t)
+	const own = "Just a note.\n\n## Backlinks\n\nWhy backlinks matter, in my own words.\n\n- [Gone](./gone/)\n    - Old.\n"
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"First.md":  "Links to [[Second]].\n",
+		"Second.md": own,
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{}))
+	second, err := ioutil.ReadFile(filepath.Join(destDir, "Second.md"))
+	require.NoError(err)
+	require.Contains(string(second), own)
+	require.Contains(string(second), "<!-- sharedbrain:backlinks:start -->\n\n## Backlinks\n\n- [First](./first/)\n")
+
+	// Nor is it mistaken for generated output when the note is rewritten in place
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, sourceDir, Options{SkipBackups: true}))
+	second, err = ioutil.ReadFile(filepath.Join(sourceDir, "Second.md"))
+	require.NoError(err)
+	require.Contains(string(second), own)
+	require.Equal(1, strings.Count(string(second), "- [First](./first/)"))
+}
@@ -0,0 +1,83 @@
+package backlinker
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+)
+
+// noteSidecar is the JSON form of a fully processed note.
+type noteSidecar struct {
+	Title     string            `json:"title"`
+	Date      string            `json:"date,omitempty"`
+	Tags      []string          `json:"tags"`
+	URL       string            `json:"url"`
+	Links     []sidecarLink     `json:"links"`
+	Backlinks []sidecarBacklink `json:"backlinks"`
+	Body      string            `json:"body"`
+}
+
+type sidecarLink struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+type sidecarBacklink struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Context string `json:"context"`
+}
+
+// metadataStrings returns a frontmatter value as a list of strings, whether it was
+// given as a single value or as a list.
+func metadataStrings(file *markdownFile, key string) []string {
+	result := []string{}
+	switch value := file.metadata[key].(type) {
+	case nil:
+	case []interface{}:
+		for _, item := range value {
+			result = append(result, fmt.Sprint(item))
+		}
+	default:
+		result = append(result, fmt.Sprint(value))
+	}
+	return result
+}
+
+// buildSidecar gathers everything known about the file into its sidecar form.
+func buildSidecar(file *markdownFile, opts *Options) noteSidecar {
+	sidecar := noteSidecar{
+		Title:     file.Title,
+		Tags:      metadataStrings(file, "tags"),
+		URL:       opts.linkTo(file),
+		Links:     []sidecarLink{},
+		Backlinks: []sidecarBacklink{},
+		Body:      file.convertedBody,
+	}
+	if date, ok := opts.metadataDate(file); ok {
+		sidecar.Date = opts.formatDate(date)
+	} else if date, ok := file.metadata["date"].(string); ok {
+		sidecar.Date = date
+	}
+	for _, other := range file.forwardLinks {
+		sidecar.Links = append(sidecar.Links, sidecarLink{Title: other.Title, URL: opts.linkTo(other)})
+	}
+	for _, bl := range dedupeBacklinks(file.BackLinks) {
+		sidecar.Backlinks = append(sidecar.Backlinks, sidecarBacklink{
+			Title:   bl.OtherFile.Title,
+			URL:     opts.linkTo(bl.OtherFile),
+			Context: bl.Context,
+		})
+	}
+	return sidecar
+}
+
+// writeSidecar writes the file's sidecar, named like the markdown file with a .json
+// extension, into dir.
+func writeSidecar(dir string, file *markdownFile, opts *Options) error {
+	data, err := json.MarshalIndent(buildSidecar(file, opts), "", "  ")
+	if err != nil {
+		return err
+	}
+	return opts.writeOutput(path.Join(dir, removeExtension(file.OriginalName)+".json"), append(data, '\n'))
+}
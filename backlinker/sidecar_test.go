@@ -0,0 +1,59 @@
+package backlinker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSidecars(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"2020-05-01.md":      "---\ntags: [garden, go]\n---\nToday I tended [[Digital Gardens]] and [[Weeds]].\n",
+		"Digital Gardens.md": "About gardens.\n",
+	})
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{JSONSidecars: SidecarsAlongside})
+	require.NoError(err)
+	require.FileExists(filepath.Join(destDir, "2020-05-01.md"))
+
+	data, err := ioutil.ReadFile(filepath.Join(destDir, "2020-05-01.json"))
+	require.NoError(err)
+	var sidecar noteSidecar
+	require.NoError(json.Unmarshal(data, &sidecar))
+	require.Equal(noteSidecar{
+		Title: "2020-05-01",
+		Date:  "2020-05-01T08:00:00-05:00",
+		Tags:  []string{"garden", "go"},
+		URL:   "./2020-05-01/",
+		Links: []sidecarLink{
+			{Title: "Digital Gardens", URL: "./digital-gardens/"},
+			{Title: "Weeds", URL: "./weeds/"},
+		},
+		Backlinks: []sidecarBacklink{},
+		Body:      "Today I tended [Digital Gardens](./digital-gardens/) and [Weeds](./weeds/).\n",
+	}, sidecar)
+
+	data, err = ioutil.ReadFile(filepath.Join(destDir, "Digital Gardens.json"))
+	require.NoError(err)
+	require.NoError(json.Unmarshal(data, &sidecar))
+	require.Equal([]sidecarBacklink{{
+		Title:   "2020-05-01",
+		URL:     "./2020-05-01/",
+		Context: "Today I tended [[Digital Gardens]] and [[Weeds]].",
+	}}, sidecar.Backlinks)
+	require.Equal("2020-05-01T08:00:00-05:00", sidecar.Date, "Dates are inherited from backlinks")
+}
+
+func TestJSONSidecarsOnly(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"First.md": "Hello.\n",
+	})
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{JSONSidecars: SidecarsOnly})
+	require.NoError(err)
+	require.FileExists(filepath.Join(destDir, "First.json"))
+	require.NoFileExists(filepath.Join(destDir, "First.md"))
+}
@@ -0,0 +1,95 @@
+package backlinker
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// compareTitles orders two titles alphabetically. Without a configured collation
+// this is a plain byte comparison.
+func (o *Options) compareTitles(a string, b string) int {
+	if o.Collation == "" {
+		return strings.Compare(a, b)
+	}
+	if o.collator == nil {
+		tag, err := language.Parse(o.Collation)
+		if err != nil {
+			o.warnf(WarnBadConfig, "", "unknown collation %q: %v", o.Collation, err)
+			o.Collation = ""
+			return strings.Compare(a, b)
+		}
+		o.collator = collate.New(tag)
+	}
+	return o.collator.CompareString(a, b)
+}
+
+// BacklinkSort chooses the order the backlinks of a note are listed in.
+type BacklinkSort string
+
+const (
+	// SortByDate lists the notes with dates first, newest first, and then the rest.
+	SortByDate BacklinkSort = ""
+	// SortByTitle lists the notes alphabetically, by title.
+	SortByTitle BacklinkSort = "title"
+	// SortByBacklinks lists the notes with the most backlinks of their own first.
+	SortByBacklinks BacklinkSort = "backlinks"
+	// SortByFolder lists the notes by the directory they're in.
+	SortByFolder BacklinkSort = "folder"
+)
+
+// backlinkSort is the order the file's backlinks are listed in, and whether it's
+// reversed: the ones its frontmatter gives as backlinks_sort and backlinks_sort_reverse,
+// or else Options.BacklinkSort and Options.ReverseBacklinkSort.
+func (o *Options) backlinkSort(file *markdownFile) (BacklinkSort, bool) {
+	order, reverse := o.BacklinkSort, o.ReverseBacklinkSort
+	if value, ok := file.metadata["backlinks_sort"].(string); ok {
+		order = BacklinkSort(value)
+	}
+	if value, ok := file.metadata["backlinks_sort_reverse"].(bool); ok {
+		reverse = value
+	}
+	return order, reverse
+}
+
+// sortBacklinks orders the backlinks of the file as backlinkSort says, and those that
+// are equal by title. By date, the undated notes always come after those with dates.
+func sortBacklinks(file *markdownFile, backlinks []backlink, opts *Options) {
+	order, reverse := opts.backlinkSort(file)
+	sort.SliceStable(backlinks, func(i, j int) bool {
+		file1 := backlinks[i].OtherFile
+		file2 := backlinks[j].OtherFile
+		var comparison int
+		switch order {
+		case SortByTitle:
+		case SortByBacklinks:
+			comparison = len(file2.BackLinks) - len(file1.BackLinks)
+		case SortByFolder:
+			comparison = opts.compareTitles(file1.dir, file2.dir)
+		default:
+			date1, hasDate1 := opts.metadataDate(file1)
+			date2, hasDate2 := opts.metadataDate(file2)
+			if hasDate1 != hasDate2 {
+				return hasDate1
+			}
+			if hasDate1 && !date1.Equal(date2) {
+				comparison = 1
+				if date1.After(date2) {
+					comparison = -1
+				}
+			}
+		}
+		if comparison == 0 {
+			comparison = opts.compareTitles(file1.Title, file2.Title)
+			if order != SortByTitle {
+				return comparison < 0
+			}
+		}
+		if reverse {
+			return comparison > 0
+		}
+		return comparison < 0
+	})
+}
@@ -0,0 +1,77 @@
+package backlinker
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareTitlesDefaultsToByteOrder(t *testing.T) {
+	require := require.New(t)
+	titles := []string{"zèbre", "écureuil", "abeille"}
+	opts := Options{}
+	sort.Slice(titles, func(i, j int) bool { return opts.compareTitles(titles[i], titles[j]) < 0 })
+	require.Equal([]string{"abeille", "zèbre", "écureuil"}, titles)
+}
+
+func TestCompareTitlesWithCollation(t *testing.T) {
+	require := require.New(t)
+	titles := []string{"zèbre", "écureuil", "Éléphant", "abeille"}
+	opts := Options{Collation: "fr"}
+	sort.Slice(titles, func(i, j int) bool { return opts.compareTitles(titles[i], titles[j]) < 0 })
+	require.Equal([]string{"abeille", "écureuil", "Éléphant", "zèbre"}, titles)
+}
+
+func TestBacklinksSortedWithCollation(t *testing.T) {
+	require := require.New(t)
+	fileMap := map[string]*markdownFile{
+		"target.md": createMarkdownFile("Target.md", false),
+		"zèbre.md":  createMarkdownFile("Zèbre.md", false),
+		"étoile.md": createMarkdownFile("Étoile.md", false),
+	}
+	target := fileMap["target.md"]
+	for _, name := range []string{"zèbre.md", "étoile.md"} {
+		target.BackLinks = append(target.BackLinks, backlink{OtherFile: fileMap[name], Context: "[[Target]]"})
+	}
+	writer := bytes.Buffer{}
+	err := addBacklinks(target, fileMap, &Options{Collation: "fr"}, &writer)
+	require.Nil(err)
+	require.Regexp(`(?s)Étoile.*Zèbre`, writer.String())
+}
+
+func TestBacklinkSort(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md":     "Plants.\n",
+		"Topic.md":      "---\nbacklinks_sort: title\nbacklinks_sort_reverse: true\n---\nGardening.\n",
+		"2024-01-01.md": "Planned the [[Garden]] and the [[Topic]].\n",
+		"2024-06-01.md": "Weeded the [[Garden]] and the [[Topic]].\n",
+		"Apples.md":     "Grown in the [[Garden]] for the [[Topic]].\n",
+		"Pond.md":       "By the [[Garden]], on the [[Topic]].\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{ReverseBacklinkSort: true}))
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Regexp(`(?s)2024-01-01.*2024-06-01.*Apples.*Pond`, string(garden), "Oldest first, and the undated notes still last")
+	topic, err := ioutil.ReadFile(filepath.Join(destDir, "Topic.md"))
+	require.NoError(err)
+	require.Regexp(`(?s)Pond.*Apples.*2024-06-01.*2024-01-01`, string(topic), "The note's own order")
+}
+
+func TestBacklinkSortByCount(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "Plants.\n",
+		"Apples.md": "Grown in the [[Garden]].\n",
+		"Pond.md":   "By the [[Garden]].\n",
+		"Frogs.md":  "In the [[Pond]].\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{BacklinkSort: SortByBacklinks}))
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Regexp(`(?s)Pond.*Apples`, string(garden))
+}
@@ -0,0 +1,39 @@
+package backlinker
+
+// Stats counts what a processed set of files is made of.
+type Stats struct {
+	// Notes is the number of notes read from the source directory, date notes included.
+	Notes int
+	// DateNotes is the number of notes named for a date.
+	DateNotes int
+	// Stubs is the number of pages made only because something links to them.
+	Stubs int
+	// Links is the number of links from one page to another.
+	Links int
+	// Orphans is the number of notes that nothing links to.
+	Orphans int
+}
+
+// CollectStats counts the notes, stubs and links in a processed set of files. Drafts
+// that are being left out and generated pages aren't counted.
+func CollectStats(files FileMap) Stats {
+	var stats Stats
+	for _, file := range includedFiles(files) {
+		if file.generated {
+			continue
+		}
+		stats.Links += len(file.BackLinks)
+		if file.IsNew {
+			stats.Stubs++
+			continue
+		}
+		stats.Notes++
+		if file.IsDateFile {
+			stats.DateNotes++
+		}
+		if len(file.BackLinks) == 0 {
+			stats.Orphans++
+		}
+	}
+	return stats
+}
@@ -0,0 +1,19 @@
+package backlinker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectStats(t *testing.T) {
+	require := require.New(t)
+	sourceDir, _ := writeVault(t, map[string]string{
+		"2024-02-01.md": "Worked on [[Garden]] and [[Shed]].\n",
+		"Garden.md":     "Near the [[Shed]].\n",
+		"Lonely.md":     "Nobody links here.\n",
+	})
+	files, err := LoadFiles(sourceDir, Options{TypeIndexPage: "by-type.md"})
+	require.NoError(err)
+	require.Equal(Stats{Notes: 3, DateNotes: 1, Stubs: 1, Links: 3, Orphans: 2}, CollectStats(files))
+}
@@ -0,0 +1,137 @@
+package backlinker
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// StubPolicy chooses whether pages are made for the notes that are linked to but don't
+// exist.
+type StubPolicy string
+
+const (
+	// StubsCreate makes a stub for each of them: a page listing its backlinks.
+	StubsCreate StubPolicy = ""
+	// StubsPlain makes no pages for them, and turns the links to them into plain text.
+	StubsPlain StubPolicy = "plain"
+	// StubsSpan is StubsPlain with the text in a span of Options.StubClass, for themes to
+	// style. Hugo only keeps the span with its goldmark renderer's unsafe option set.
+	StubsSpan StubPolicy = "span"
+)
+
+func (o *Options) stubClass() string {
+	if o.StubClass == "" {
+		return "missing-note"
+	}
+	return o.StubClass
+}
+
+// skipStubs marks the stubs as unwritten when Options.Stubs says they aren't made, or
+// they have fewer links than Options.StubMinBacklinks. They stay in the map, so that the
+// links to them are still counted and reported as dangling.
+func skipStubs(fileMap map[string]*markdownFile, opts *Options) {
+	if opts.Stubs == StubsCreate && opts.StubMinBacklinks <= 0 {
+		return
+	}
+	for _, file := range fileMap {
+		if file.IsNew && (opts.Stubs != StubsCreate || len(file.BackLinks) < opts.StubMinBacklinks) {
+			file.unwritten = true
+		}
+	}
+}
+
+// danglingLink is what the link s, to a note that doesn't exist and has no stub, becomes:
+// its label, as plain text or in a span. Notes rewritten in place keep the link, so
+// that it works once the note is written.
+func (o *Options) danglingLink(s string, linkText string) string {
+	if o.inPlace {
+		return s
+	}
+	if o.Stubs == StubsSpan {
+		return fmt.Sprintf(`<span class="%s">%s</span>`, o.stubClass(), linkLabel(linkText))
+	}
+	return linkLabel(linkText)
+}
+
+// stubTemplateData is what Options.StubTemplate is given to render a stub, and
+// Options.StubBody to render its body (without the Sections).
+type stubTemplateData struct {
+	// Title is the title the stub is given, from the links to it.
+	Title string
+	// Date is the stub's date (RFC 3339), taken from its backlinks, or "" without one.
+	Date string
+	// Backlinks are the notes linking to the stub, in the order they're usually listed.
+	Backlinks []templateBacklink
+	// Sections are the generated sections, with their markers, as they'd be written
+	// after the body of any other note.
+	Sections string
+}
+
+// stubTemplate parses Options.StubTemplate, returning nil when there isn't one.
+func (o *Options) stubTemplate() (*template.Template, error) {
+	if o.StubTemplate == "" {
+		return nil, nil
+	}
+	return template.New("stub").Parse(o.StubTemplate)
+}
+
+// stubBodyTemplate parses Options.StubBody, returning nil when there isn't one.
+func (o *Options) stubBodyTemplate() (*template.Template, error) {
+	if o.StubBody == "" {
+		return nil, nil
+	}
+	return template.New("stub body").Parse(o.StubBody)
+}
+
+// renderStub replaces the content of a stub with the stub template's rendering of it.
+func renderStub(tmpl *template.Template, file *markdownFile, fileMap map[string]*markdownFile,
+	sections []generatedSection, opts *Options) error {
+	var rendered bytes.Buffer
+	err := tmpl.Execute(&rendered, stubData(file, fileMap, sections, opts))
+	if err != nil {
+		return err
+	}
+	file.newData = &rendered
+	return nil
+}
+
+// renderStubBody makes the stub body template's rendering of a stub its body, which the
+// generated sections follow as they do any other.
+func renderStubBody(tmpl *template.Template, file *markdownFile, fileMap map[string]*markdownFile,
+	opts *Options) error {
+	var rendered bytes.Buffer
+	err := tmpl.Execute(&rendered, stubData(file, fileMap, nil, opts))
+	if err != nil {
+		return err
+	}
+	file.convertedBody = rendered.String()
+	if file.convertedBody != "" && !strings.HasSuffix(file.convertedBody, "\n") {
+		file.convertedBody += "\n"
+	}
+	return nil
+}
+
+// stubData is what the stub templates are given for a stub.
+func stubData(file *markdownFile, fileMap map[string]*markdownFile, sections []generatedSection,
+	opts *Options) stubTemplateData {
+	data := stubTemplateData{
+		Title:     file.Title,
+		Backlinks: []templateBacklink{},
+		Sections:  fillMarkedSections("", sections),
+	}
+	if date, ok := opts.metadataDate(file); ok {
+		data.Date = opts.formatDate(date)
+	}
+	sortBacklinks(file, file.BackLinks, opts)
+	for _, bl := range dedupeBacklinks(file.BackLinks) {
+		data.Backlinks = append(data.Backlinks, templateBacklink{
+			Title:   bl.OtherFile.Title,
+			URL:     opts.linkTo(bl.OtherFile),
+			Label:   bl.Label,
+			Context: convertLinksOnLine(bl.Context, fileMap, opts),
+		})
+	}
+	return data
+}
@@ -0,0 +1,161 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStubTemplate(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"2020-05-01.md": "Started a [[Compost Heap]].\n",
+		"Garden.md":     "The [[Compost Heap]] is by the [[Shed]].\n",
+	})
+	opts := Options{StubTemplate: `---
+title: {{ printf "%q" .Title }}
+{{- if .Date }}
+date: {{ .Date }}
+{{- end }}
+stub: true
+---
+_Nothing has been written about {{ .Title }} yet._
+
+Mentioned in:
+{{ range .Backlinks }}
+* [{{ .Title }}]({{ .URL }}): {{ .Context }}
+{{- end }}
+{{ .Sections -}}
+`}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+	require.NoError(err)
+
+	heap, err := ioutil.ReadFile(filepath.Join(destDir, "Compost Heap.md"))
+	require.NoError(err)
+	require.Equal(`---
+title: "Compost Heap"
+date: 2020-05-01T08:00:00-05:00
+stub: true
+---
+_Nothing has been written about Compost Heap yet._
+
+Mentioned in:
+
+* [2020-05-01](./2020-05-01/): Started a [Compost Heap](./compost-heap/).
+* [Garden](./garden/): The [Compost Heap](./compost-heap/) is by the [Shed](./shed/).
+
+<!-- sharedbrain:backlinks:start -->
+
+## Backlinks
+
+- [2020-05-01](./2020-05-01/)
+    - Started a [Compost Heap](./compost-heap/).
+- [Garden](./garden/)
+    - The [Compost Heap](./compost-heap/) is by the [Shed](./shed/).
+
+<!-- sharedbrain:backlinks:end -->
+`, string(heap))
+
+	shed, err := ioutil.ReadFile(filepath.Join(destDir, "Shed.md"))
+	require.NoError(err)
+	require.Contains(string(shed), "title: \"Shed\"\nstub: true\n")
+
+	// Real notes are written as usual
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Equal("---\ntitle: Garden\n---\nThe [Compost Heap](./compost-heap/) is by the [Shed](./shed/).\n", string(garden))
+}
+
+func TestStubTemplateErrors(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{"Garden.md": "A [[Shed]].\n"})
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{StubTemplate: "{{ .Title "})
+	require.Error(err)
+	err = ProcessBackLinksWithOptions(sourceDir, destDir, Options{StubTemplate: "{{ .Missing }}"})
+	require.Error(err)
+}
+
+func TestStubBody(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "The [[Compost Heap]] is by the shed.\n",
+	})
+	opts := Options{StubBody: "_{{ .Title }} hasn't been written yet._"}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+	require.NoError(err)
+
+	heap, err := ioutil.ReadFile(filepath.Join(destDir, "Compost Heap.md"))
+	require.NoError(err)
+	require.Equal(`---
+title: Compost Heap
+---
+_Compost Heap hasn't been written yet._
+
+<!-- sharedbrain:backlinks:start -->
+
+## Backlinks
+
+- [Garden](./garden/)
+    - The [Compost Heap](./compost-heap/) is by the shed.
+
+<!-- sharedbrain:backlinks:end -->
+`, string(heap))
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.NotContains(string(garden), "hasn't been written yet", "Only stubs get the body")
+
+	_, err = LoadFiles(sourceDir, Options{StubBody: "{{ .Title"})
+	require.Error(err)
+}
+
+func TestStubsNotCreated(t *testing.T) {
+	require := require.New(t)
+	files := map[string]string{
+		"Garden.md": "The [[Compost Heap]] is by the [[Shed|old shed]] and the [[Pond]].\n",
+		"Pond.md":   "Frogs.\n",
+	}
+	expected := map[StubPolicy]string{
+		StubsPlain: "The Compost Heap is by the old shed and the [Pond](./pond/).\n",
+		StubsSpan: `The <span class="missing-note">Compost Heap</span> is by the ` +
+			`<span class="missing-note">old shed</span> and the [Pond](./pond/).` + "\n",
+	}
+	for policy, text := range expected {
+		sourceDir, destDir := writeVault(t, files)
+		report := &Report{}
+		err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{Stubs: policy, Report: report})
+		require.NoError(err)
+
+		garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+		require.NoError(err)
+		require.Contains(string(garden), text, policy)
+		require.NoFileExists(filepath.Join(destDir, "Compost Heap.md"), policy)
+		require.NoFileExists(filepath.Join(destDir, "Shed.md"), policy)
+		require.Len(report.Warnings, 2, "Dangling links are still reported")
+	}
+}
+
+func TestStubMinBacklinks(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "The [[Compost Heap]] is by the [[Shed]].\n",
+		"Pond.md":   "Past the [[Shed]].\n",
+	})
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{StubMinBacklinks: 2})
+	require.NoError(err)
+
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "The Compost Heap is by the [Shed](./shed/).\n")
+	require.FileExists(filepath.Join(destDir, "Shed.md"))
+	require.NoFileExists(filepath.Join(destDir, "Compost Heap.md"))
+
+	spans := t.TempDir()
+	err = ProcessBackLinksWithOptions(sourceDir, spans, Options{StubMinBacklinks: 2, Stubs: StubsSpan})
+	require.NoError(err)
+	garden, err = ioutil.ReadFile(filepath.Join(spans, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), `<span class="missing-note">Compost Heap</span>`)
+	require.NoFileExists(filepath.Join(spans, "Shed.md"), "The policy still makes no stubs at all")
+}
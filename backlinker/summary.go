@@ -0,0 +1,61 @@
+package backlinker
+
+import (
+	"regexp"
+	"strings"
+)
+
+// moreMarker is the delimiter Hugo uses to mark the end of a page's summary.
+const moreMarker = "<!--more-->"
+
+// extractSummary returns the summary of a file: everything before the <!--more-->
+// marker when there is one, or else the first paragraph of text. Headings are skipped,
+// wikilinks are reduced to their text and the lines are joined into a single line.
+func extractSummary(file *markdownFile, opts *Options) string {
+	lines := bodyWithoutGeneratedSections(file, opts)
+	var summary []string
+	foundMarker := false
+	for _, line := range lines {
+		if index := strings.Index(line, moreMarker); index >= 0 {
+			summary = append(summary, line[:index])
+			foundMarker = true
+			break
+		}
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			summary = append(summary, line)
+		}
+	}
+	if !foundMarker {
+		summary = firstParagraph(lines)
+	}
+	return flattenText(summary)
+}
+
+// firstParagraph returns the first run of non-blank lines that aren't headings.
+func firstParagraph(lines []string) []string {
+	var paragraph []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			if len(paragraph) > 0 {
+				break
+			}
+			continue
+		}
+		paragraph = append(paragraph, trimmed)
+	}
+	return paragraph
+}
+
+// flattenText joins lines into a single line of text with wikilinks replaced by their text.
+func flattenText(lines []string) string {
+	return unwrapWikilinks(strings.Join(strings.Fields(strings.Join(lines, " ")), " "))
+}
+
+// unwrapWikilinks replaces each wikilink in text with the text shown for the link.
+func unwrapWikilinks(text string) string {
+	re := regexp.MustCompile(`\[\[([^\]]+)\]\]`)
+	return re.ReplaceAllStringFunc(text, func(s string) string {
+		return linkLabel(s[2 : len(s)-2])
+	})
+}
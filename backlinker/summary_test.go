@@ -0,0 +1,62 @@
+package backlinker
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// loadFile runs a file's text through frontmatter extraction as generateFileData would.
+func loadFile(t *testing.T, name string, text string) *markdownFile {
+	file := createMarkdownFile(name, false)
+	file.scanner = bufio.NewScanner(strings.NewReader(text))
+	require.NoError(t, extractFrontmatter(file, file.scanner, &Options{}))
+	require.NoError(t, bufferBody(file, &Options{}))
+	return file
+}
+
+func TestSummaryUsesMoreMarker(t *testing.T) {
+	require := require.New(t)
+	file := loadFile(t, "Note.md", `---
+title: A Note
+---
+# A Note
+
+The first paragraph talks about [[Gardens]].
+
+And the second one keeps going. <!--more--> But this is cut.
+
+This isn't part of it either.
+`)
+	require.Equal("The first paragraph talks about Gardens. And the second one keeps going.", extractSummary(file, &Options{}))
+}
+
+func TestSummaryFallsBackToFirstParagraph(t *testing.T) {
+	require := require.New(t)
+	file := loadFile(t, "Note.md", `# A Note
+
+The first paragraph
+spans two lines.
+
+The second paragraph.
+`)
+	require.Equal("The first paragraph spans two lines.", extractSummary(file, &Options{}))
+}
+
+func TestSummaryAddedToFrontmatter(t *testing.T) {
+	require := require.New(t)
+	file := loadFile(t, "Note.md", "Summary here.\n<!--more-->\nThe rest.\n")
+	writer := bytes.Buffer{}
+	err := adjustFrontmatter(file, &Options{Summary: true}, &writer)
+	require.Nil(err)
+	require.Contains(writer.String(), "summary: Summary here.\n")
+
+	file = loadFile(t, "Note.md", "---\nsummary: Hand written\n---\nSummary here.\n")
+	writer = bytes.Buffer{}
+	err = adjustFrontmatter(file, &Options{Summary: true}, &writer)
+	require.Nil(err)
+	require.Contains(writer.String(), "summary: Hand written\n")
+}
@@ -0,0 +1,75 @@
+package backlinker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// tagsKey is the frontmatter key for a note's tags.
+const tagsKey = "tags"
+
+// normalizingTags reports whether any of the tag options are in use.
+func (o *Options) normalizingTags() bool {
+	return o.NormalizeTags || len(o.TagAliases) > 0 || o.SortTags || o.TagsAsList
+}
+
+// normalizeTag lower-cases and trims a tag under Options.NormalizeTags, then replaces it
+// with the tag it's an alias of, if any.
+func (o *Options) normalizeTag(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if o.NormalizeTags {
+		tag = strings.ToLower(tag)
+	}
+	if canonical, exists := o.TagAliases[tag]; exists {
+		return canonical
+	}
+	return tag
+}
+
+// normalizeTags rewrites the tags in a file's frontmatter: normalized, without the
+// duplicates that leaves, and sorted under Options.SortTags. Tags given as a single
+// comma-separated string stay that way unless Options.TagsAsList is set.
+func normalizeTags(file *markdownFile, opts *Options) {
+	value, exists := file.metadata[tagsKey]
+	if !exists || !opts.normalizingTags() {
+		return
+	}
+	var raw []string
+	asString := false
+	switch tags := value.(type) {
+	case string:
+		raw = strings.Split(tags, ",")
+		asString = true
+	case []interface{}:
+		for _, tag := range tags {
+			raw = append(raw, fmt.Sprint(tag))
+		}
+	default:
+		return
+	}
+
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range raw {
+		tag = opts.normalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	if opts.SortTags {
+		sort.Strings(tags)
+	}
+
+	if asString && !opts.TagsAsList {
+		file.metadata[tagsKey] = strings.Join(tags, ", ")
+		return
+	}
+	list := make([]interface{}, 0, len(tags))
+	for _, tag := range tags {
+		list = append(list, tag)
+	}
+	file.metadata[tagsKey] = list
+}
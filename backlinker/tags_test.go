@@ -0,0 +1,38 @@
+package backlinker
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagVariantsCollapse(t *testing.T) {
+	require := require.New(t)
+	opts := Options{NormalizeTags: true, TagAliases: map[string]string{"golang": "go"}}
+
+	file := loadFile(t, "Note.md", "---\ntags: [go, Golang, web, GO, ' golang ']\n---\nBody.\n")
+	writer := bytes.Buffer{}
+	require.NoError(adjustFrontmatter(file, &opts, &writer))
+	require.Contains(writer.String(), "tags:\n- go\n- web\ntitle: Note\n")
+
+	file = loadFile(t, "Note.md", "---\ntags: web, golang, go\n---\nBody.\n")
+	writer = bytes.Buffer{}
+	require.NoError(adjustFrontmatter(file, &opts, &writer))
+	require.Contains(writer.String(), "tags: web, go\n", "A string stays a string")
+
+	opts.SortTags = true
+	opts.TagsAsList = true
+	file = loadFile(t, "Note.md", "---\ntags: web, golang, go\n---\nBody.\n")
+	writer = bytes.Buffer{}
+	require.NoError(adjustFrontmatter(file, &opts, &writer))
+	require.Contains(writer.String(), "tags:\n- go\n- web\n")
+}
+
+func TestTagsUntouchedByDefault(t *testing.T) {
+	require := require.New(t)
+	file := loadFile(t, "Note.md", "---\ntags: [go, go, Web]\n---\nBody.\n")
+	writer := bytes.Buffer{}
+	require.NoError(adjustFrontmatter(file, &Options{}, &writer))
+	require.Contains(writer.String(), "tags: [go, go, Web]\n")
+}
@@ -0,0 +1,223 @@
+package backlinker
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Target is the static site generator the output is written for.
+type Target string
+
+const (
+	// TargetHugo writes the output for Hugo.
+	TargetHugo Target = ""
+	// TargetJekyll writes the output for Jekyll, as for GitHub Pages: date notes become
+	// posts, in _posts with the names Jekyll gives them, links follow Jekyll's URLs,
+	// frontmatter is all YAML, and the keys written for Hugo get their Jekyll names. A
+	// note's permalink takes the place of Hugo's url.
+	TargetJekyll Target = "jekyll"
+	// TargetEleventy writes the output for Eleventy: links follow Eleventy's URLs, or the
+	// permalink that Options.Permalink gives each note, and frontmatter is all YAML. A
+	// note's own permalink takes the place of Hugo's url.
+	TargetEleventy Target = "eleventy"
+	// TargetZola writes the output for Zola: frontmatter is all TOML, with the keys Zola
+	// doesn't know under [extra] and tags under [taxonomies], and notes link to each other
+	// by path, in the form @/section/page.md. A note's path takes the place of Hugo's url.
+	TargetZola Target = "zola"
+)
+
+// target is the Target, with "hugo" spelled out taken for the default.
+func (o *Options) target() Target {
+	if o.Target == "hugo" {
+		return TargetHugo
+	}
+	return o.Target
+}
+
+// jekyllKeys are the names Jekyll (and its common plugins) give the frontmatter keys
+// written for Hugo.
+var jekyllKeys = map[string]string{
+	"lastmod": "last_modified_at",
+	"aliases": "redirect_from",
+}
+
+// zolaKeys are the keys Zola takes at the top of a page's frontmatter. Any other key is
+// an error to Zola, so the rest are written under [extra].
+var zolaKeys = map[string]bool{
+	"title": true, "description": true, "date": true, "updated": true, "weight": true, "draft": true,
+	"slug": true, "path": true, "aliases": true, "authors": true, "in_search_index": true,
+	"template": true, "render": true,
+}
+
+// zolaTaxonomies are the keys written under [taxonomies] for Zola.
+var zolaTaxonomies = map[string]bool{"tags": true, "categories": true}
+
+// frontmatterKey is the name that the target gives a frontmatter key written here.
+func (o *Options) frontmatterKey(key string) string {
+	if renamed, exists := jekyllKeys[key]; exists && o.target() == TargetJekyll {
+		return renamed
+	}
+	if key == "lastmod" && o.target() == TargetZola {
+		return "updated"
+	}
+	return key
+}
+
+// urlKey is the frontmatter key giving the URL a note is published at.
+func (o *Options) urlKey() string {
+	switch o.target() {
+	case TargetJekyll, TargetEleventy:
+		return "permalink"
+	case TargetZola:
+		return "path"
+	}
+	return "url"
+}
+
+// outputFormat is the format the file's frontmatter is written in: the one it was read
+// in, unless the target only takes one.
+func (o *Options) outputFormat(file *markdownFile) frontmatterFormat {
+	switch o.target() {
+	case TargetHugo:
+		return file.frontmatter
+	case TargetZola:
+		return tomlFrontmatter
+	}
+	return yamlFrontmatter
+}
+
+// targetMetadata arranges the frontmatter the way the target needs it, which for Zola
+// means moving the keys it doesn't know under [extra] and the tags under [taxonomies].
+func (o *Options) targetMetadata(meta map[string]interface{}) map[string]interface{} {
+	if o.target() != TargetZola {
+		return meta
+	}
+	result := make(map[string]interface{})
+	taxonomies := make(map[string]interface{})
+	extra := make(map[string]interface{})
+	for key, value := range meta {
+		switch {
+		case zolaKeys[key]:
+			result[key] = value
+		case zolaTaxonomies[key]:
+			taxonomies[key] = taxonomyTerms(value)
+		case key == "taxonomies" || key == "extra":
+			// Frontmatter written for Zola already keeps what it has
+			table, isTable := stringKeyed(value).(map[string]interface{})
+			if !isTable {
+				extra[key] = value
+				continue
+			}
+			for name, item := range table {
+				if key == "taxonomies" {
+					taxonomies[name] = item
+				} else {
+					extra[name] = item
+				}
+			}
+		default:
+			extra[key] = value
+		}
+	}
+	if len(taxonomies) > 0 {
+		result["taxonomies"] = taxonomies
+	}
+	if len(extra) > 0 {
+		result["extra"] = extra
+	}
+	return result
+}
+
+// taxonomyTerms is the list of terms Zola needs a taxonomy to be, from a list or a
+// comma-separated string.
+func taxonomyTerms(value interface{}) []interface{} {
+	terms := []interface{}{}
+	switch value := value.(type) {
+	case []interface{}:
+		return value
+	case string:
+		for _, term := range strings.Split(value, ",") {
+			if term = strings.TrimSpace(term); term != "" {
+				terms = append(terms, term)
+			}
+		}
+	case nil:
+	default:
+		terms = append(terms, value)
+	}
+	return terms
+}
+
+// zolaLink is the internal link Zola resolves to the file, by its path: @/page.md. It's
+// written in angle brackets when the path has spaces, to keep the markdown link whole.
+func (o *Options) zolaLink(file *markdownFile, fragment string) string {
+	link := "@/" + o.outputPath(file) + fragment
+	if strings.ContainsAny(link, " \t") {
+		return "<" + link + ">"
+	}
+	return link
+}
+
+// jekyllPostName is the name Jekyll needs a date note to have as a post: its date, then
+// the part of its URL that's its own. That's its slug or its title, or else the name of
+// the date notes as a whole, since the title of a date note is its date already.
+func (o *Options) jekyllPostName(file *markdownFile) string {
+	date, _ := o.filenameDate(file.OriginalName)
+	day := date.Format("2006-01-02")
+	name := file.slug
+	if name == "" && file.Title != removeExtension(file.OriginalName) {
+		name = file.Title
+	}
+	if name == "" {
+		name = o.labels().Feed
+	}
+	return day + "-" + urlize(name) + ".md"
+}
+
+// jekyllLink is the URL Jekyll publishes the file at, by its default permalinks: the
+// date and name of a post, under the directories it's in as categories, or the path of
+// any other page.
+func (o *Options) jekyllLink(file *markdownFile) string {
+	dir := o.outputDir(file)
+	if file.IsDateFile {
+		date, _ := o.filenameDate(file.OriginalName)
+		name := strings.TrimPrefix(removeExtension(o.jekyllPostName(file)), date.Format("2006-01-02")+"-")
+		return "./" + path.Join(urlize(dir), date.Format("2006/01/02"), name) + ".html"
+	}
+	return "./" + (&url.URL{Path: removeExtension(path.Join(dir, file.OriginalName))}).EscapedPath() + ".html"
+}
+
+// datePrefix matches the date Eleventy leaves out of the slug of a file like
+// 2024-02-01-notes.md.
+var datePrefix = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}-`)
+
+// eleventyLink is the URL Eleventy publishes the file at: the path of Options.Permalink,
+// when it's set, and otherwise Eleventy's own, which is the directory the file is in and
+// its name, without any date it starts with. An index is the URL of its directory.
+func (o *Options) eleventyLink(file *markdownFile) string {
+	if o.Permalink != "" {
+		return "./" + strings.TrimPrefix(o.expandPermalink(o.Permalink, file), "/")
+	}
+	name := removeExtension(file.OriginalName)
+	if name == "index" {
+		name = ""
+	} else if trimmed := datePrefix.ReplaceAllString(name, ""); trimmed != "" {
+		name = trimmed
+	}
+	link := path.Join(o.outputDir(file), name)
+	if link == "" {
+		return "./"
+	}
+	return "./" + (&url.URL{Path: link}).EscapedPath() + "/"
+}
+
+// applyPermalink writes the permalink that Options.Permalink gives the file into its
+// frontmatter, for Eleventy to publish it at, unless it has a permalink of its own.
+func applyPermalink(file *markdownFile, opts *Options) {
+	if opts.target() != TargetEleventy || opts.Permalink == "" || file.url != "" {
+		return
+	}
+	file.metadata["permalink"] = "/" + strings.TrimPrefix(opts.eleventyLink(file), "./")
+}
@@ -0,0 +1,130 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJekyllTarget(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"2024-02-01.md": "Planted the [[Garden]].\n",
+		"Garden.md":     "Started on [[2024-02-01]], by the [[Lily Pond]]. See [[About]].\n",
+		"Lily Pond.md":  "+++\ntitle = \"Lily Pond\"\n+++\nFrogs.\n",
+		"About.md":      "---\npermalink: /about/\n---\nWho I am.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{Target: TargetJekyll}))
+
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "Started on [2024-02-01](./2024/02/01/journal.html), by the [Lily Pond](./Lily%20Pond.html). "+
+		"See [About](./about/).\n")
+	post, err := ioutil.ReadFile(filepath.Join(destDir, "_posts", "2024-02-01-journal.md"))
+	require.NoError(err)
+	require.Contains(string(post), "Planted the [Garden](./Garden.html).\n")
+	require.NoFileExists(filepath.Join(destDir, "2024-02-01.md"))
+	pond, err := ioutil.ReadFile(filepath.Join(destDir, "Lily Pond.md"))
+	require.NoError(err)
+	require.True(strings.HasPrefix(string(pond), "---\n"), "Jekyll only takes YAML frontmatter")
+	require.Contains(string(pond), "title: Lily Pond\n---\n")
+
+	destDir = t.TempDir()
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{Target: "jekyll", LinkStyle: LinkStyleJekyll}))
+	post, err = ioutil.ReadFile(filepath.Join(destDir, "_posts", "2024-02-01-journal.md"))
+	require.NoError(err)
+	require.Contains(string(post), "Planted the [Garden]({% link Garden.md %}).\n")
+	garden, err = ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "Started on [2024-02-01]({% link _posts/2024-02-01-journal.md %})")
+}
+
+func TestEleventyTarget(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"2024-02-01-planting.md": "Planted the [[Garden]].\n",
+		"Garden.md":              "Started [[2024-02-01-planting|planting]], by the [[Lily Pond]].\n",
+		"Lily Pond.md":           "---\npermalink: /ponds/lily/\n---\nFrogs.\n",
+	})
+	opts := Options{Target: TargetEleventy, BacklinksDataFile: "_data/backlinks.json"}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "Started [planting](./planting/), by the [Lily Pond](./ponds/lily/).\n")
+
+	data, err := ioutil.ReadFile(filepath.Join(destDir, "_data", "backlinks.json"))
+	require.NoError(err)
+	require.JSONEq(`{
+		"/Garden/": [{"title": "2024-02-01-planting", "url": "/planting/", "context": "Planted the [Garden](./Garden/)."}],
+		"/planting/": [{"title": "Garden", "url": "/Garden/",
+			"context": "Started [planting](./planting/), by the [Lily Pond](./ponds/lily/)."}],
+		"/ponds/lily/": [{"title": "Garden", "url": "/Garden/",
+			"context": "Started [planting](./planting/), by the [Lily Pond](./ponds/lily/)."}]
+	}`, string(data))
+
+	destDir = t.TempDir()
+	opts = Options{Target: TargetEleventy, Permalink: "/notes/:slug/"}
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	garden, err = ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Contains(string(garden), "permalink: /notes/garden/\n")
+	require.Contains(string(garden), "Started [planting](./notes/2024-02-01-planting/), by the [Lily Pond](./ponds/lily/).\n",
+		"A note's own permalink wins")
+	pond, err := ioutil.ReadFile(filepath.Join(destDir, "Lily Pond.md"))
+	require.NoError(err)
+	require.Contains(string(pond), "permalink: /ponds/lily/\n")
+}
+
+func TestZolaTarget(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md":    "---\ntags: plants, outdoors\nmood: sunny\n---\nBy the [[Lily Pond#Frogs]].\n",
+		"Lily Pond.md": "+++\ntitle = \"Lily Pond\"\n[extra]\ndepth = 2\n+++\n## Frogs\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{Target: TargetZola}))
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.Equal("+++\ntitle = \"Garden\"\n\n[extra]\n  mood = \"sunny\"\n\n[taxonomies]\n  tags = [\"plants\", \"outdoors\"]\n+++\n"+
+		"By the [Lily Pond#Frogs](<@/Lily Pond.md#frogs>).\n", string(garden))
+	pond, err := ioutil.ReadFile(filepath.Join(destDir, "Lily Pond.md"))
+	require.NoError(err)
+	require.Contains(string(pond), "[extra]\n  depth = 2\n")
+	require.Contains(string(pond), "- [Garden](@/Garden.md)\n")
+}
+
+func TestBacklinksAsHugoData(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md":    "Plants by the [[Lily Pond]].\n",
+		"Lily Pond.md": "---\nslug: pond\n---\nFrogs.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{BacklinksDataOnly: true}))
+	pond, err := ioutil.ReadFile(filepath.Join(destDir, "Lily Pond.md"))
+	require.NoError(err)
+	require.Equal("---\nslug: pond\ntitle: Lily Pond\n---\nFrogs.\n", string(pond), "The page has no backlinks section")
+	data, err := ioutil.ReadFile(filepath.Join(destDir, "data", "backlinks.json"))
+	require.NoError(err)
+	require.JSONEq(`{"pond": [{"title": "Garden", "url": "/garden/", "context": "Plants by the [Lily Pond](./pond/)."}]}`, string(data))
+}
+
+func TestBacklinksShortcode(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md":    "Plants by the [[Lily Pond]].\n",
+		"Lily Pond.md": "Frogs.\n",
+	})
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, Options{BacklinksShortcode: "backlinks"}))
+	pond, err := ioutil.ReadFile(filepath.Join(destDir, "Lily Pond.md"))
+	require.NoError(err)
+	require.Contains(string(pond), "\n{{< backlinks \"lily-pond\" >}}\n")
+	require.NotContains(string(pond), "## Backlinks")
+	garden, err := ioutil.ReadFile(filepath.Join(destDir, "Garden.md"))
+	require.NoError(err)
+	require.NotContains(string(garden), "{{<", "Only notes with backlinks get the shortcode")
+	data, err := ioutil.ReadFile(filepath.Join(destDir, "data", "backlinks.json"))
+	require.NoError(err)
+	require.Contains(string(data), `"lily-pond": [`)
+}
@@ -0,0 +1,72 @@
+package backlinker
+
+import (
+	"io"
+	"io/ioutil"
+	"text/template"
+)
+
+// templateBacklink is a backlink as the templates are given it.
+type templateBacklink struct {
+	Title string
+	URL   string
+	// Label is the text the link was shown with, or "" if it had none.
+	Label string
+	// Context is the text around the link, with its links converted.
+	Context string
+}
+
+// backlinksTemplateData is what Options.BacklinksTemplate is given to render the
+// backlinks of a note.
+type backlinksTemplateData struct {
+	Title string
+	URL   string
+	// Params is the note's frontmatter.
+	Params map[string]interface{}
+	// Heading is the heading the section would have, from Options.Labels.
+	Heading   string
+	Backlinks []templateBacklink
+}
+
+// parseBacklinksTemplate parses Options.BacklinksTemplate, or the BacklinksTemplateFile,
+// for the run, leaving it nil when there's neither.
+func (o *Options) parseBacklinksTemplate() error {
+	source := o.BacklinksTemplate
+	if source == "" && o.BacklinksTemplateFile != "" {
+		data, err := ioutil.ReadFile(o.BacklinksTemplateFile)
+		if err != nil {
+			return err
+		}
+		source = string(data)
+	}
+	if source == "" {
+		o.backlinksTemplate = nil
+		return nil
+	}
+	tmpl, err := template.New("backlinks").Parse(source)
+	if err != nil {
+		return err
+	}
+	o.backlinksTemplate = tmpl
+	return nil
+}
+
+// renderBacklinks writes the file's backlinks section with the backlinks template.
+func renderBacklinks(file *markdownFile, fileMap map[string]*markdownFile, opts *Options, writer io.Writer) error {
+	data := backlinksTemplateData{
+		Title:     file.Title,
+		URL:       opts.pageLink(file, ""),
+		Params:    file.metadata,
+		Heading:   opts.labels().Backlinks,
+		Backlinks: []templateBacklink{},
+	}
+	for _, backlink := range listedBacklinks(file, opts) {
+		data.Backlinks = append(data.Backlinks, templateBacklink{
+			Title:   backlink.OtherFile.Title,
+			URL:     opts.pageLink(backlink.OtherFile, ""),
+			Label:   backlink.Label,
+			Context: backlinkContext(backlink, fileMap, opts),
+		})
+	}
+	return opts.backlinksTemplate.Execute(writer, data)
+}
@@ -0,0 +1,44 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBacklinksTemplate(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md":    "Plants by the [[Lily Pond|pond]].\n",
+		"Shed.md":      "Tools for the [[Lily Pond]].\n",
+		"Lily Pond.md": "---\nicon: frog\n---\nFrogs.\n",
+	})
+	templateFile := filepath.Join(t.TempDir(), "backlinks.tmpl")
+	require.NoError(ioutil.WriteFile(templateFile, []byte(`
+<details>
+<summary>{{ .Params.icon }} {{ len .Backlinks }} notes link to {{ .Title }}</summary>
+{{ range .Backlinks }}
+* [{{ .Title }}]({{ .URL }}){{ with .Label }} as "{{ . }}"{{ end }}: {{ .Context }}
+{{- end }}
+</details>
+`), 0644))
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{BacklinksTemplateFile: templateFile})
+	require.NoError(err)
+
+	pond, err := ioutil.ReadFile(filepath.Join(destDir, "Lily Pond.md"))
+	require.NoError(err)
+	require.Contains(string(pond), `
+<details>
+<summary>frog 2 notes link to Lily Pond</summary>
+
+* [Garden](./garden/) as "pond": Plants by the [pond](./lily-pond/).
+* [Shed](./shed/): Tools for the [Lily Pond](./lily-pond/).
+</details>
+`)
+	require.NotContains(string(pond), "## Backlinks")
+
+	err = ProcessBackLinksWithOptions(sourceDir, t.TempDir(), Options{BacklinksTemplate: "{{ .Missing }"})
+	require.Error(err, "A template that doesn't parse is an error")
+}
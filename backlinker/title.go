@@ -0,0 +1,68 @@
+package backlinker
+
+import "strings"
+
+// TitlePolicy decides what happens when a note has both a frontmatter title and a
+// leading H1 heading, which some themes would render twice.
+type TitlePolicy string
+
+const (
+	// TitleKeepBoth leaves both the frontmatter title and the H1 alone.
+	TitleKeepBoth TitlePolicy = ""
+	// TitlePreferFrontmatter removes a leading H1 that repeats the frontmatter title.
+	TitlePreferFrontmatter TitlePolicy = "prefer-frontmatter"
+	// TitlePreferH1 uses a leading H1 as the title and removes it from the body.
+	TitlePreferH1 TitlePolicy = "prefer-h1"
+)
+
+// leadingH1 finds the H1 heading that opens the body, skipping blank lines. It returns the
+// heading's text and its index in the body, or -1 when the body doesn't open with one.
+func leadingH1(file *markdownFile) (string, int) {
+	for i, line := range file.body {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "# ") {
+			return strings.TrimSpace(strings.TrimPrefix(trimmed, "# ")), i
+		}
+		return "", -1
+	}
+	return "", -1
+}
+
+// removeBodyLine removes the line of the body at index, along with a blank line directly
+// after it.
+func removeBodyLine(file *markdownFile, index int) {
+	lines := file.body
+	end := index + 1
+	if end < len(lines) && strings.TrimSpace(lines[end]) == "" {
+		end++
+	}
+	lines = append(lines[:index:index], lines[end:]...)
+	file.body = lines
+	file.resetScanner()
+}
+
+// applyTitlePolicy reconciles the frontmatter title with a leading H1 according to
+// Options.TitlePolicy. It runs before adjustFrontmatter so the resulting title is the
+// one used everywhere.
+func applyTitlePolicy(file *markdownFile, opts *Options) {
+	if opts.TitlePolicy == TitleKeepBoth || file.IsNew {
+		return
+	}
+	heading, index := leadingH1(file)
+	if index < 0 {
+		return
+	}
+	title, hasTitle := metadataTitle(file.metadata)
+	switch opts.TitlePolicy {
+	case TitlePreferFrontmatter:
+		if hasTitle && strings.EqualFold(strings.TrimSpace(title), heading) {
+			removeBodyLine(file, index)
+		}
+	case TitlePreferH1:
+		file.metadata["title"] = heading
+		removeBodyLine(file, index)
+	}
+}
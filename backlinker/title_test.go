@@ -0,0 +1,57 @@
+package backlinker
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const titleAndHeading = `---
+title: Digital Gardens
+---
+
+# Digital gardens
+
+Growing [[Notes]].
+`
+
+// generateWithTitlePolicy runs the file through the title policy and frontmatter
+// and link conversion, returning the result.
+func generateWithTitlePolicy(t *testing.T, text string, policy TitlePolicy) (*markdownFile, string) {
+	file := loadFile(t, "Gardens.md", text)
+	opts := Options{TitlePolicy: policy}
+	applyTitlePolicy(file, &opts)
+	writer := bytes.Buffer{}
+	require.NoError(t, adjustFrontmatter(file, &opts, &writer))
+	require.NoError(t, convertLinks(file, map[string]*markdownFile{}, &opts, &writer))
+	return file, writer.String()
+}
+
+func TestTitlePolicyKeepBoth(t *testing.T) {
+	require := require.New(t)
+	file, output := generateWithTitlePolicy(t, titleAndHeading, TitleKeepBoth)
+	require.Equal("Digital Gardens", file.Title)
+	require.Equal("---\ntitle: Digital Gardens\n---\n\n# Digital gardens\n\nGrowing [Notes](./notes/).\n", output)
+}
+
+func TestTitlePolicyPreferFrontmatter(t *testing.T) {
+	require := require.New(t)
+	file, output := generateWithTitlePolicy(t, titleAndHeading, TitlePreferFrontmatter)
+	require.Equal("Digital Gardens", file.Title)
+	require.Equal("---\ntitle: Digital Gardens\n---\n\nGrowing [Notes](./notes/).\n", output)
+
+	_, output = generateWithTitlePolicy(t, "---\ntitle: Something Else\n---\n# Digital gardens\n", TitlePreferFrontmatter)
+	require.Contains(output, "# Digital gardens\n", "A heading that differs from the title is kept")
+}
+
+func TestTitlePolicyPreferH1(t *testing.T) {
+	require := require.New(t)
+	file, output := generateWithTitlePolicy(t, titleAndHeading, TitlePreferH1)
+	require.Equal("Digital gardens", file.Title)
+	require.Equal("---\ntitle: Digital gardens\n---\n\nGrowing [Notes](./notes/).\n", output)
+
+	file, output = generateWithTitlePolicy(t, "# From The Heading\nBody text.\n", TitlePreferH1)
+	require.Equal("From The Heading", file.Title)
+	require.Equal("---\ntitle: From The Heading\n---\nBody text.\n", output)
+}
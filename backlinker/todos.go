@@ -0,0 +1,63 @@
+package backlinker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// todo is an unchecked task list item found in a note.
+type todo struct {
+	Source *markdownFile
+	Text   string
+}
+
+// collectTodos finds the unchecked task list items (`- [ ] ...`) in the body of each
+// note, skipping fenced code blocks.
+func collectTodos(fileMap map[string]*markdownFile, opts *Options) []todo {
+	task := regexp.MustCompile(`^\s*[-*+]\s+\[ \]\s+(.+)$`)
+	var todos []todo
+	for _, file := range includedFiles(fileMap) {
+		if file.IsNew {
+			continue
+		}
+		inFence := false
+		for _, line := range bodyWithoutGeneratedSections(file, opts) {
+			trimmed := strings.TrimSpace(line)
+			if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+				inFence = !inFence
+				continue
+			}
+			if inFence {
+				continue
+			}
+			if match := task.FindStringSubmatch(line); match != nil {
+				todos = append(todos, todo{Source: file, Text: strings.TrimSpace(match[1])})
+			}
+		}
+	}
+	return todos
+}
+
+// addTodoPage gathers every unchecked task into a single page, grouped under a link to
+// the note each came from.
+func addTodoPage(fileMap map[string]*markdownFile, opts *Options) error {
+	if opts.TodoPage == "" {
+		return nil
+	}
+	todos := collectTodos(fileMap, opts)
+	var body strings.Builder
+	var source *markdownFile
+	for _, item := range todos {
+		if item.Source != source {
+			source = item.Source
+			heading := fmt.Sprintf("[%s](%s)", source.Title, opts.pageLink(source, ""))
+			if date, ok := opts.metadataDate(source); ok && opts.TodoPageDates {
+				heading += " (" + date.Format("2006-01-02") + ")"
+			}
+			body.WriteString("\n## " + heading + "\n\n")
+		}
+		body.WriteString("- [ ] " + convertLinksOnLine(item.Text, fileMap, opts) + "\n")
+	}
+	return addGeneratedPage(fileMap, opts.TodoPage, opts.labels().TodoPage, body.String(), opts)
+}
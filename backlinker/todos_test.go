@@ -0,0 +1,49 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTodoPage(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"2020-05-01.md": "- [ ] Water the [[Garden]]\n- [x] Already done\n",
+		"Garden.md":     "---\ntitle: The Garden\n---\n* [ ] Plant seeds\n\n```\n- [ ] Not a task\n```\n",
+		"Done.md":       "- [X] Nothing left\n",
+	})
+	opts := Options{TodoPage: "todos.md", TodoPageDates: true}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+	require.NoError(err)
+	todos, err := ioutil.ReadFile(filepath.Join(destDir, "todos.md"))
+	require.NoError(err)
+	require.Equal(`---
+title: Todos
+---
+
+## [2020-05-01](./2020-05-01/) (2020-05-01)
+
+- [ ] Water the [Garden](./garden/)
+
+## [The Garden](./garden/) (2020-05-01)
+
+- [ ] Plant seeds
+`, string(todos))
+}
+
+func TestTodoPageDoesNotReplaceNote(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Todos.md": "My own list.\n- [ ] Something\n",
+	})
+	report := Report{}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, Options{TodoPage: "todos.md", Report: &report})
+	require.NoError(err)
+	todos, err := ioutil.ReadFile(filepath.Join(destDir, "Todos.md"))
+	require.NoError(err)
+	require.Contains(string(todos), "My own list.")
+	require.Equal(1, len(report.Warnings))
+}
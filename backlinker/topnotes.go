@@ -0,0 +1,47 @@
+package backlinker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// addTopNotes adds a page listing the notes with the most backlinks, with how many each
+// has. Notes with the same number are listed newest first, then by title. Drafts are
+// left out even when they're written, since the site won't show them.
+func addTopNotes(fileMap map[string]*markdownFile, opts *Options) error {
+	if opts.TopNotesPage == "" {
+		return nil
+	}
+	var notes []*markdownFile
+	for _, file := range includedFiles(fileMap) {
+		if !file.IsNew && !isDraft(file) && len(file.BackLinks) > 0 {
+			notes = append(notes, file)
+		}
+	}
+	sort.SliceStable(notes, func(i, j int) bool {
+		countI, countJ := len(notes[i].BackLinks), len(notes[j].BackLinks)
+		if countI != countJ {
+			return countI > countJ
+		}
+		dateI, hasDateI := opts.metadataDate(notes[i])
+		dateJ, hasDateJ := opts.metadataDate(notes[j])
+		if hasDateI != hasDateJ {
+			return hasDateI
+		}
+		if !dateI.Equal(dateJ) {
+			return dateI.After(dateJ)
+		}
+		return opts.compareTitles(notes[i].Title, notes[j].Title) < 0
+	})
+	if len(notes) > opts.topNotesLimit() {
+		notes = notes[:opts.topNotesLimit()]
+	}
+
+	var body strings.Builder
+	body.WriteString("\n")
+	for i, file := range notes {
+		body.WriteString(fmt.Sprintf("%d. [%s](%s) (%d)\n", i+1, file.Title, opts.pageLink(file, ""), len(file.BackLinks)))
+	}
+	return addGeneratedPage(fileMap, opts.TopNotesPage, opts.labels().TopNotes, body.String(), opts)
+}
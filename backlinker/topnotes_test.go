@@ -0,0 +1,47 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopNotes(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Hub.md":      "Popular.\n",
+		"Older.md":    "---\ndate: 2024-01-01\n---\nSometimes.\n",
+		"Newer.md":    "---\ndate: 2024-03-01\n---\nSometimes.\n",
+		"Rare.md":     "Once.\n",
+		"Secret.md":   "---\ndraft: true\n---\nLinks to [[Hub]] and [[Rare]].\n",
+		"A.md":        "[[Hub]] [[Older]] [[Newer]] [[Rare]] [[Missing]] [[Secret]]\n",
+		"B.md":        "[[Hub]] [[Older]] [[Newer]] [[Missing]] [[Secret]]\n",
+		"C.md":        "[[Hub]] [[Missing]] [[Secret]]\n",
+		"Unlinked.md": "Nobody links here.\n",
+	})
+	opts := Options{TopNotesPage: "top-notes.md", TopNotesLimit: 3, SkipDrafts: true}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+	require.NoError(err)
+	top, err := ioutil.ReadFile(filepath.Join(destDir, "top-notes.md"))
+	require.NoError(err)
+	require.Equal(`---
+title: Top Notes
+---
+
+1. [Hub](./hub/) (3)
+2. [Newer](./newer/) (2)
+3. [Older](./older/) (2)
+`, string(top))
+
+	// A draft that's written is still left out, though its links count
+	destDir = t.TempDir()
+	opts.SkipDrafts = false
+	require.NoError(ProcessBackLinksWithOptions(sourceDir, destDir, opts))
+	top, err = ioutil.ReadFile(filepath.Join(destDir, "top-notes.md"))
+	require.NoError(err)
+	require.FileExists(filepath.Join(destDir, "Secret.md"))
+	require.Contains(string(top), "\n1. [Hub](./hub/) (4)\n2. [Newer](./newer/) (2)\n")
+	require.NotContains(string(top), "Secret")
+}
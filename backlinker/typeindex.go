@@ -0,0 +1,59 @@
+package backlinker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// addTypeIndex adds a page listing the notes grouped by the type given in their
+// frontmatter, with the notes that have no type at the end.
+func addTypeIndex(fileMap map[string]*markdownFile, opts *Options) error {
+	if opts.TypeIndexPage == "" {
+		return nil
+	}
+	groups := make(map[string][]*markdownFile)
+	var untyped []*markdownFile
+	for _, file := range includedFiles(fileMap) {
+		if file.IsNew {
+			continue
+		}
+		value, exists := file.metadata[opts.typeKey()]
+		noteType := ""
+		if exists && value != nil {
+			noteType = strings.TrimSpace(fmt.Sprint(value))
+		}
+		if noteType == "" {
+			untyped = append(untyped, file)
+			continue
+		}
+		groups[noteType] = append(groups[noteType], file)
+	}
+
+	types := make([]string, 0, len(groups))
+	for noteType := range groups {
+		types = append(types, noteType)
+	}
+	sort.SliceStable(types, func(i, j int) bool {
+		return opts.compareTitles(types[i], types[j]) < 0
+	})
+	var body strings.Builder
+	writeGroup := func(heading string, files []*markdownFile) {
+		if len(files) == 0 {
+			return
+		}
+		sort.SliceStable(files, func(i, j int) bool {
+			return opts.compareTitles(files[i].Title, files[j].Title) < 0
+		})
+		body.WriteString("\n## " + heading + "\n\n")
+		for _, file := range files {
+			body.WriteString(fmt.Sprintf("- [%s](%s)\n", file.Title, opts.pageLink(file, "")))
+		}
+	}
+	for _, noteType := range types {
+		writeGroup(noteType, groups[noteType])
+	}
+	labels := opts.labels()
+	writeGroup(labels.Untyped, untyped)
+	return addGeneratedPage(fileMap, opts.TypeIndexPage, labels.TypeIndex, body.String(), opts)
+}
@@ -0,0 +1,48 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeIndex(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Standup.md":    "---\ntype: meeting\n---\nNotes about [[Sam]].\n",
+		"Retro.md":      "---\ntype: meeting\n---\nWent well.\n",
+		"Sam.md":        "---\ntype: person\n---\nA person.\n",
+		"Garden.md":     "---\ntype: project\ntitle: The Garden\n---\nTodo:\n- [ ] Dig\n",
+		"Scratch.md":    "No type here.\n",
+		"2020-05-01.md": "---\ntype: \"\"\n---\nAn empty type.\n",
+	})
+	opts := Options{TypeIndexPage: "by-type.md", TodoPage: "todos.md"}
+	err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+	require.NoError(err)
+	index, err := ioutil.ReadFile(filepath.Join(destDir, "by-type.md"))
+	require.NoError(err)
+	require.Equal(`---
+title: Notes by Type
+---
+
+## meeting
+
+- [Retro](./retro/)
+- [Standup](./standup/)
+
+## person
+
+- [Sam](./sam/)
+
+## project
+
+- [The Garden](./garden/)
+
+## Untyped
+
+- [2020-05-01](./2020-05-01/)
+- [Scratch](./scratch/)
+`, string(index))
+}
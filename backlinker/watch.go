@@ -0,0 +1,126 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long Watch waits for changes to settle before rebuilding, so
+// that saving several files at once (or an editor's write-and-rename) is one rebuild.
+const watchDebounce = 100 * time.Millisecond
+
+// Watch runs ProcessBackLinksWithOptions, and then runs it again each time a markdown
+// file in the source directory is created, changed, renamed or removed, until done is
+// closed. A run that fails is logged and the watching carries on. Files left just as a
+// run wrote them don't count as changed, which keeps a source directory that is also the
+// destination from rebuilding forever.
+func Watch(sourceDir string, destDir string, opts Options, done <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	dirs, err := watchDirs(watcher, sourceDir, &opts)
+	if err != nil {
+		return err
+	}
+
+	var written map[string]os.FileInfo
+	rebuild := func() {
+		err := ProcessBackLinksWithOptions(sourceDir, destDir, opts)
+		if err != nil {
+			opts.logf("Error when processing: %v\n", err)
+		}
+		written = statFiles(dirs)
+	}
+	rebuild()
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-done:
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+				if event.Op&fsnotify.Create != 0 && opts.Recursive {
+					added, err := watchDirs(watcher, event.Name, &opts)
+					if err != nil {
+						opts.logf("Can't watch %s: %v\n", event.Name, err)
+					}
+					dirs = append(dirs, added...)
+				}
+				continue
+			}
+			if isMarkdownFile(event.Name) && event.Op != fsnotify.Chmod && changedSince(event.Name, written) {
+				settle = time.After(watchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			opts.logf("Error when watching: %v\n", err)
+		case <-settle:
+			settle = nil
+			opts.logf("Rebuilding after changes in %s\n", sourceDir)
+			rebuild()
+		}
+	}
+}
+
+// watchDirs watches dir, and its subdirectories (other than hidden ones) with
+// Options.Recursive. It returns the directories it added.
+func watchDirs(watcher *fsnotify.Watcher, dir string, opts *Options) ([]string, error) {
+	if !opts.Recursive {
+		return []string{dir}, watcher.Add(dir)
+	}
+	var added []string
+	err := filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if name != dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		added = append(added, name)
+		return watcher.Add(name)
+	})
+	return added, err
+}
+
+// statFiles returns the state of the markdown files in the directories, by filename.
+func statFiles(dirs []string) map[string]os.FileInfo {
+	files := make(map[string]os.FileInfo)
+	for _, dir := range dirs {
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			if !info.IsDir() && isMarkdownFile(info.Name()) {
+				files[filepath.Join(dir, info.Name())] = info
+			}
+		}
+	}
+	return files
+}
+
+// changedSince reports whether the file is different now from its state in files:
+// created, changed or removed.
+func changedSince(filename string, files map[string]os.FileInfo) bool {
+	before, existed := files[filename]
+	now, err := os.Stat(filename)
+	if err != nil {
+		return existed
+	}
+	return !existed || now.Size() != before.Size() || !now.ModTime().Equal(before.ModTime())
+}
@@ -0,0 +1,70 @@
+package backlinker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// waitForFile waits for the file to exist and contain text.
+func waitForFile(t *testing.T, filename string, text string) string {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := ioutil.ReadFile(filename)
+		if err == nil && strings.Contains(string(data), text) {
+			return string(data)
+		}
+		if time.Now().After(deadline) {
+			require.FailNow(t, "timed out waiting for "+filename, "last read: %s (%v)", data, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestWatchRebuildsOnChange(t *testing.T) {
+	require := require.New(t)
+	sourceDir, destDir := writeVault(t, map[string]string{
+		"Garden.md": "The garden.\n",
+	})
+	done := make(chan struct{})
+	watching := make(chan error)
+	go func() {
+		watching <- Watch(sourceDir, destDir, Options{}, done)
+	}()
+	waitForFile(t, filepath.Join(destDir, "Garden.md"), "The garden.")
+
+	require.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "Shed.md"), []byte("Next to the [[Garden]].\n"), 0644))
+	garden := waitForFile(t, filepath.Join(destDir, "Garden.md"), "- [Shed](./shed/)")
+	require.Contains(garden, "Next to the [Garden](./garden/).")
+
+	close(done)
+	require.NoError(<-watching)
+}
+
+func TestWatchInPlaceDoesNotLoop(t *testing.T) {
+	require := require.New(t)
+	sourceDir, _ := writeVault(t, map[string]string{
+		"Garden.md": "The garden.\n",
+		"Shed.md":   "Next to the [[Garden]].\n",
+	})
+	runs := 0
+	opts := Options{Progress: func(done int, total int, current string) {
+		if done == 0 {
+			runs++
+		}
+	}}
+	done := make(chan struct{})
+	watching := make(chan error)
+	go func() {
+		watching <- Watch(sourceDir, sourceDir, opts, done)
+	}()
+	waitForFile(t, filepath.Join(sourceDir, "Garden.md"), "## Backlinks")
+	time.Sleep(5 * watchDebounce)
+	close(done)
+	require.NoError(<-watching)
+	require.Equal(1, runs, "Its own output doesn't set off another run")
+}
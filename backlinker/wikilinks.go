@@ -0,0 +1,130 @@
+package backlinker
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/sheldonhull/sharedbrain/wikilink"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// LinkSyntax chooses which ways of writing links are recognized besides wikilinks.
+type LinkSyntax string
+
+const (
+	// LinkSyntaxWikilinks recognizes wikilinks, [[page]], and embeds, ![[page]], alone.
+	LinkSyntaxWikilinks LinkSyntax = ""
+	// LinkSyntaxRoam recognizes Roam's tags as well: #[[page]], and #page for a name
+	// without spaces. They're written as links labelled with the tag, except in notes
+	// rewritten in place, where they're left as they are.
+	LinkSyntaxRoam LinkSyntax = "roam"
+)
+
+// foundLink is a wikilink found in a file: its text and the paragraph it's in. A
+// markdown link to another note, like [text](other-note.md), is found too, with its
+// destination as the text and the text it shows as the label.
+type foundLink struct {
+	Text     string `json:"text"`
+	Context  string `json:"context"`
+	Label    string `json:"label,omitempty"`
+	Markdown bool   `json:"markdown,omitempty"`
+	// start and stop are where the link is in the text that was parsed, with the ! of an
+	// embed like ![[page]], which embed is set for, or the # of a tag, which tag is set for.
+	start int
+	stop  int
+	embed bool
+	tag   bool
+}
+
+// linkRecorder records each wikilink the wikilink extension finds in a file. A new one
+// is used for every file, so that any number of files can be parsed at once.
+type linkRecorder struct {
+	links []foundLink
+}
+
+func (r *linkRecorder) Track(link wikilink.Link) {
+	r.links = append(r.links, foundLink{
+		Text:    link.Target,
+		Context: link.Context,
+		start:   link.Start,
+		stop:    link.Stop,
+		embed:   link.Embed,
+		tag:     link.Tag,
+	})
+}
+
+// parseNote parses the file with Goldmark, returning the document and the wikilinks
+// found in it.
+func parseNote(filetext []byte, opts *Options) (ast.Node, *linkRecorder) {
+	found := &linkRecorder{}
+	md := goldmark.New(goldmark.WithExtensions(&wikilink.Extender{
+		Resolver: wikilink.ResolverFunc(backlinkCollector{}.Normalize),
+		Tracker:  found,
+		Tags:     opts.LinkSyntax == LinkSyntaxRoam,
+	}))
+	return md.Parser().Parse(text.NewReader(filetext)), found
+}
+
+// findLinks parses the file with Goldmark and returns the links in it, in order.
+func findLinks(filetext []byte, opts *Options) []foundLink {
+	doc, found := parseNote(filetext, opts)
+	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, isLink := node.(*ast.Link); isLink && entering {
+			if markdown, isNote := noteLink(link, filetext); isNote {
+				found.links = append(found.links, markdown)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	sort.SliceStable(found.links, func(i, j int) bool { return found.links[i].start < found.links[j].start })
+	return found.links
+}
+
+// noteLink returns the markdown link as a found link, if it's a relative link to a
+// markdown file. Whether that's one of the notes depends on the note it's in.
+func noteLink(link *ast.Link, source []byte) (foundLink, bool) {
+	destination, err := url.Parse(string(link.Destination))
+	if err != nil || destination.Scheme != "" || destination.Host != "" ||
+		strings.HasPrefix(destination.Path, "/") || !isMarkdownFile(destination.Path) {
+		return foundLink{}, false
+	}
+	found := foundLink{
+		Text:     destination.Path,
+		Label:    string(link.Text(source)),
+		Markdown: true,
+	}
+	if destination.Fragment != "" {
+		found.Text += "#" + destination.Fragment
+	}
+	lines := enclosingBlock(link).Lines()
+	found.Context = blockText(lines, source)
+	// Markdown links are never rewritten, so they only need a place in the order
+	if text, isText := link.FirstChild().(*ast.Text); isText {
+		found.start = text.Segment.Start
+	} else if lines.Len() > 0 {
+		found.start = lines.At(0).Start
+	}
+	found.stop = found.start
+	return found, true
+}
+
+// enclosingBlock is the block (usually a paragraph) an inline node is in.
+func enclosingBlock(node ast.Node) ast.Node {
+	for node.Parent() != nil && node.Type() != ast.TypeBlock {
+		node = node.Parent()
+	}
+	return node
+}
+
+// blockText is the text of a block's lines, as the context of the links in it.
+func blockText(lines *text.Segments, source []byte) string {
+	context := ""
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		context += string(segment.Value(source))
+	}
+	return context
+}
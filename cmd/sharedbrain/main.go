@@ -0,0 +1,248 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/sheldonhull/sharedbrain/backlinker"
+)
+
+const VERSION = "1.1.2"
+
+const usage = `Usage: sharedbrain <command> [flags]
+
+Commands:
+  build    convert the notes in -content and write them, with backlinks, to -dest
+  check    report problems with the notes, such as dangling links, without writing
+  stats    print counts of the notes, stubs and links
+  graph    print the link graph as Mermaid or JSON
+  version  print the version
+
+Run "sharedbrain <command> -h" for the flags of a command. Flags without a command
+(as in "sharedbrain -content notes -dest site") run build.
+
+Settings are read from sharedbrain.yaml, if there is one in the working directory, or
+from the file given with -config. Flags override them.
+`
+
+// commands are the subcommands, by name.
+var commands = map[string]func(args []string) error{
+	"build":   build,
+	"check":   check,
+	"stats":   stats,
+	"graph":   graph,
+	"version": func([]string) error { return nil },
+}
+
+func main() {
+	log.Printf("sharedbrain %s\n", VERSION)
+	args := os.Args[1:]
+	name := "build"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	command, exists := commands[name]
+	if !exists {
+		fmt.Fprint(os.Stderr, usage)
+		if name != "help" {
+			os.Exit(2)
+		}
+		return
+	}
+	err := command(args)
+	if err != nil {
+		log.Fatalf("Error when processing: %v\n", err)
+	}
+}
+
+// sourceFlags are the flags every command that reads the notes has.
+type sourceFlags struct {
+	config      *string
+	content     *string
+	basePath    *string
+	concurrency *int
+	linkSyntax  *string
+	target      *string
+}
+
+func addSourceFlags(flags *flag.FlagSet) sourceFlags {
+	return sourceFlags{
+		config:      flags.String("config", "", "Configuration file (defaults to "+backlinker.ConfigFile+" if there is one)"),
+		content:     flags.String("content", "", "Source directory"),
+		basePath:    flags.String("base-path", "", "Generate root-relative links under this path"),
+		concurrency: flags.Int("concurrency", 0, "How many notes to read at once (defaults to the number of CPUs)"),
+		linkSyntax:  flags.String("link-syntax", "", "Links to recognize besides wikilinks: roam, for #[[page]] and #page"),
+		target:      flags.String("target", "", "Static site generator to write for: hugo (the default), jekyll, eleventy or zola"),
+	}
+}
+
+// settings reads the configuration file, if there is one, and applies the flags over it.
+func (s sourceFlags) settings() (backlinker.Config, error) {
+	var config backlinker.Config
+	filename := *s.config
+	if filename == "" {
+		if _, err := os.Stat(backlinker.ConfigFile); err == nil {
+			filename = backlinker.ConfigFile
+		}
+	}
+	if filename != "" {
+		var err error
+		config, err = backlinker.LoadConfig(filename)
+		if err != nil {
+			return config, err
+		}
+	}
+	if *s.content != "" {
+		config.Content = *s.content
+	}
+	if *s.basePath != "" {
+		config.BasePath = *s.basePath
+	}
+	if *s.concurrency > 0 {
+		config.Concurrency = *s.concurrency
+	}
+	if *s.linkSyntax != "" {
+		config.LinkSyntax = backlinker.LinkSyntax(*s.linkSyntax)
+	}
+	if *s.target != "" {
+		config.Target = backlinker.Target(*s.target)
+	}
+	return config, nil
+}
+
+// load parses the flags and reads the notes from the source directory.
+func load(flags *flag.FlagSet, source sourceFlags, args []string, report *backlinker.Report) (backlinker.FileMap, error) {
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	config, err := source.settings()
+	if err != nil {
+		return nil, err
+	}
+	if config.Content == "" {
+		return nil, fmt.Errorf("content has not been set. Cannot proceed")
+	}
+	opts := config.Options()
+	opts.Report = report
+	return backlinker.LoadFiles(config.Content, opts)
+}
+
+func build(args []string) error {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	source := addSourceFlags(flags)
+	dest := flags.String("dest", "", "Destination directory")
+	version := flags.Bool("v", false, "Prints version")
+	strict := flags.Bool("strict", false, "Fail if there are any warnings")
+	watch := flags.Bool("watch", false, "Rebuild whenever a note changes, until interrupted")
+	dryRun := flags.Bool("dry-run", false, "Write nothing, and print a diff of how each file would change")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *version {
+		log.Print("(just printing version, at your request)\n")
+		return nil
+	}
+
+	config, err := source.settings()
+	if err != nil {
+		return err
+	}
+	if *dest != "" {
+		config.Dest = *dest
+	}
+	config.Strict = config.Strict || *strict
+	if config.Dest == "" || config.Content == "" {
+		log.Fatal("Either dest or content have not been set. Cannot proceed.\n")
+	}
+	opts := config.Options()
+	if *dryRun {
+		opts.DryRun = os.Stdout
+	}
+	if *watch {
+		return watchUntilInterrupted(config, opts)
+	}
+	err = backlinker.ProcessBackLinksWithOptions(config.Content, config.Dest, opts)
+	if err != nil {
+		return err
+	}
+	log.Print("Generation complete!\n")
+	return nil
+}
+
+func watchUntilInterrupted(config backlinker.Config, opts backlinker.Options) error {
+	done := make(chan struct{})
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupts
+		close(done)
+	}()
+	log.Printf("Watching %s for changes\n", config.Content)
+	return backlinker.Watch(config.Content, config.Dest, opts, done)
+}
+
+// check exits with status 1 if there are any warnings, so that it can gate a pipeline.
+func check(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	source := addSourceFlags(flags)
+	report := backlinker.Report{}
+	_, err := load(flags, source, args, &report)
+	if err != nil {
+		return err
+	}
+	for _, warning := range report.Warnings {
+		fmt.Println(warning)
+	}
+	if len(report.Warnings) > 0 {
+		log.Printf("%d warning(s)\n", len(report.Warnings))
+		os.Exit(1)
+	}
+	log.Print("No problems found\n")
+	return nil
+}
+
+func stats(args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	source := addSourceFlags(flags)
+	files, err := load(flags, source, args, nil)
+	if err != nil {
+		return err
+	}
+	counts := backlinker.CollectStats(files)
+	fmt.Printf("notes:      %d\n", counts.Notes)
+	fmt.Printf("date notes: %d\n", counts.DateNotes)
+	fmt.Printf("stubs:      %d\n", counts.Stubs)
+	fmt.Printf("links:      %d\n", counts.Links)
+	fmt.Printf("orphans:    %d\n", counts.Orphans)
+	return nil
+}
+
+func graph(args []string) error {
+	flags := flag.NewFlagSet("graph", flag.ExitOnError)
+	source := addSourceFlags(flags)
+	format := flags.String("format", "mermaid", "Output format: mermaid or json")
+	root := flags.String("root", "", "Only include the notes near this one (required for json)")
+	depth := flags.Int("depth", 1, "How many links from the root to include")
+	files, err := load(flags, source, args, nil)
+	if err != nil {
+		return err
+	}
+	switch {
+	case *format == "mermaid" && *root == "":
+		return backlinker.WriteGraphMermaid(files, os.Stdout)
+	case *format == "mermaid":
+		return backlinker.WriteLocalGraphMermaid(files, *root, *depth, os.Stdout)
+	case *format == "json" && *root != "":
+		return backlinker.WriteLocalGraphJSON(files, *root, *depth, os.Stdout)
+	case *format == "json":
+		return fmt.Errorf("the json graph needs a root")
+	}
+	return fmt.Errorf("unknown graph format %q", *format)
+}
@@ -3,9 +3,12 @@ module github.com/sheldonhull/sharedbrain
 go 1.16
 
 require (
-	github.com/dangoor/goldmark-wikilinks v1.0.0
+	github.com/BurntSushi/toml v1.3.2
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/stretchr/testify v1.5.1
 	github.com/yuin/goldmark v1.1.25
+	golang.org/x/text v0.3.7
 	gopkg.in/yaml.v2 v2.2.7
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,266 @@
+// Package wikilink is sharedbrain's handling of wikilinks, [[page]], as a goldmark
+// extension, for generators built on goldmark to resolve and collect the links of a
+// vault of notes the way sharedbrain does.
+//
+// Each wikilink becomes a Node, which is rendered as a link to the destination its
+// Resolver gives, and is reported to its Tracker with the paragraph it's in, which is
+// what a note's backlinks are made of:
+//
+//	md := goldmark.New(goldmark.WithExtensions(&wikilink.Extender{
+//		Resolver: wikilink.ResolverFunc(func(target string) string {
+//			return "/" + strings.ToLower(target) + "/"
+//		}),
+//		Tracker: wikilink.TrackerFunc(func(link wikilink.Link) {
+//			backlinks[link.Target] = append(backlinks[link.Target], link.Context)
+//		}),
+//	}))
+//
+// The state of a parse is kept by the Extender's Tracker, not by the extension, so
+// any number of documents can be parsed at once, each with its own Extender.
+package wikilink
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Link is a wikilink found in a document: its text and the paragraph it's in.
+type Link struct {
+	// Target is the text between the brackets, such as page#heading|label, or the name
+	// in a tag.
+	Target string
+	// Context is the text of the block, usually a paragraph, the link is in.
+	Context string
+	// Start and Stop are where the link is in the source, with the ! of an embed like
+	// ![[page]], which Embed is set for, or the # of a tag, which Tag is set for.
+	Start int
+	Stop  int
+	Embed bool
+	Tag   bool
+}
+
+// Resolver gives the destination a wikilink's target links to.
+type Resolver interface {
+	Resolve(target string) string
+}
+
+// ResolverFunc is a function used as a Resolver.
+type ResolverFunc func(target string) string
+
+// Resolve calls the function.
+func (f ResolverFunc) Resolve(target string) string {
+	return f(target)
+}
+
+// Tracker is told of each wikilink found, in the order they're parsed.
+type Tracker interface {
+	Track(link Link)
+}
+
+// TrackerFunc is a function used as a Tracker.
+type TrackerFunc func(link Link)
+
+// Track calls the function.
+func (f TrackerFunc) Track(link Link) {
+	f(link)
+}
+
+// KindWikilink is the kind of a Node.
+var KindWikilink = ast.NewNodeKind("Wikilink")
+
+// Node is a wikilink in the AST. Its child is the text shown for it: its label, or
+// otherwise its target.
+type Node struct {
+	ast.BaseInline
+	// Destination is what the Resolver gave for the target.
+	Destination []byte
+	Link        Link
+}
+
+// Kind implements ast.Node.Kind.
+func (n *Node) Kind() ast.NodeKind {
+	return KindWikilink
+}
+
+// Dump implements ast.Node.Dump.
+func (n *Node) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"Destination": string(n.Destination),
+		"Target":      n.Link.Target,
+	}, nil)
+}
+
+// Extender is the goldmark extension for wikilinks.
+type Extender struct {
+	// Resolver gives the destination of each link. Without one, the destination is the
+	// target as it's written, without its label.
+	Resolver Resolver
+	// Tracker, if set, is told of each link found.
+	Tracker Tracker
+	// Tags makes Roam's tags, #[[page]] and #page, links too.
+	Tags bool
+}
+
+// Extend implements goldmark.Extender.
+func (e *Extender) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(&wikilinkParser{e}, 102)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&wikilinkRenderer{}, 500)))
+}
+
+func (e *Extender) resolve(target string) string {
+	if e.Resolver != nil {
+		return e.Resolver.Resolve(target)
+	}
+	name, _ := splitLabel(target)
+	return name
+}
+
+// wikilinkParser is the inline parser that finds wikilinks.
+type wikilinkParser struct {
+	*Extender
+}
+
+// Trigger looks for the [[ beginning of wikilinks, and the ! of embeds like ![[page]],
+// which would otherwise be taken for the start of an image.
+func (wl *wikilinkParser) Trigger() []byte {
+	if wl.Tags {
+		return []byte{'[', '!', '#'}
+	}
+	return []byte{'[', '!'}
+}
+
+func (wl *wikilinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	// An embed's link starts after its !, and a tag's after its #
+	open := 0
+	if len(line) > 0 && (line[0] == '!' || line[0] == '#') {
+		open = 1
+	}
+	if len(line) > 0 && line[0] == '#' && !isTagStart(block.PrecendingCharacter()) {
+		return nil
+	}
+	if len(line) > 1 && line[0] == '#' && line[1] != '[' {
+		return wl.parseTag(parent, block)
+	}
+	// Did we not actually find a wikilink?
+	if len(line) < open+2 || line[open] != '[' || line[open+1] != '[' {
+		return nil
+	}
+	gotFirst := false
+	pos := open + 2
+	for ; pos < len(line); pos++ {
+		b := line[pos]
+		// look for two ]] to close out the wikilink
+		if b == ']' {
+			if gotFirst {
+				break
+			}
+			gotFirst = true
+		} else if gotFirst {
+			gotFirst = false
+		}
+	}
+	if pos >= len(line) {
+		return nil
+	}
+	destSegment := text.NewSegment(segment.Start+open+2, segment.Start+pos-1)
+	return wl.add(parent, block, destSegment, Link{
+		Start: segment.Start,
+		Stop:  segment.Start + pos + 1,
+		Embed: line[0] == '!',
+		Tag:   line[0] == '#',
+	})
+}
+
+// parseTag parses a tag like #page, which runs to the first character that can't be in
+// a note's name without a space. A tag starts with a letter, so that #1 isn't one.
+func (wl *wikilinkParser) parseTag(parent ast.Node, block text.Reader) ast.Node {
+	line, segment := block.PeekLine()
+	if r, _ := utf8.DecodeRune(line[1:]); !unicode.IsLetter(r) {
+		return nil
+	}
+	pos := 1
+	for pos < len(line) {
+		r, size := utf8.DecodeRune(line[pos:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("_-/", r) {
+			break
+		}
+		pos += size
+	}
+	destSegment := text.NewSegment(segment.Start+1, segment.Start+pos)
+	return wl.add(parent, block, destSegment, Link{
+		Start: segment.Start,
+		Stop:  segment.Start + pos,
+		Tag:   true,
+	})
+}
+
+// isTagStart reports whether a tag can follow the character, so that the # in a link
+// such as [[page#heading]], or in the middle of a word, doesn't start one.
+func isTagStart(previous rune) bool {
+	return unicode.IsSpace(previous) || strings.ContainsRune("([", previous)
+}
+
+// add reports the link that was found, with its text and context, to the Tracker and
+// puts it in the AST.
+func (wl *wikilinkParser) add(parent ast.Node, block text.Reader, destSegment text.Segment, found Link) ast.Node {
+	found.Target = string(block.Value(destSegment))
+	lines := parent.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		found.Context += string(block.Value(seg))
+	}
+	if wl.Tracker != nil {
+		wl.Tracker.Track(found)
+	}
+
+	block.Advance(found.Stop - found.Start)
+
+	link := &Node{Destination: []byte(wl.resolve(found.Target)), Link: found}
+	if index := strings.Index(found.Target, "|"); index >= 0 {
+		label := destSegment.WithStart(destSegment.Start + index + 1)
+		destSegment = label.TrimLeftSpace(block.Source())
+	}
+	link.AppendChild(link, ast.NewTextSegment(destSegment))
+	return link
+}
+
+// splitLabel separates what a link such as [[page|label]] points to from the text shown
+// for it. The pipe can be written \| so that it doesn't end the cell of a table.
+func splitLabel(target string) (string, string) {
+	index := strings.Index(target, "|")
+	if index < 0 {
+		return target, ""
+	}
+	return strings.TrimSuffix(target[:index], "\\"), strings.TrimSpace(target[index+1:])
+}
+
+// wikilinkRenderer renders wikilinks in HTML as the links they are.
+type wikilinkRenderer struct{}
+
+func (r *wikilinkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindWikilink, r.render)
+}
+
+func (r *wikilinkRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*Node)
+	if entering {
+		_, _ = w.WriteString(`<a href="`)
+		if !html.IsDangerousURL(n.Destination) {
+			_, _ = w.Write(util.EscapeHTML(util.URLEscape(n.Destination, true)))
+		}
+		_, _ = w.WriteString(`">`)
+	} else {
+		_, _ = w.WriteString("</a>")
+	}
+	return ast.WalkContinue, nil
+}
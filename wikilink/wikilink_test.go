@@ -0,0 +1,61 @@
+package wikilink
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+)
+
+func TestExtender(t *testing.T) {
+	require := require.New(t)
+	var links []Link
+	md := goldmark.New(goldmark.WithExtensions(&Extender{
+		Resolver: ResolverFunc(func(target string) string {
+			name, _ := splitLabel(target)
+			return "/" + strings.ToLower(strings.ReplaceAll(name, " ", "-")) + "/"
+		}),
+		Tracker: TrackerFunc(func(link Link) { links = append(links, link) }),
+		Tags:    true,
+	}))
+	source := "See [[Lily Pond|the pond]] and #Frogs.\n\nAn ![[Embed]], not [a link](x.md).\n"
+	var output bytes.Buffer
+	require.NoError(md.Convert([]byte(source), &output))
+	require.Equal(`<p>See <a href="/lily-pond/">the pond</a> and <a href="/frogs/">Frogs</a>.</p>
+<p>An <a href="/embed/">Embed</a>, not <a href="x.md">a link</a>.</p>
+`, output.String())
+
+	require.Len(links, 3)
+	require.Equal(Link{
+		Target:  "Lily Pond|the pond",
+		Context: "See [[Lily Pond|the pond]] and #Frogs.",
+		Start:   4,
+		Stop:    26,
+	}, links[0])
+	require.True(links[1].Tag)
+	require.Equal("Frogs", links[1].Target)
+	require.Equal("#Frogs", source[links[1].Start:links[1].Stop])
+	require.True(links[2].Embed)
+	require.Equal("![[Embed]]", source[links[2].Start:links[2].Stop])
+}
+
+func TestExtenderWithoutResolver(t *testing.T) {
+	var output bytes.Buffer
+	md := goldmark.New(goldmark.WithExtensions(&Extender{}))
+	require.NoError(t, md.Convert([]byte("A #tag and [[Page#Heading|label]]\n"), &output))
+	require.Equal(t, "<p>A #tag and <a href=\"Page#Heading\">label</a></p>\n", output.String())
+}
+
+func TestTagsStartWithLetter(t *testing.T) {
+	var links []Link
+	md := goldmark.New(goldmark.WithExtensions(&Extender{
+		Tracker: TrackerFunc(func(link Link) { links = append(links, link) }),
+		Tags:    true,
+	}))
+	var output bytes.Buffer
+	require.NoError(t, md.Convert([]byte("Item #1 of #2020-goals, and #ideas.\n"), &output))
+	require.Equal(t, "<p>Item #1 of #2020-goals, and <a href=\"ideas\">ideas</a>.</p>\n", output.String())
+	require.Len(t, links, 1)
+}